@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Returns the given line of the source with a caret under the given column,
+// the caret is extended to underline the given length. Lines and columns start at 1
+func SourceExcerpt(source string, line int, column int, length int) string {
+
+	lines := strings.Split(source, "\n")
+
+	if line < 1 || line > len(lines) || column < 1 {
+		return ""
+	}
+
+	text := []rune(strings.TrimRight(lines[line-1], "\r"))
+
+	if column > len(text)+1 {
+		return ""
+	}
+
+	// Keep the tabs so the caret is aligned with the code
+	padding := ""
+	for _, char := range text[:column-1] {
+		if char == '\t' {
+			padding += "\t"
+		} else {
+			padding += " "
+		}
+	}
+
+	if length < 1 {
+		length = 1
+	}
+
+	if column-1+length > len(text) {
+		length = len(text) - column + 1
+		if length < 1 {
+			length = 1
+		}
+	}
+
+	gutter := fmt.Sprintf("%4d | ", line)
+	emptyGutter := strings.Repeat(" ", len(gutter)-2) + "| "
+
+	return gutter + string(text) + "\n" + emptyGutter + padding + strings.Repeat("^", length)
+}
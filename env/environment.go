@@ -10,12 +10,21 @@ type Environment struct {
 	// Variables is a map of variable names to their values
 	Variables map[string]values.RuntimeValue
 
+	// Locals found by the resolver, stored by slot instead of by name
+	Slots []values.RuntimeValue
+
+	// Names that can not be assigned again, the resolver checks the locals
+	constants map[string]bool
+
 	// keep tracking of imports flow to avoid circular imports
 	ImportChain map[string]bool
 
 	// Module Name
 	ModuleName string
 
+	// Struct of the method that runs in this scope, set in the scope of its calls
+	Owner *values.StructValue
+
 	Parent *Environment
 }
 
@@ -30,6 +39,36 @@ func (env *Environment) DeclareVar(name string, value values.RuntimeValue) error
 	return nil
 }
 
+// Declares a variable that can not be assigned again
+func (env *Environment) DeclareConst(name string, value values.RuntimeValue) error {
+
+	if err := env.DeclareVar(name, value); err != nil {
+		return err
+	}
+
+	env.MakeConstant(name)
+	return nil
+}
+
+// Declares a constant even if the name already exists, like the namespace of a module imported again
+func (env *Environment) ForceDeclareConst(name string, value values.RuntimeValue) {
+	env.ForceDeclare(name, value)
+	env.MakeConstant(name)
+}
+
+// Marks a name of the actual environment as a constant
+func (env *Environment) MakeConstant(name string) {
+	if env.constants == nil {
+		env.constants = make(map[string]bool)
+	}
+	env.constants[name] = true
+}
+
+// Checks if the name is a constant of the actual environment
+func (env Environment) IsConstant(name string) bool {
+	return env.constants[name]
+}
+
 func (env Environment) ExistsInActualEnv(n string) bool {
 	_, ex := env.Variables[n]
 	return ex
@@ -67,6 +106,10 @@ func (env Environment) CheckVarExists(name string) bool {
 }
 
 func (env *Environment) ForceDeclare(name string, value values.RuntimeValue) {
+	// Scopes of blocks and functions only create the map when something is declared by name
+	if env.Variables == nil {
+		env.Variables = make(map[string]values.RuntimeValue)
+	}
 	env.Variables[name] = value
 }
 
@@ -74,6 +117,9 @@ func (env *Environment) ForceDeclare(name string, value values.RuntimeValue) {
 func (env *Environment) SetVar(name string, value values.RuntimeValue) error {
 
 	if env.ExistsInActualEnv(name) {
+		if env.IsConstant(name) {
+			return fmt.Errorf("'%s' is a constant and can not be reassigned", name)
+		}
 		env.ForceDeclare(name, value)
 		return nil
 	} else {
@@ -85,10 +131,59 @@ func (env *Environment) SetVar(name string, value values.RuntimeValue) error {
 	return fmt.Errorf("variable '%s' not found", name)
 }
 
+// Returns the environment of the given number of scopes up
+func (env *Environment) Ancestor(depth int) *Environment {
+	for i := 0; i < depth; i++ {
+		env = env.Parent
+	}
+	return env
+}
+
+// Finds the struct of the method where the code runs, its private members can be used there
+func (env *Environment) FindOwner() *values.StructValue {
+	for ; env != nil; env = env.Parent {
+		if env.Owner != nil {
+			return env.Owner
+		}
+	}
+	return nil
+}
+
+// Declares a local in the given slot of the actual scope
+func (env *Environment) DeclareSlot(slot int, value values.RuntimeValue) {
+	for len(env.Slots) <= slot {
+		env.Slots = append(env.Slots, nil)
+	}
+	env.Slots[slot] = value
+}
+
+// Returns a local, false if it is not declared yet
+func (env *Environment) GetSlot(depth int, slot int) (values.RuntimeValue, bool) {
+	scope := env.Ancestor(depth)
+
+	if slot >= len(scope.Slots) || scope.Slots[slot] == nil {
+		return nil, false
+	}
+
+	return scope.Slots[slot], true
+}
+
+// Assigns a value to a local, false if it is not declared yet
+func (env *Environment) SetSlot(depth int, slot int, value values.RuntimeValue) bool {
+	scope := env.Ancestor(depth)
+
+	if slot >= len(scope.Slots) || scope.Slots[slot] == nil {
+		return false
+	}
+
+	scope.Slots[slot] = value
+	return true
+}
+
 func NewScopeEnv(parent *Environment, size int) *Environment {
 	return &Environment{
 		Parent:      parent,
-		Variables:   make(map[string]values.RuntimeValue, size),
+		Slots:       make([]values.RuntimeValue, 0, size),
 		ImportChain: parent.ImportChain,
 		ModuleName:  parent.ModuleName,
 	}
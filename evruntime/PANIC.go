@@ -1,12 +1,13 @@
 package evruntime
 
 import (
+	"evie/common"
 	environment "evie/env"
 	"evie/values"
 	"fmt"
 )
 
-func (e Evaluator) Panic(errorType string, msg string, line int, env *environment.Environment) values.ErrorValue {
+func (e Evaluator) Panic(errorType string, msg string, line int, column int, env *environment.Environment) values.ErrorValue {
 
 	errorStruct, _ := env.GetVar("ErrorObject")
 
@@ -14,7 +15,9 @@ func (e Evaluator) Panic(errorType string, msg string, line int, env *environmen
 
 	errProperties["message"] = values.StringValue{Value: msg}
 	errProperties["type"] = values.StringValue{Value: errorType}
+	errProperties["kind"] = values.ErrorKindOf(errorType)
 	errProperties["line"] = values.NumberValue{Value: float64(line)}
+	errProperties["column"] = values.NumberValue{Value: float64(column)}
 	errProperties["module"] = values.StringValue{Value: env.ModuleName}
 
 	callStack := &values.ArrayValue{Value: make([]values.RuntimeValue, 0)}
@@ -37,7 +40,31 @@ func (e Evaluator) PrintError(err values.ErrorValue) {
 
 	errValue := err.Object
 
-	output := "\n >>> DONT PANIC, but something went wrong at line " + errValue.Value["line"].GetString() + " at module " + errValue.Value["module"].GetString() + ":\n\t " + errValue.Value["type"].GetString() + ": " + errValue.Value["message"].GetString() + "\n"
+	// Some errors are returned without going through Panic, so there is no object to show
+	if errValue == nil {
+		fmt.Println("\n >>> DONT PANIC, but something went wrong:\n\t " + err.ErrorType + ": " + err.Value + "\n")
+		return
+	}
+
+	module := errValue.Value["module"].GetString()
+	line := int(errValue.Value["line"].GetNumber())
+	column := 0
+
+	position := "line " + errValue.Value["line"].GetString()
+
+	// Errors built by the user with ErrorObject{} do not have a column
+	if col, ok := errValue.Value["column"]; ok && col.GetType() == values.NumberType && col.GetNumber() > 0 {
+		column = int(col.GetNumber())
+		position += ", column " + col.GetString()
+	}
+
+	output := "\n >>> DONT PANIC, but something went wrong at " + position + " at module " + module + ":\n\t " + errValue.Value["type"].GetString() + ": " + errValue.Value["message"].GetString() + "\n"
+
+	if source, ok := e.Sources[module]; ok && column > 0 {
+		if excerpt := common.SourceExcerpt(source, line, column, 1); excerpt != "" {
+			output += "\n" + excerpt + "\n"
+		}
+	}
 
 	cstack := errValue.Value["callstack"].(*values.ArrayValue).Value
 
@@ -0,0 +1,128 @@
+package evruntime
+
+import (
+	"evie/values"
+	"fmt"
+	"runtime"
+)
+
+// Result of calling a function that has yield statements
+// Its body runs in its own goroutine, but only the body or the code asking for the values runs at a time
+type Generator struct {
+	body    func(c *Coroutine) values.RuntimeValue
+	co      *Coroutine
+	started bool
+	done    bool
+	index   int
+}
+
+// Side of a generator seen by its body, it does not keep the generator so a
+// generator nobody uses can be collected while its body waits in a yield
+type Coroutine struct {
+	resume  chan bool
+	yielded chan values.RuntimeValue
+}
+
+// The body returns an error or nothing when it finishes
+func NewGenerator(body func(c *Coroutine) values.RuntimeValue) *Generator {
+	g := &Generator{body: body, co: &Coroutine{resume: make(chan bool), yielded: make(chan values.RuntimeValue)}}
+
+	// The body of a generator dropped before its end would wait forever
+	runtime.SetFinalizer(g, (*Generator).Close)
+
+	return g
+}
+
+// Runs the body until its next yield and returns the value, or false when it has finished
+// Errors of the body are given as the value and finish the generator
+func (g *Generator) Next() (values.RuntimeValue, values.RuntimeValue, bool) {
+
+	if g.done {
+		return nil, nil, false
+	}
+
+	co := g.co
+
+	if !g.started {
+		g.started = true
+		body := g.body
+		go func() {
+			result := body(co)
+			if result.GetType() != values.ErrorType {
+				result = nil
+			}
+			co.yielded <- result
+		}()
+	} else {
+		co.resume <- true
+	}
+
+	value := <-co.yielded
+
+	if value == nil {
+		g.done = true
+		return nil, nil, false
+	}
+
+	if value.GetType() == values.ErrorType {
+		g.done = true
+		return value, nil, true
+	}
+
+	g.index++
+	return value, values.NumberValue{Value: float64(g.index - 1)}, true
+}
+
+// Finishes the generator, if its body is waiting in a yield it stops there.
+// Loops call it when they stop before taking all the values
+func (g *Generator) Close() {
+
+	if g.started && !g.done {
+		g.co.resume <- false
+	}
+
+	g.done = true
+}
+
+// Called by the body, gives the value and waits until the next one is asked
+// The goroutine of the body ends here if the generator is closed
+func (c *Coroutine) Yield(value values.RuntimeValue) {
+	c.yielded <- value
+
+	if !<-c.resume {
+		runtime.Goexit()
+	}
+}
+
+func (g *Generator) Iterate() values.Iterator {
+	return g
+}
+
+func (g *Generator) GetType() values.ValueType {
+	return values.GeneratorType
+}
+func (g *Generator) GetString() string {
+	return "generator"
+}
+func (g *Generator) GetNumber() float64 {
+	return 0
+}
+func (g *Generator) GetBool() bool {
+	return true
+}
+
+func (g *Generator) GetProp(name string) (values.RuntimeValue, error) {
+
+	// Returns the next value, or nothing when there are no more
+	if name == "next" {
+		return values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
+			value, _, ok := g.Next()
+			if !ok {
+				return values.NothingValue{}
+			}
+			return value
+		}}, nil
+	}
+
+	return values.NothingValue{}, fmt.Errorf("property %s does not exists", name)
+}
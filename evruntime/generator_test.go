@@ -0,0 +1,74 @@
+package evruntime
+
+import (
+	"evie/values"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// A body that never finishes by itself
+func countForever(co *Coroutine) values.RuntimeValue {
+	for n := 0.0; ; n++ {
+		co.Yield(values.NumberValue{Value: n})
+	}
+}
+
+// Waits a bit for the goroutines of the stopped bodies to end
+func waitGoroutines(t *testing.T, expected int) {
+	for i := 0; i < 100 && runtime.NumGoroutine() > expected; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+
+	if actual := runtime.NumGoroutine(); actual > expected {
+		t.Errorf("%d goroutines are still running, expected %d", actual, expected)
+	}
+}
+
+func TestGeneratorCloseStopsBody(t *testing.T) {
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		g := NewGenerator(countForever)
+		g.Next()
+		g.Next()
+		g.Close()
+
+		if _, _, ok := g.Next(); ok {
+			t.Fatal("a closed generator gave a value")
+		}
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestDroppedGeneratorStopsBody(t *testing.T) {
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		g := NewGenerator(countForever)
+		g.Next()
+	}
+
+	waitGoroutines(t, before)
+}
+
+func TestForInClosesGenerator(t *testing.T) {
+
+	before := runtime.NumGoroutine()
+
+	g := NewGenerator(countForever)
+	it := values.Iterator(g)
+
+	it.Next()
+	values.CloseIterator(it)
+
+	if !g.done {
+		t.Error("the generator was not closed")
+	}
+
+	waitGoroutines(t, before)
+}
@@ -2,28 +2,39 @@ package evruntime
 
 import (
 	"errors"
-	"evie/common"
 	environment "evie/env"
-	"evie/lexer"
-	"evie/lib"
-	"evie/native"
 	"evie/parser"
 	"evie/values"
-	"fmt"
 	"os"
-	"path/filepath"
-	"strconv"
 )
 
 type Evaluator struct {
 	Nodes     []parser.Stmt
 	CallStack CallStack
 	RootPath  string
+
+	// Source code of each loaded module, used to show where the errors are
+	Sources map[string]string
+
+	// Set while running the body of a generator
+	Generator *Coroutine
 }
 
 // Takes an AST and evaluates it, Node by node
+// Errors that reach the top are printed and stop the program
 func (e Evaluator) Evaluate(env *environment.Environment) *environment.Environment {
 
+	if err := e.EvaluateModule(env); err != nil {
+		e.PrintError(err.(values.ErrorValue))
+		os.Exit(1)
+	}
+
+	return env
+}
+
+// Evaluates the nodes of a module until one of them fails, returns that error or nil
+func (e Evaluator) EvaluateModule(env *environment.Environment) values.RuntimeValue {
+
 	e.CallStack = CallStack{Items: make([]CallStackItem, 0)}
 
 	for _, node := range e.Nodes {
@@ -32,13 +43,11 @@ func (e Evaluator) Evaluate(env *environment.Environment) *environment.Environme
 
 		// If the return value is an ErrorValue
 		if ret.GetType() == values.ErrorType {
-
-			e.PrintError(ret.(values.ErrorValue))
-			os.Exit(1)
+			return ret
 		}
 
 	}
-	return env
+	return nil
 }
 
 // Evaluate a single Statement node
@@ -56,8 +65,14 @@ func (e Evaluator) EvaluateStmt(n parser.Stmt, env *environment.Environment) val
 		return e.EvaluateFunctionDeclarationStmt(n.(parser.FunctionDeclarationNode), env)
 	case parser.NodeReturnStatement:
 		return e.EvaluateReturnNode(n.(parser.ReturnNode), env)
+	case parser.NodeYieldStatement:
+		return e.EvaluateYieldNode(n.(parser.YieldNode), env)
 	case parser.NodeLoopStatement:
 		return e.EvaluateLoopStmt(n.(parser.LoopStmtNode), env)
+	case parser.NodeWhileStatement:
+		return e.EvaluateWhileStmt(n.(parser.WhileStmtNode), env)
+	case parser.NodeMatchStatement:
+		return e.EvaluateMatchStmt(n.(parser.MatchStmtNode), env)
 	case parser.NodeStructMethodDeclaration:
 		return e.EvaluateStructMethodExpression(n.(parser.StructMethodDeclarationNode), env)
 	case parser.NodeBreakStatement:
@@ -68,6 +83,10 @@ func (e Evaluator) EvaluateStmt(n parser.Stmt, env *environment.Environment) val
 		return e.EvaluateTryCatchNode(n.(parser.TryCatchNode), env)
 	case parser.NodeStructDeclaration:
 		return e.EvaluatStructDeclarationStmt(n.(parser.StructDeclarationNode), env)
+	case parser.NodeInterfaceDeclaration:
+		return e.EvaluateInterfaceDeclarationStmt(n.(parser.InterfaceDeclarationNode), env)
+	case parser.NodeEnumDeclaration:
+		return e.EvaluateEnumDeclarationStmt(n.(parser.EnumDeclarationNode), env)
 	case parser.NodeImportStatement:
 		return e.EvaluateImportNode(n.(parser.ImportNode), env)
 	default: // If is not a statement, it is a expressionStmt
@@ -78,60 +97,62 @@ func (e Evaluator) EvaluateStmt(n parser.Stmt, env *environment.Environment) val
 
 // Test for native imports
 func (e Evaluator) EvaluateImportNode(node parser.ImportNode, env *environment.Environment) values.RuntimeValue {
+	return e.ImportModule(node, env, func(ast []parser.Stmt, moduleEnv *environment.Environment) values.RuntimeValue {
+		eval := Evaluator{Nodes: ast, RootPath: e.RootPath, Sources: e.Sources}
+		return eval.EvaluateModule(moduleEnv)
+	})
+}
 
-	// Line
-	line := node.Line
+// LOOP STATEMENT
+func (e Evaluator) EvaluateLoopStmt(node parser.LoopStmtNode, env *environment.Environment) values.RuntimeValue {
 
-	// Map std libraries load methods
-	libmap := lib.GetLibMap()
+	for {
 
-	// Is a native library?
-	if val, ok := libmap[node.Path]; ok {
-		val(env)
-	} else {
-		// Is a custom file
+		// Each iteration has its own scope if the body declares something
+		loopenv := NewBlockEnv(node.Body, env)
 
-		if _, ok := env.ImportChain[node.Path]; ok {
-			return e.Panic(values.CircularImportError, "Circular import with module: "+node.Path, line, env)
-		}
+		// Loop through body
+		for _, stmt := range node.Body {
 
-		// Do not add .ev to modules ;)
-		path := node.Path + ".ev"
+			ret := e.EvaluateStmt(stmt, loopenv)
 
-		// Read file, parse and evaluate
-		var source string = common.ReadFile(e.RootPath + string(filepath.Separator) + path)
+			t := ret.GetType()
+
+			if t == values.ErrorType || t == values.ReturnType {
+				return ret
+			} else if t == values.BreakType {
+				return values.NothingValue{}
+			} else if t == values.ContinueType {
+				break
+			}
+		}
+	}
+}
 
-		tokens := lexer.Tokenize(source)
-		ast := parser.NewParser(tokens).GetAST()
+// WHILE STATEMENT
+func (e Evaluator) EvaluateWhileStmt(node parser.WhileStmtNode, env *environment.Environment) values.RuntimeValue {
 
-		// Create new environment for the module with the parent environment
-		envForModule := environment.NewEnvironment()
-		native.SetupEnvironment(envForModule)
-		envForModule.ImportChain = env.ImportChain
-		envForModule.ImportChain[env.ModuleName] = true
-		envForModule.ModuleName = node.Path
+	for {
 
-		eval := Evaluator{Nodes: ast}
-		importEnv := eval.Evaluate(envForModule)
-		// Get the created environment after evaluate the module
-		// Get all the variables loaded and load into the actual environment
-		// using a namespace
-		env.ForceDeclare(node.Alias, values.NamespaceValue{Value: importEnv.Variables})
-	}
+		condition := e.EvaluateExpression(node.Condition, env)
 
-	return values.NothingValue{}
-}
+		if condition.GetType() == values.ErrorType {
+			return condition
+		}
 
-// LOOP STATEMENT
-func (e Evaluator) EvaluateLoopStmt(node parser.LoopStmtNode, env *environment.Environment) values.RuntimeValue {
+		value, err := e.EvaluateImplicitBoolConversion(condition)
 
-	// Creating new environment
+		if err != nil {
+			return e.Panic(values.RuntimeError, err.Error(), node.Line, node.Column, env)
+		}
 
-	for {
+		if !value {
+			return values.NothingValue{}
+		}
 
-		loopenv := environment.NewScopeEnv(env, 0)
+		// Each iteration has its own scope if the body declares something
+		loopenv := NewBlockEnv(node.Body, env)
 
-		// Loop through body
 		for _, stmt := range node.Body {
 
 			ret := e.EvaluateStmt(stmt, loopenv)
@@ -149,6 +170,65 @@ func (e Evaluator) EvaluateLoopStmt(node parser.LoopStmtNode, env *environment.E
 	}
 }
 
+// MATCH STATEMENT
+func (e Evaluator) EvaluateMatchStmt(node parser.MatchStmtNode, env *environment.Environment) values.RuntimeValue {
+
+	value := e.EvaluateExpression(node.Value, env)
+
+	if value.GetType() == values.ErrorType {
+		return value
+	}
+
+	for _, arm := range node.Arms {
+
+		// The names of the pattern live in the scope of the arm
+		armenv := environment.NewScopeEnv(env, 0)
+
+		matched, err := e.MatchPattern(arm.Pattern, value, armenv)
+
+		if err != nil {
+			return err
+		}
+
+		if !matched {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := e.EvaluateExpression(arm.Guard, armenv)
+
+			if guard.GetType() == values.ErrorType {
+				return guard
+			}
+
+			pass, err := e.EvaluateImplicitBoolConversion(guard)
+
+			if err != nil {
+				return e.Panic(values.InvalidConversionError, err.Error(), node.Line, node.Column, env)
+			}
+
+			if !pass {
+				continue
+			}
+		}
+
+		for _, stmt := range arm.Body {
+
+			result := e.EvaluateStmt(stmt, armenv)
+
+			t := result.GetType()
+
+			if t == values.ErrorType || t == values.ReturnType || t == values.BreakType || t == values.ContinueType {
+				return result
+			}
+		}
+
+		break
+	}
+
+	return values.NothingValue{}
+}
+
 // TRY CATCH
 func (e Evaluator) EvaluateTryCatchNode(node parser.TryCatchNode, env *environment.Environment) values.RuntimeValue {
 
@@ -160,7 +240,8 @@ func (e Evaluator) EvaluateTryCatchNode(node parser.TryCatchNode, env *environme
 
 		ret := e.EvaluateStmt(stmt, env)
 
-		if ret.GetType() == values.ReturnType {
+		// return, break and continue leave the try after running the finally block
+		if ret.GetType() == values.ReturnType || ret.GetType() == values.BreakType || ret.GetType() == values.ContinueType {
 			if node.Finally != nil {
 				e.EvaluateFinallyBlock(node.Finally, env)
 			}
@@ -169,7 +250,7 @@ func (e Evaluator) EvaluateTryCatchNode(node parser.TryCatchNode, env *environme
 
 		if ret.GetType() == values.ErrorType {
 
-			env.ForceDeclare("error", ret.(values.ErrorValue).Object)
+			ForceDeclareVar("error", node.ErrorBinding, ret.(values.ErrorValue).Object, env)
 
 			// CATCH BODY
 			for _, cstmt := range catch {
@@ -177,17 +258,12 @@ func (e Evaluator) EvaluateTryCatchNode(node parser.TryCatchNode, env *environme
 				result := e.EvaluateStmt(cstmt, env)
 
 				// Error inside the catch lol
-				if result.GetType() == values.ErrorType || result.GetType() == values.ReturnType {
+				if result.GetType() == values.ErrorType || result.GetType() == values.ReturnType || result.GetType() == values.BreakType || result.GetType() == values.ContinueType {
 					if node.Finally != nil {
 						e.EvaluateFinallyBlock(node.Finally, env)
 					}
 
 					return result
-				} else if result.GetType() == values.BreakType {
-
-					return values.BoolValue{Value: true}
-				} else if result.GetType() == values.ContinueType {
-					continue
 				}
 			}
 
@@ -207,7 +283,7 @@ func (e Evaluator) EvaluateTryCatchNode(node parser.TryCatchNode, env *environme
 
 func (e Evaluator) EvaluateFinallyBlock(stmt []parser.Stmt, env *environment.Environment) values.RuntimeValue {
 
-	scope := environment.NewScopeEnv(env, 0)
+	scope := NewBlockEnv(stmt, env)
 
 	for _, stmt := range stmt {
 		res := e.EvaluateStmt(stmt, scope)
@@ -221,6 +297,42 @@ func (e Evaluator) EvaluateFinallyBlock(stmt []parser.Stmt, env *environment.Env
 
 }
 
+// YIELD STMT
+func (e Evaluator) EvaluateYieldNode(node parser.YieldNode, env *environment.Environment) values.RuntimeValue {
+
+	value := e.EvaluateExpression(node.Value, env)
+	if value.GetType() == values.ErrorType {
+		return value
+	}
+
+	e.Generator.Yield(value)
+
+	return values.NothingValue{}
+}
+
+// Returns a generator that runs the body of the function when its values are asked
+func (e Evaluator) StartGenerator(fn values.FunctionValue, fnEnv *environment.Environment) values.RuntimeValue {
+	return NewGenerator(func(co *Coroutine) values.RuntimeValue {
+		e.Generator = co
+		e.CallStack = CallStack{Items: append([]CallStackItem{}, e.CallStack.Items...)}
+
+		for _, stmt := range fn.Body {
+			result := e.EvaluateStmt(stmt, fnEnv)
+
+			if result.GetType() == values.ErrorType {
+				return result
+			}
+
+			// return ends the values
+			if result.GetType() == values.ReturnType {
+				break
+			}
+		}
+
+		return values.NothingValue{}
+	})
+}
+
 // RETURN STMT
 func (e Evaluator) EvaluateReturnNode(node parser.ReturnNode, env *environment.Environment) values.RuntimeValue {
 
@@ -254,101 +366,141 @@ func (e Evaluator) EvaluateForInStmt(node parser.ForInSatementNode, env *environ
 		return iterator
 	}
 
-	// Flag for Break statement
-	thereIsBreak := false
-
-	if iterator.GetType() == values.ArrayType {
-		// Creating new environment
+	it, err := e.GetIterator(iterator, node.Line, node.Column, env)
 
-		iterValues := iterator.(*values.ArrayValue).Value
+	if err != nil {
+		return err
+	}
 
-		for index, value := range iterValues {
+	for {
+		local, index, ok := it.Next()
 
-			loopenv := environment.NewScopeEnv(env, 2)
+		if !ok {
+			break
+		}
 
-			if thereIsBreak == true {
-				break
+		// Errors of native iterators do not have a position yet
+		if err, isError := local.(values.ErrorValue); isError && err.Object == nil {
+			errorType := err.ErrorType
+			if errorType == "" {
+				errorType = values.RuntimeError
 			}
+			return e.Panic(errorType, err.Value, node.Line, node.Column, env)
+		} else if isError {
+			return err
+		}
 
-			// Load variables in env on each iteration
-			loopenv.ForceDeclare(node.LocalVarName, value)
+		if result, stop := e.evaluateForInBody(node, env, local, index); stop {
+			values.CloseIterator(it)
+			return result
+		}
+	}
 
-			if node.IndexVarName != "" {
-				loopenv.ForceDeclare(node.IndexVarName, values.NumberValue{Value: float64(index)})
-			}
+	return values.BoolValue{Value: true}
+}
 
-			// LOOP through for in body!
-			for _, stmt := range node.Body {
+// Runs the body of a for in loop once, returns true when the loop has to stop
+func (e Evaluator) evaluateForInBody(node parser.ForInSatementNode, env *environment.Environment, local values.RuntimeValue, index values.RuntimeValue) (values.RuntimeValue, bool) {
 
-				result := e.EvaluateStmt(stmt, loopenv)
+	// New environment on each iteration, so closures keep their own values
+	loopenv := environment.NewScopeEnv(env, 2)
 
-				if result.GetType() == values.ErrorType || result.GetType() == values.ReturnType {
+	// Load variables in env on each iteration
+	if node.Pattern != nil {
+		if err := e.Destructure(*node.Pattern, local, node.Line, node.Column, loopenv); err != nil {
+			return err, true
+		}
+	} else {
+		ForceDeclareVar(node.LocalVarName, node.LocalBinding, local, loopenv)
+	}
 
-					return result
-				} else if result.GetType() == values.BreakType {
-					thereIsBreak = true
-					break
-				} else if result.GetType() == values.ContinueType {
-					break
-				}
+	if node.IndexVarName != "" {
+		ForceDeclareVar(node.IndexVarName, node.IndexBinding, index, loopenv)
+	}
 
-			}
+	// LOOP through for in body!
+	for _, stmt := range node.Body {
+
+		result := e.EvaluateStmt(stmt, loopenv)
+
+		if result.GetType() == values.ErrorType || result.GetType() == values.ReturnType {
+			return result, true
+		} else if result.GetType() == values.BreakType {
+			return values.BoolValue{Value: true}, true
+		} else if result.GetType() == values.ContinueType {
+			break
 		}
+	}
+
+	return nil, false
+}
 
-	} else if iterator.GetType() == values.DictionaryType {
-		// Creating new environment
+// STRUCT DECLARATION
+func (e Evaluator) EvaluatStructDeclarationStmt(node parser.StructDeclarationNode, env *environment.Environment) values.RuntimeValue {
 
-		loopenv := environment.NewScopeEnv(env, 2)
+	var parent values.RuntimeValue
 
-		iterValues := iterator.(*values.DictionaryValue).Value
+	if node.Parent != "" {
+		parent = e.LookupVar(node.Parent, node.ParentBinding, node.Line, node.Column, env)
+	}
 
-		for index, value := range iterValues {
+	defaults := make([]values.RuntimeValue, len(node.Properties))
 
-			if thereIsBreak == true {
-				break
-			}
+	for i, value := range node.Defaults {
+		if value != nil {
+			defaults[i] = e.EvaluateExpression(value, env)
+		}
+	}
 
-			loopenv.ForceDeclare(node.LocalVarName, values.StringValue{Value: index})
-			if node.IndexVarName != "" {
-				loopenv.ForceDeclare(node.IndexVarName, value)
-			}
+	statics := make([]values.RuntimeValue, len(node.Statics))
 
-			for _, stmt := range node.Body {
+	for i, static := range node.Statics {
+		statics[i] = e.EvaluateExpression(static.Value, env)
+		if statics[i].GetType() == values.ErrorType {
+			return statics[i]
+		}
+	}
 
-				result := e.EvaluateStmt(stmt, loopenv)
+	interfaces := make([]values.RuntimeValue, len(node.Interfaces))
 
-				if result.GetType() == values.ErrorType || result.GetType() == values.ReturnType {
+	for i, iface := range node.Interfaces {
+		interfaces[i] = e.EvaluateExpression(iface, env)
+	}
 
-					return result
-				} else if result.GetType() == values.BreakType {
-					thereIsBreak = true
-					break
-				} else if result.GetType() == values.ContinueType {
-					break
-				}
+	structValue := e.NewStruct(node, parent, defaults, statics, interfaces, env)
 
-			}
+	if structValue.GetType() == values.ErrorType {
+		return structValue
+	}
 
-		}
+	err := DeclareVar(node.Name, node.Binding, structValue, env)
 
+	if err != nil {
+		return e.Panic(values.IdentifierError, err.Error(), node.Line, node.Column, env)
 	}
 
 	return values.BoolValue{Value: true}
 }
 
-// STRUCT DECLARATION
-func (e Evaluator) EvaluatStructDeclarationStmt(node parser.StructDeclarationNode, env *environment.Environment) values.RuntimeValue {
+// INTERFACE DECLARATION
+func (e Evaluator) EvaluateInterfaceDeclarationStmt(node parser.InterfaceDeclarationNode, env *environment.Environment) values.RuntimeValue {
 
-	rtValue := values.StructValue{}
+	err := DeclareVar(node.Name, node.Binding, NewInterface(node), env)
+
+	if err != nil {
+		return e.Panic(values.IdentifierError, err.Error(), node.Line, node.Column, env)
+	}
+
+	return values.BoolValue{Value: true}
+}
 
-	rtValue.Properties = node.Properties
-	rtValue.Methods = make(map[string]values.RuntimeValue)
-	rtValue.Name = node.Name
+// ENUM DECLARATION
+func (e Evaluator) EvaluateEnumDeclarationStmt(node parser.EnumDeclarationNode, env *environment.Environment) values.RuntimeValue {
 
-	err := env.DeclareVar(node.Name, rtValue)
+	err := DeclareVar(node.Name, node.Binding, NewEnum(node), env)
 
 	if err != nil {
-		return e.Panic(values.IdentifierError, err.Error(), node.Line, env)
+		return e.Panic(values.IdentifierError, err.Error(), node.Line, node.Column, env)
 	}
 
 	return values.BoolValue{Value: true}
@@ -359,17 +511,19 @@ func (e Evaluator) EvaluateFunctionDeclarationStmt(node parser.FunctionDeclarati
 
 	fn := values.FunctionValue{}
 
+	fn.Name = node.Name
 	fn.Body = node.Body
 	fn.Parameters = node.Parameters
+	fn.Generator = node.Generator
 	fn.Struct = ""
 	fn.Evaluator = e
 	fnenv := env
 	fn.Environment = fnenv
 
-	err := env.DeclareVar(node.Name, fn)
+	err := DeclareVar(node.Name, node.Binding, fn, env)
 
 	if err != nil {
-		return e.Panic(values.IdentifierError, err.Error(), node.Line, env)
+		return e.Panic(values.IdentifierError, err.Error(), node.Line, node.Column, env)
 	}
 
 	return values.BoolValue{Value: true}
@@ -390,11 +544,11 @@ func (e Evaluator) EvaluateIfStmt(node parser.IfStatementNode, env *environment.
 	value, err := e.EvaluateImplicitBoolConversion(evaluatedExp)
 
 	if err != nil {
-		return e.Panic(values.InvalidConversionError, err.Error(), node.Line, env)
+		return e.Panic(values.InvalidConversionError, err.Error(), node.Line, node.Column, env)
 	}
 
 	if value == true {
-		ifenv := environment.NewScopeEnv(env, 0)
+		ifenv := NewBlockEnv(node.Body, env)
 
 		for _, stmt := range node.Body {
 
@@ -428,14 +582,14 @@ func (e Evaluator) EvaluateIfStmt(node parser.IfStatementNode, env *environment.
 				value, err := e.EvaluateImplicitBoolConversion(exp)
 
 				if err != nil {
-					return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+					return e.Panic(values.RuntimeError, err.Error(), node.Line, node.Column, env)
 				}
 
 				if value == true {
 
 					matched = true
 
-					ifenv := environment.NewScopeEnv(env, 0)
+					ifenv := NewBlockEnv(elseif.Body, env)
 
 					for _, stmt := range elseif.Body {
 
@@ -455,7 +609,7 @@ func (e Evaluator) EvaluateIfStmt(node parser.IfStatementNode, env *environment.
 		// if node.ElseBody != nil && matched == false {
 		if node.ElseBody != nil && matched == false {
 
-			ifenv := environment.NewScopeEnv(env, 0)
+			ifenv := NewBlockEnv(node.ElseBody, env)
 
 			for _, stmt := range node.ElseBody {
 
@@ -495,10 +649,16 @@ func (e Evaluator) EvaluateVarDeclaration(node parser.VarDeclarationNode, env *e
 		return parsed
 	}
 
-	err := env.DeclareVar(identifier.Value, parsed)
+	if node.Pattern != nil {
+		if err := e.Destructure(*node.Pattern, parsed, node.Line, node.Column, env); err != nil {
+			return err
+		}
+	} else if err := DeclareVar(identifier.Value, identifier.Binding, parsed, env); err != nil {
+		return e.Panic(values.RuntimeError, err.Error(), node.Line, node.Column, env)
+	}
 
-	if err != nil {
-		return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+	if node.Constant {
+		MakeConstants(node, env)
 	}
 
 	return values.NothingValue{}
@@ -517,11 +677,7 @@ func (e Evaluator) EvaluateExpression(n parser.Exp, env *environment.Environment
 		return values.BoolValue{Value: n.(parser.BooleanNode).Value}
 	case parser.NodeIdentifier:
 		node := n.(parser.IdentifierNode)
-		lookup, err := env.GetVar(node.Value)
-		if err != nil {
-			return e.Panic(values.IdentifierError, err.Error(), node.Line, env)
-		}
-		return lookup
+		return e.LookupVar(node.Value, node.Binding, node.Line, node.Column, env)
 	case parser.NodeNothing:
 		return values.NothingValue{}
 	case parser.NodeAssignment:
@@ -546,6 +702,10 @@ func (e Evaluator) EvaluateExpression(n parser.Exp, env *environment.Environment
 		return e.EvaluateSliceExpression(n.(parser.SliceExpNode), env)
 	case parser.NodeTernaryExp:
 		return e.EvaluateTernaryExpression(n.(parser.TernaryExpNode), env)
+	case parser.NodeRangeExp:
+		return e.EvaluateRangeExpression(n.(parser.RangeExpNode), env)
+	case parser.NodeInterpolationExp:
+		return e.EvaluateInterpolationExpression(n.(parser.InterpolationNode), env)
 	case parser.NodeAnonFunctionDeclaration:
 		return e.EvaluateAnonymousFunctionExpression(n.(parser.AnonFunctionDeclarationNode), env)
 	case parser.NodeBinaryComparisonExp:
@@ -554,7 +714,7 @@ func (e Evaluator) EvaluateExpression(n parser.Exp, env *environment.Environment
 		return e.EvaluateBinaryLogicExpression(n.(parser.BinaryLogicExpNode), env)
 	default:
 		// litter.Dump(n)
-		return e.Panic(values.RuntimeError, "Unknown Expression Type", 0, env)
+		return e.Panic(values.RuntimeError, "Unknown Expression Type", 0, 0, env)
 	}
 
 }
@@ -565,6 +725,7 @@ func (e Evaluator) EvaluateAnonymousFunctionExpression(node parser.AnonFunctionD
 
 	fn.Body = node.Body
 	fn.Parameters = node.Parameters
+	fn.Generator = node.Generator
 	fn.Struct = ""
 
 	// fnenv := environment.NewEnvironment()
@@ -576,6 +737,44 @@ func (e Evaluator) EvaluateAnonymousFunctionExpression(node parser.AnonFunctionD
 	return fn
 }
 
+func (e Evaluator) EvaluateRangeExpression(node parser.RangeExpNode, env *environment.Environment) values.RuntimeValue {
+
+	from := e.EvaluateExpression(node.From, env)
+	if from.GetType() == values.ErrorType {
+		return from
+	}
+
+	to := e.EvaluateExpression(node.To, env)
+	if to.GetType() == values.ErrorType {
+		return to
+	}
+
+	var step values.RuntimeValue
+	if node.Step != nil {
+		step = e.EvaluateExpression(node.Step, env)
+		if step.GetType() == values.ErrorType {
+			return step
+		}
+	}
+
+	return e.MakeRange(from, to, step, node.Inclusive, node.Line, node.Column, env)
+}
+
+func (e Evaluator) EvaluateInterpolationExpression(node parser.InterpolationNode, env *environment.Environment) values.RuntimeValue {
+
+	parts := make([]values.RuntimeValue, len(node.Parts))
+
+	for i, part := range node.Parts {
+		value := e.EvaluateExpression(part, env)
+		if value.GetType() == values.ErrorType {
+			return value
+		}
+		parts[i] = value
+	}
+
+	return e.Interpolate(parts, node.Line, node.Column, env)
+}
+
 func (e Evaluator) EvaluateTernaryExpression(node parser.TernaryExpNode, env *environment.Environment) values.RuntimeValue {
 
 	condition := e.EvaluateExpression(node.Condition, env)
@@ -587,7 +786,7 @@ func (e Evaluator) EvaluateTernaryExpression(node parser.TernaryExpNode, env *en
 	value, err := e.EvaluateImplicitBoolConversion(condition)
 
 	if err != nil {
-		return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+		return e.Panic(values.RuntimeError, err.Error(), node.Line, node.Column, env)
 	}
 
 	if value {
@@ -618,88 +817,28 @@ func (e Evaluator) EvaluateSliceExpression(node parser.SliceExpNode, env *enviro
 		}
 	}
 
-	switch value.GetType() {
-	case values.ArrayType:
-
-		fn, err := value.(*values.ArrayValue).GetProp("slice")
-
-		if err != nil {
-			return e.Panic(values.PropertyError, err.Error(), node.Line, env)
-		}
-
-		var ret values.RuntimeValue
-
-		if node.To == nil {
-			ret = fn.(values.NativeFunctionValue).Value([]values.RuntimeValue{init})
-		} else {
-			ret = fn.(values.NativeFunctionValue).Value([]values.RuntimeValue{init, end})
-		}
-
-		if ret.GetType() == values.ErrorType {
-			return e.Panic(ret.(values.ErrorValue).ErrorType, ret.(values.ErrorValue).Value, node.Line, env)
-		}
-
-		return ret
-	case values.StringType:
-
-		fn, err := value.GetProp("slice")
-
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
-		}
-		var ret values.RuntimeValue
-
-		if node.To == nil {
-			ret = fn.(values.NativeFunctionValue).Value([]values.RuntimeValue{init})
-		} else {
-			ret = fn.(values.NativeFunctionValue).Value([]values.RuntimeValue{init, end})
-		}
-
-		if ret.GetType() == values.ErrorType {
-			return e.Panic(ret.(values.ErrorValue).ErrorType, ret.(values.ErrorValue).Value, node.Line, env)
-		}
-		return ret
-	default:
-		return values.ErrorValue{Value: "Expected array or string"}
-	}
-
+	return e.SliceValue(value, init, end, node.Line, node.Column, env)
 }
 
 // Declaration of a struct method
 func (e Evaluator) EvaluateStructMethodExpression(node parser.StructMethodDeclarationNode, env *environment.Environment) values.RuntimeValue {
 
-	structName := node.Struct
-
-	// Check if struct exists and is a struct
-	structLup, err := env.GetVar(structName)
-
-	if err != nil {
-		e.Panic(values.IdentifierError, err.Error(), node.Line, env)
-	}
-
-	if structLup.GetType() != values.StructType {
-		e.Panic(values.TypeError, "Expected struct, got "+structLup.GetType().String(), node.Line, env)
-	}
-
-	// Check if method already exists
-	_, exists := structLup.(values.StructValue).Methods[node.Function.Name]
-
-	if exists {
-		return e.Panic(values.RuntimeError, "Method '"+node.Function.Name+"' already exists in struct '"+structName+"'", node.Line, env)
-	}
-
 	// Create function value
 	fn := values.FunctionValue{}
 
 	fn.Body = node.Function.Body
 	fn.Parameters = node.Function.Parameters
-	fn.Struct = structName
+	fn.Generator = node.Function.Generator
 	fn.Environment = env
+	fn.Evaluator = e
 
-	// Store function in struct
-	env.Variables[structName].(values.StructValue).Methods[node.Function.Name] = fn
+	structLup := e.LookupVar(node.Struct, node.StructBinding, node.Line, node.Column, env)
 
-	return values.NothingValue{}
+	if node.Static {
+		return e.DeclareStaticMethod(structLup, node.Struct, fn, node.Function.Name, node.Line, node.Column, env)
+	}
+
+	return e.DeclareStructMethod(structLup, node.Struct, fn, node.Function.Name, node.Line, node.Column, env)
 }
 
 // Evaluate a member expression
@@ -710,28 +849,13 @@ func (e Evaluator) EvaluateMemberExpression(node parser.MemberExpNode, env *envi
 	if varValue.GetType() == values.ErrorType {
 		return varValue
 	}
-	//init := timer.Init()
-	fn, err := varValue.GetProp(node.Member)
-	//timer.add("get_prop", init)
-
-	if err != nil {
-		return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
-	}
-
-	return fn
 
+	return e.MemberValue(varValue, node.Member, env.FindOwner(), node.Line, node.Column, env)
 }
 
 // Evaluate an object initialization
 func (e Evaluator) EvaluateObjInitializeExpression(node parser.ObjectInitExpNode, env *environment.Environment) values.RuntimeValue {
 
-	// Syntax for object initialization is the same as dictionaries
-	// So initialize one for obtaining the values
-	propDict := node.Value
-
-	// val will be the final object where we will store the values
-	val := values.ObjectValue{}
-
 	// Lookup for the base struct
 	structLup := e.EvaluateExpression(node.Struct, env)
 
@@ -739,45 +863,21 @@ func (e Evaluator) EvaluateObjInitializeExpression(node parser.ObjectInitExpNode
 		return structLup
 	}
 
-	// If when evaluating the struct it is not a struct, return error
-	if _, ok := structLup.(values.StructValue); !ok {
-		return e.Panic(values.RuntimeError, "You only can initialize objects of structs, not of "+structLup.GetType().String(), node.Line, env)
-	}
-
-	val.Struct = structLup.(values.StructValue)
-	val.Value = make(map[string]values.RuntimeValue)
-
-	// Create a map with the properties defined in the struct
-	// So we can check if the property exists with less complexity
-	structProperties := make(map[string]bool)
-
-	// Initialize each property of the object with nothing
-	// And at the same type fill the map of properties created earlier
-	for _, prop := range val.Struct.Properties {
-		val.Value[prop] = values.NothingValue{}
-		structProperties[prop] = true
-	}
-
-	// Evaluate expressions and set values
-	// For each property defined in the initialization, which is parsed as a dictionary
-	// Check if that property exists in the struct by using the map created earlier
-	for key, exp := range propDict.Value {
+	// Syntax for object initialization is the same as dictionaries
+	properties := make(map[string]values.RuntimeValue, len(node.Value.Value))
 
-		if _, ok := structProperties[key]; !ok {
-			return e.Panic(values.RuntimeError, "Unknown property "+key, node.Line, env)
-		}
+	for key, exp := range node.Value.Value {
 
-		value := e.EvaluateExpression(exp, env) // Evaluate value
+		value := e.EvaluateExpression(exp, env)
 
 		if value.GetType() == values.ErrorType {
 			return value
 		}
 
-		// Add the value to the map of properties of the object
-		val.Value[key] = value
+		properties[key] = value
 	}
 
-	return &val
+	return e.NewObject(structLup, properties, env.FindOwner(), node.Line, node.Column, env)
 }
 
 func (e Evaluator) EvaluateDictionaryExpression(node parser.DictionaryExpNode, env *environment.Environment) values.RuntimeValue {
@@ -815,43 +915,7 @@ func (e Evaluator) EvaluateIndexAccessExpression(node parser.IndexAccessExpNode,
 		return identifier
 	}
 
-	// El indice puede ser numeric si es un array, o un string si se trata de un diccionario
-	// En ambos casos lo trataremos como un string y si es necesario se convertira a int
-
-	var i string = index.GetString()
-
-	switch identifier.GetType() {
-	case values.ArrayType:
-		val := identifier.(*values.ArrayValue)
-		iToInt, _ := strconv.Atoi(i)
-		if iToInt < 0 {
-			iToInt = len(val.Value) + iToInt
-		}
-		if iToInt >= len(val.Value) {
-			return e.Panic(values.InvalidIndexError, "Index "+i+" out of range", node.Line, env)
-		}
-		return val.Value[iToInt]
-	case values.StringType:
-		val := identifier.(values.StringValue).Value
-		iToInt, _ := strconv.Atoi(i)
-		if iToInt >= len(val) {
-			return e.Panic(values.InvalidIndexError, "Index "+i+" out of range", node.Line, env)
-		}
-		return values.StringValue{Value: string(val[iToInt])}
-	case values.DictionaryType:
-		val := identifier.(*values.DictionaryValue)
-		item, exists := val.Value[i]
-
-		if !exists {
-			return e.Panic(values.RuntimeError, "Undefined key '"+i, node.Line, env)
-		}
-
-		return item
-
-	default:
-		return e.Panic(values.RuntimeError, "Only arrays and dictionaries can be accessed by index", node.Line, env)
-	}
-
+	return e.IndexValue(identifier, index, node.Line, node.Column, env)
 }
 
 func (e Evaluator) EvaluateArrayExpression(node parser.ArrayExpNode, env *environment.Environment) values.RuntimeValue {
@@ -880,6 +944,19 @@ func (e *Evaluator) EvaluateCallExpression(node parser.CallExpNode, env *environ
 
 	}
 
+	var namedArgs map[string]values.RuntimeValue
+
+	for _, arg := range node.NamedArgs {
+		value := e.EvaluateExpression(arg.Value, env)
+		if value.GetType() == values.ErrorType {
+			return value
+		}
+		if namedArgs == nil {
+			namedArgs = make(map[string]values.RuntimeValue, len(node.NamedArgs))
+		}
+		namedArgs[arg.Name] = value
+	}
+
 	calle := e.EvaluateExpression(node.Name, env)
 
 	if calle.GetType() == values.ErrorType {
@@ -890,50 +967,90 @@ func (e *Evaluator) EvaluateCallExpression(node parser.CallExpNode, env *environ
 
 	case values.NativeFunctionType:
 
-		val := calle.(values.NativeFunctionValue).Value(evaluatedArgs)
+		args, err := NativeArguments(calle.(values.NativeFunctionValue), evaluatedArgs, namedArgs)
+
+		if err != nil {
+			return e.Panic(values.InvalidArgumentError, err.Error(), node.Line, node.Column, env)
+		}
+
+		val := calle.(values.NativeFunctionValue).Value(args)
 
 		if val.GetType() == values.ErrorType {
-			return e.Panic(val.(values.ErrorValue).ErrorType, val.GetString(), node.Line, env)
+			// Errors raised by code the native runs, like a generator, already have their position
+			if val.(values.ErrorValue).Object != nil {
+				return val
+			}
+			return e.Panic(val.(values.ErrorValue).ErrorType, val.GetString(), node.Line, node.Column, env)
 		}
 
 		return val
 	case values.FunctionType:
-		fn := calle.(values.FunctionValue)
+		return e.callFunction(calle.(values.FunctionValue), evaluatedArgs, namedArgs, node.Line, node.Column, env)
 
-		fnEnv := environment.NewScopeEnv(fn.Environment.(*environment.Environment), len(node.Args))
+	case values.StructType:
+		object, init := e.Construct(calle, len(evaluatedArgs)+len(namedArgs), node.Line, node.Column, env)
 
-		e.CallStack.Add(node.Line, env.ModuleName)
-
-		for index, arg := range evaluatedArgs {
-			fnEnv.ForceDeclare(fn.Parameters[index], arg)
+		if init == nil || object.GetType() == values.ErrorType {
+			return object
 		}
-		// Set this
-		if fn.Struct != "" {
-			fnEnv.ForceDeclare("this", fn.StructObjRef)
+
+		if result := e.callFunction(init.(values.FunctionValue), evaluatedArgs, namedArgs, node.Line, node.Column, env); result != nil && result.GetType() == values.ErrorType {
+			return result
 		}
-		var result values.RuntimeValue
 
-		for _, stmt := range fn.Body {
-			result = e.EvaluateStmt(stmt, fnEnv)
+		return object
 
-			if result.GetType() == values.ErrorType {
-				e.CallStack.Remove()
-				return result
-			}
+	case values.EnumMemberType:
+		return e.NewEnumMember(calle.(values.EnumMemberValue), evaluatedArgs, namedArgs, node.Line, node.Column, env)
 
-			if result.GetType() == values.ReturnType {
-				e.CallStack.Remove()
-				return result.(values.ReturnValue).Value
-			}
+	default:
+		return e.Panic(values.RuntimeError, "Only functions can be called not "+calle.GetType().String(), node.Line, node.Column, env)
+	}
 
-		}
+}
+
+// Runs the body of a function in a new scope with the given arguments
+func (e *Evaluator) callFunction(fn values.FunctionValue, evaluatedArgs []values.RuntimeValue, namedArgs map[string]values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	args, err := BindArguments(fn, evaluatedArgs, namedArgs)
+
+	if err != nil {
+		return e.Panic(values.InvalidArgumentError, err.Error(), line, column, env)
+	}
+
+	fnEnv := NewCallEnv(fn, args)
+
+	e.CallStack.Add(line, env.ModuleName)
+
+	if err := e.DeclareDefaults(fn, fnEnv); err != nil {
 		e.CallStack.Remove()
-		return result
+		return err
+	}
 
-	default:
-		return e.Panic(values.RuntimeError, "Only functions can be called not "+calle.GetType().String(), node.Line, env)
+	if fn.Generator {
+		generator := e.StartGenerator(fn, fnEnv)
+		e.CallStack.Remove()
+		return generator
 	}
 
+	var result values.RuntimeValue
+
+	for _, stmt := range fn.Body {
+		result = e.EvaluateStmt(stmt, fnEnv)
+
+		if result.GetType() == values.ErrorType {
+			e.CallStack.Remove()
+			return result
+		}
+
+		if result.GetType() == values.ReturnType {
+			e.CallStack.Remove()
+			return result.(values.ReturnValue).Value
+		}
+
+	}
+	e.CallStack.Remove()
+	return result
 }
 
 // Evaluate an Assignment Expression
@@ -942,6 +1059,10 @@ func (e Evaluator) EvaluateAssignmentExpression(node parser.AssignmentNode, env
 	// We will make the env function to resolve this.
 	left := node.Left
 
+	if operator, ok := node.CompoundOperator(); ok {
+		return e.EvaluateCompoundAssignment(node, operator, env)
+	}
+
 	// Evaluate the expression of the right side
 	right := e.EvaluateExpression(node.Right, env)
 
@@ -957,42 +1078,14 @@ func (e Evaluator) EvaluateAssignmentExpression(node parser.AssignmentNode, env
 			return val
 		}
 
-		if val.GetType() == values.ArrayType {
-			index := e.EvaluateExpression(expNode.Index, env)
-
-			if index.GetType() == values.ErrorType {
-				return index
-			}
-
-			if index.GetType() != values.NumberType {
-				return e.Panic(values.RuntimeError, "Invalid array index", node.Line, env)
-			}
-
-			finalIndex := int(index.GetNumber())
-
-			if finalIndex < 0 {
-				finalIndex = len(val.(*values.ArrayValue).Value) + finalIndex
-			}
-
-			if finalIndex >= len(val.(*values.ArrayValue).Value) {
-				return e.Panic(values.RuntimeError, "Invalid array index or out of bounds with index: "+fmt.Sprint(finalIndex), node.Line, env) // fmt.Sprintf("Invalid array index or out of bounds with index: %d", node.Line, env)
-			}
+		index := e.EvaluateExpression(expNode.Index, env)
 
-			val.(*values.ArrayValue).Value[int(index.GetNumber())] = right
-		} else if val.GetType() == values.DictionaryType {
-			key := e.EvaluateExpression(expNode.Index, env)
-
-			if key.GetType() == values.ErrorType {
-				return key
-			}
-
-			if key.GetType() != values.StringType {
-				return e.Panic(values.RuntimeError, "Invalid dictionary key", node.Line, env)
-			}
-
-			val.(*values.DictionaryValue).Value[key.GetString()] = right
+		if index.GetType() == values.ErrorType {
+			return index
 		}
 
+		return e.AssignIndex(val, index, right, node.Line, node.Column, env)
+
 	} else if left.ExpType() == parser.NodeMemberExp {
 		expNode := left.(parser.MemberExpNode)
 		val := e.EvaluateExpression(expNode.Left, env)
@@ -1001,239 +1094,112 @@ func (e Evaluator) EvaluateAssignmentExpression(node parser.AssignmentNode, env
 			return val
 		}
 
-		if val.GetType() != values.ObjectType {
-			return e.Panic(values.RuntimeError, "Invalid object assignment", node.Line, env)
-		}
-
-		val.(*values.ObjectValue).Value[expNode.Member] = right
+		return e.AssignMember(val, expNode.Member, right, env.FindOwner(), node.Line, node.Column, env)
 
 	} else if left.ExpType() == parser.NodeIdentifier {
-		err := env.SetVar(left.(parser.IdentifierNode).Value, right)
-
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
-		}
-	} else {
-		return e.Panic(values.RuntimeError, "Invalid assignment", node.Line, env)
+		identifier := left.(parser.IdentifierNode)
+		return e.AssignVar(identifier.Value, identifier.Binding, right, node.Line, node.Column, env)
 	}
 
-	return right
+	return e.Panic(values.RuntimeError, "Invalid assignment", node.Line, node.Column, env)
 }
 
-func (e Evaluator) EvaluateBinaryExpression(node parser.BinaryExpNode, env *environment.Environment) values.RuntimeValue {
-
-	left := e.EvaluateExpression(node.Left, env)
-	right := e.EvaluateExpression(node.Right, env)
-
-	type1 := left.GetType()
-	type2 := right.GetType()
+// Evaluate an assignment like a[i] += 1, the target is evaluated only once
+func (e Evaluator) EvaluateCompoundAssignment(node parser.AssignmentNode, operator parser.OperatorType, env *environment.Environment) values.RuntimeValue {
 
-	if type1 == values.ErrorType {
-		return left
-	}
-	if type2 == values.ErrorType {
-		return right
-	}
-
-	equalTypes := type1 == type2
-
-	if !equalTypes {
-		return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), node.Line, env)
-	}
+	switch node.Left.ExpType() {
+	case parser.NodeIdentifier:
+		identifier := node.Left.(parser.IdentifierNode)
 
-	if node.Operator == parser.OperatorAdd {
+		actual := e.LookupVar(identifier.Value, identifier.Binding, identifier.Line, identifier.Column, env)
+		if actual.GetType() == values.ErrorType {
+			return actual
+		}
 
-		if type1 == values.NumberType {
-			// val := left.(values.NumberValue)
-			// val.Value = val.Value + right.(values.NumberValue).Value
-			// return val
-			return values.NumberValue{Value: left.GetNumber() + right.GetNumber()}
-		} else if type1 == values.StringType {
-			return values.StringValue{Value: left.(values.StringValue).Value + right.(values.StringValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Cant use operator + with type "+type1.String(), node.Line, env)
+		result := e.BinaryOperation(operator, actual, e.EvaluateExpression(node.Right, env), node.Line, node.Column, env)
+		if result.GetType() == values.ErrorType {
+			return result
 		}
 
-	} else if node.Operator == parser.OperatorSubtract {
+		return e.AssignVar(identifier.Value, identifier.Binding, result, node.Line, node.Column, env)
 
-		if type1 == values.NumberType {
-			val := left.(values.NumberValue)
-			val.Value = val.Value - right.(values.NumberValue).Value
-			return val
-			// return values.NumberValue{Value: left.GetNumber() - right.GetNumber()}
-		} else {
-			return e.Panic(values.RuntimeError, "Cant use operator - with type "+type1.String(), node.Line, env)
-		}
-	} else if node.Operator == parser.OperatorMultiply {
+	case parser.NodeIndexAccessExp:
+		expNode := node.Left.(parser.IndexAccessExpNode)
 
-		if type1 == values.NumberType {
-			return values.NumberValue{Value: left.(values.NumberValue).Value * right.(values.NumberValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Cant use operator * with type "+type1.String(), node.Line, env)
+		val := e.EvaluateExpression(expNode.Left, env)
+		if val.GetType() == values.ErrorType {
+			return val
 		}
-	} else if node.Operator == parser.OperatorDivide {
 
-		if type1 == values.NumberType {
-			if right.(values.NumberValue).Value == 0.0 {
-				return e.Panic(values.ZeroDivisionError, "Division by zero", node.Line, env)
-			}
-			return values.NumberValue{Value: left.(values.NumberValue).Value / right.(values.NumberValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Cant use operator / with type "+type1.String(), node.Line, env)
+		index := e.EvaluateExpression(expNode.Index, env)
+		if index.GetType() == values.ErrorType {
+			return index
 		}
-	}
 
-	return e.Panic(values.RuntimeError, "Unknown operator", node.Line, env)
-
-}
-
-func (e Evaluator) EvaluateBinaryLogicExpression(node parser.BinaryLogicExpNode, env *environment.Environment) values.RuntimeValue {
-
-	left := e.EvaluateExpression(node.Left, env)
-	right := e.EvaluateExpression(node.Right, env)
-
-	if left.GetType() == values.ErrorType {
-		return left
-	}
-	if right.GetType() == values.ErrorType {
-		return right
-	}
-
-	if node.Operator == parser.OperatorAnd {
-
-		leftValue, err := e.EvaluateImplicitBoolConversion(left)
-
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+		actual := e.IndexValue(val, index, expNode.Line, expNode.Column, env)
+		if actual.GetType() == values.ErrorType {
+			return actual
 		}
 
-		rightValue, err := e.EvaluateImplicitBoolConversion(right)
-
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+		result := e.BinaryOperation(operator, actual, e.EvaluateExpression(node.Right, env), node.Line, node.Column, env)
+		if result.GetType() == values.ErrorType {
+			return result
 		}
 
-		return values.BoolValue{Value: leftValue && rightValue}
-
-	} else if node.Operator == parser.OperatorOr {
+		return e.AssignIndex(val, index, result, node.Line, node.Column, env)
 
-		leftValue, err := e.EvaluateImplicitBoolConversion(left)
+	case parser.NodeMemberExp:
+		expNode := node.Left.(parser.MemberExpNode)
 
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+		val := e.EvaluateExpression(expNode.Left, env)
+		if val.GetType() == values.ErrorType {
+			return val
 		}
 
-		rightValue, err := e.EvaluateImplicitBoolConversion(right)
-
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
+		actual := e.MemberValue(val, expNode.Member, env.FindOwner(), expNode.Line, expNode.Column, env)
+		if actual.GetType() == values.ErrorType {
+			return actual
 		}
 
-		return values.BoolValue{Value: leftValue || rightValue}
+		result := e.BinaryOperation(operator, actual, e.EvaluateExpression(node.Right, env), node.Line, node.Column, env)
+		if result.GetType() == values.ErrorType {
+			return result
+		}
 
+		return e.AssignMember(val, expNode.Member, result, env.FindOwner(), node.Line, node.Column, env)
 	}
 
-	return values.ErrorValue{Value: "Unknown operator"}
-
+	return e.Panic(values.RuntimeError, "Invalid assignment", node.Line, node.Column, env)
 }
-func (e Evaluator) EvaluateBinaryComparisonExpression(node parser.BinaryComparisonExpNode, env *environment.Environment) values.RuntimeValue {
+
+func (e Evaluator) EvaluateBinaryExpression(node parser.BinaryExpNode, env *environment.Environment) values.RuntimeValue {
 
 	left := e.EvaluateExpression(node.Left, env)
 	right := e.EvaluateExpression(node.Right, env)
 
-	type1 := left.GetType()
-	type2 := right.GetType()
-
-	if type1 == values.ErrorType {
-		return left
-	}
-	if type2 == values.ErrorType {
-		return right
-	}
-
-	equalTypes := type1 == type2
-
-	if node.Operator == parser.OperatorEquals {
-
-		if !equalTypes {
-			return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), node.Line, env)
-		}
-
-		if type1 == values.StringType {
-			return values.BoolValue{Value: left.(values.StringValue).Value == right.(values.StringValue).Value}
-		} else if type1 == values.NumberType {
-			return values.BoolValue{Value: left.(values.NumberValue).Value == right.(values.NumberValue).Value}
-		} else if type1 == values.BoolType {
-			return values.BoolValue{Value: left.(values.BoolValue).Value == right.(values.BoolValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Cant use operator == with type "+type1.String(), node.Line, env)
-		}
-	} else if node.Operator == parser.OperatorGreaterThan {
-
-		if !equalTypes {
-			return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), node.Line, env)
-		}
-
-		if type1 == values.NumberType {
-			return values.BoolValue{Value: left.(values.NumberValue).Value > right.(values.NumberValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Operator > only can be used with numbers, not with type "+type1.String(), node.Line, env)
-		}
-	} else if node.Operator == parser.OperatorLessThan {
-
-		if !equalTypes {
-			return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), node.Line, env)
-		}
-
-		if type1 == values.NumberType {
-			return values.BoolValue{Value: left.(values.NumberValue).Value < right.(values.NumberValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Operator < only can be used with numbers, not with type "+type1.String(), node.Line, env)
-		}
-	} else if node.Operator == parser.OperatorLessOrEqThan {
-
-		if !equalTypes {
-			return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), node.Line, env)
-		}
+	return e.BinaryOperation(node.Operator, left, right, node.Line, node.Column, env)
+}
 
-		if type1 == values.NumberType {
-			return values.BoolValue{Value: left.(values.NumberValue).Value <= right.(values.NumberValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Operator <= only can be used with numbers, not with type "+type1.String(), node.Line, env)
-		}
-	} else if node.Operator == parser.OperatorGreaterOrEqThan {
+func (e Evaluator) EvaluateBinaryLogicExpression(node parser.BinaryLogicExpNode, env *environment.Environment) values.RuntimeValue {
 
-		if !equalTypes {
-			return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), node.Line, env)
-		}
+	left := e.EvaluateExpression(node.Left, env)
+	right := e.EvaluateExpression(node.Right, env)
 
-		if type1 == values.NumberType {
-			return values.BoolValue{Value: left.(values.NumberValue).Value >= right.(values.NumberValue).Value}
-		} else {
-			return e.Panic(values.RuntimeError, "Operator >= only can be used with numbers, not with type "+type1.String(), node.Line, env)
-		}
-	}
+	return e.LogicOperation(node.Operator, left, right, node.Line, node.Column, env)
+}
+func (e Evaluator) EvaluateBinaryComparisonExpression(node parser.BinaryComparisonExpNode, env *environment.Environment) values.RuntimeValue {
 
-	return values.ErrorValue{Value: "Unknown operator"}
+	left := e.EvaluateExpression(node.Left, env)
+	right := e.EvaluateExpression(node.Right, env)
 
+	return e.ComparisonOperation(node.Operator, left, right, node.Line, node.Column, env)
 }
 
 func (e Evaluator) EvaluateUnaryExpression(node parser.UnaryExpNode, env *environment.Environment) values.RuntimeValue {
 
 	exp := e.EvaluateExpression(node.Right, env)
 
-	if node.Operator == "-" && exp.GetType() == values.NumberType {
-		return values.NumberValue{Value: -exp.(values.NumberValue).Value}
-	} else if node.Operator == "not" {
-		res, err := e.EvaluateImplicitBoolConversion(exp)
-
-		if err != nil {
-			return e.Panic(values.RuntimeError, err.Error(), node.Line, env)
-		}
-		return values.BoolValue{Value: !res}
-	} else {
-		return values.ErrorValue{Value: "Unknown operator '" + node.Operator + "'"}
-	}
+	return e.UnaryOperation(node.Operator, exp, node.Line, node.Column, env)
 }
 
 func (e Evaluator) EvaluateImplicitBoolConversion(value values.RuntimeValue) (bool, error) {
@@ -1254,27 +1220,37 @@ func (e Evaluator) EvaluateImplicitBoolConversion(value values.RuntimeValue) (bo
 func (e Evaluator) ExecuteCallback(fn interface{}, args []interface{}) interface{} {
 
 	fnValue := fn.(values.FunctionValue)
-	fnEnv := fnValue.Environment.(*environment.Environment)
 
-	for i, paramName := range fnValue.Parameters {
-		if i+1 < len(args) {
-			break
-		}
+	arguments := make([]values.RuntimeValue, 0, len(args))
 
-		switch val := args[i].(type) {
-		case *values.ObjectValue:
-			fnEnv.Variables[paramName] = val
-		case values.NumberValue:
-			fnEnv.Variables[paramName] = val
-		case values.StringValue:
-			fnEnv.Variables[paramName] = val
-		case values.BoolValue:
-			fnEnv.Variables[paramName] = val
+	for _, arg := range args {
+		switch val := arg.(type) {
 		case values.ArrayValue:
-			fnEnv.Variables[paramName] = &val
+			arguments = append(arguments, &val)
+		case values.RuntimeValue:
+			arguments = append(arguments, val)
 		}
 	}
 
+	// Natives give all the values they have, the function may not want all of them
+	if !HasRestParameter(fnValue) && len(arguments) > len(fnValue.Parameters) {
+		arguments = arguments[:len(fnValue.Parameters)]
+	}
+
+	if err := CheckArity(fnValue, len(arguments)); err != nil {
+		return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: err.Error()}
+	}
+
+	fnEnv := NewCallEnv(fnValue, arguments)
+
+	if err := e.DeclareDefaults(fnValue, fnEnv); err != nil {
+		return err
+	}
+
+	if fnValue.Generator {
+		return e.StartGenerator(fnValue, fnEnv)
+	}
+
 	var result values.RuntimeValue
 
 	for _, stmt := range fnValue.Body {
@@ -0,0 +1,309 @@
+package evruntime
+
+import (
+	environment "evie/env"
+	"evie/parser"
+	"evie/values"
+	"strconv"
+)
+
+// Gives a pattern the values it compares with and declares its names, each engine keeps its variables in its own place
+type PatternScope interface {
+	// Value of a literal or enum pattern, or the struct of an object pattern
+	PatternValue(pattern parser.Pattern) values.RuntimeValue
+	DeclarePattern(pattern parser.Pattern, value values.RuntimeValue)
+}
+
+// The tree walker evaluates the values of a pattern when they are needed and declares its names in the environment
+type envScope struct {
+	e   Evaluator
+	env *environment.Environment
+}
+
+func (s envScope) PatternValue(pattern parser.Pattern) values.RuntimeValue {
+	if pattern.Kind == parser.PatternObject {
+		return s.e.LookupVar(pattern.Name, pattern.Binding, pattern.Line, pattern.Column, s.env)
+	}
+	return s.e.EvaluateExpression(pattern.Value, s.env)
+}
+
+func (s envScope) DeclarePattern(pattern parser.Pattern, value values.RuntimeValue) {
+	ForceDeclareVar(pattern.Name, pattern.Binding, value, s.env)
+}
+
+// Checks if the value has the shape of the pattern, declaring the names of the pattern in env
+// The second result is an error, like a struct pattern of something that is not a struct
+func (e Evaluator) MatchPattern(pattern parser.Pattern, value values.RuntimeValue, env *environment.Environment) (bool, values.RuntimeValue) {
+	return e.MatchPatternIn(pattern, value, envScope{e, env}, env)
+}
+
+// Like MatchPattern, with the values and the names of the pattern in the given scope
+func (e Evaluator) MatchPatternIn(pattern parser.Pattern, value values.RuntimeValue, scope PatternScope, env *environment.Environment) (bool, values.RuntimeValue) {
+
+	switch pattern.Kind {
+	case parser.PatternWildcard:
+		return true, nil
+
+	case parser.PatternBinding:
+		scope.DeclarePattern(pattern, value)
+		return true, nil
+
+	case parser.PatternLiteral:
+		literal := scope.PatternValue(pattern)
+		if literal.GetType() == values.ErrorType {
+			return false, literal
+		}
+		equal, _ := values.Equals(literal, value)
+		return equal, nil
+
+	case parser.PatternAlternative:
+		for _, item := range pattern.Items {
+			if matched, err := e.MatchPatternIn(item, value, scope, env); matched || err != nil {
+				return matched, err
+			}
+		}
+		return false, nil
+
+	case parser.PatternArray:
+		array, ok := value.(*values.ArrayValue)
+
+		if !ok || len(array.Value) < len(pattern.Items) || pattern.Rest == nil && len(array.Value) != len(pattern.Items) {
+			return false, nil
+		}
+
+		if matched, err := e.matchItems(pattern.Items, array.Value, scope, env); !matched {
+			return false, err
+		}
+
+		if pattern.Rest != nil {
+			rest := make([]values.RuntimeValue, len(array.Value)-len(pattern.Items))
+			copy(rest, array.Value[len(pattern.Items):])
+			return e.MatchPatternIn(*pattern.Rest, &values.ArrayValue{Value: rest}, scope, env)
+		}
+		return true, nil
+
+	case parser.PatternDictionary:
+		fields, ok := patternFields(value)
+
+		if !ok {
+			return false, nil
+		}
+		return e.matchKeys(pattern, fields, scope, env)
+
+	case parser.PatternEnum:
+		expected := scope.PatternValue(pattern)
+
+		if expected.GetType() == values.ErrorType {
+			return false, expected
+		}
+
+		member, ok := expected.(values.EnumMemberValue)
+
+		if !ok || member.Fields == nil || member.Payload != nil {
+			return false, e.Panic(values.TypeError, "Enum patterns need a member that takes a payload, not "+expected.GetString(), pattern.Line, pattern.Column, env)
+		}
+
+		if len(member.Fields) != len(pattern.Items) {
+			return false, e.Panic(values.TypeError, "The payload of "+member.Enum.Name+"."+member.Name+" has "+strconv.Itoa(len(member.Fields))+" fields but the pattern has "+strconv.Itoa(len(pattern.Items)), pattern.Line, pattern.Column, env)
+		}
+
+		actual, ok := value.(values.EnumMemberValue)
+
+		if !ok || !actual.Is(member) || actual.Payload == nil {
+			return false, nil
+		}
+		return e.matchItems(pattern.Items, actual.Payload, scope, env)
+
+	case parser.PatternObject:
+		structValue := scope.PatternValue(pattern)
+
+		if structValue.GetType() == values.ErrorType {
+			return false, structValue
+		}
+
+		object, ok := value.(*values.ObjectValue)
+
+		switch expected := structValue.(type) {
+		case values.StructValue:
+			// Objects of structs that inherit from it match too
+			ok = ok && object.Struct.Is(expected)
+		case values.InterfaceValue:
+			ok = ok && expected.ImplementedBy(object)
+		default:
+			return false, e.Panic(values.TypeError, "'"+pattern.Name+"' is not a struct or an interface", pattern.Line, pattern.Column, env)
+		}
+
+		if !ok {
+			return false, nil
+		}
+		return e.matchKeys(pattern, publicFields(object), scope, env)
+	}
+
+	return false, nil
+}
+
+// Matches each pattern with the value at the same position
+func (e Evaluator) matchItems(patterns []parser.Pattern, items []values.RuntimeValue, scope PatternScope, env *environment.Environment) (bool, values.RuntimeValue) {
+	for i, item := range patterns {
+		if matched, err := e.MatchPatternIn(item, items[i], scope, env); !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Matches the pattern of each key with its value, all the keys must exist
+func (e Evaluator) matchKeys(pattern parser.Pattern, fields map[string]values.RuntimeValue, scope PatternScope, env *environment.Environment) (bool, values.RuntimeValue) {
+	for i, key := range pattern.Keys {
+		value, exists := fields[key]
+
+		if !exists {
+			return false, nil
+		}
+
+		if matched, err := e.MatchPatternIn(pattern.Items[i], value, scope, env); !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Dictionary patterns take the keys of dictionaries, the public fields of objects and the payload of enum members
+func patternFields(value values.RuntimeValue) (map[string]values.RuntimeValue, bool) {
+	switch value := value.(type) {
+	case *values.DictionaryValue:
+		return value.Value, true
+	case *values.ObjectValue:
+		return publicFields(value), true
+	case values.EnumMemberValue:
+		fields := make(map[string]values.RuntimeValue, len(value.Payload))
+		for i, field := range value.Payload {
+			fields[value.Fields[i]] = field
+		}
+		return fields, value.Payload != nil
+	}
+	return nil, false
+}
+
+// Patterns can not take the private fields of an object
+func publicFields(object *values.ObjectValue) map[string]values.RuntimeValue {
+	fields := make(map[string]values.RuntimeValue, len(object.Value))
+	for key, value := range object.Value {
+		if !values.IsPrivate(key) {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// Declares the names of a var or for-in pattern, failing when the value has another shape
+func (e Evaluator) Destructure(pattern parser.Pattern, value values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+	return e.DestructureIn(pattern, value, envScope{e, env}, line, column, env)
+}
+
+// Like Destructure, with the values and the names of the pattern in the given scope
+func (e Evaluator) DestructureIn(pattern parser.Pattern, value values.RuntimeValue, scope PatternScope, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	matched, err := e.MatchPatternIn(pattern, value, scope, env)
+
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return e.Panic(values.TypeError, "Can not destructure the value, "+mismatch(pattern, value), line, column, env)
+	}
+	return nil
+}
+
+// Explains the first part of the value that does not match the pattern
+func mismatch(pattern parser.Pattern, value values.RuntimeValue) string {
+
+	switch pattern.Kind {
+	case parser.PatternArray:
+		array, ok := value.(*values.ArrayValue)
+		expected := strconv.Itoa(len(pattern.Items))
+
+		if !ok {
+			return "expected an array but found " + typeName(value)
+		}
+
+		found := strconv.Itoa(len(array.Value))
+
+		if pattern.Rest == nil && len(array.Value) != len(pattern.Items) {
+			return "expected an array of " + expected + " elements but found " + found
+		}
+
+		if len(array.Value) < len(pattern.Items) {
+			return "expected an array of at least " + expected + " elements but found " + found
+		}
+
+		for i, item := range pattern.Items {
+			if reason := mismatch(item, array.Value[i]); reason != "" {
+				return reason
+			}
+		}
+
+	case parser.PatternDictionary, parser.PatternObject:
+		fields, ok := patternFields(value)
+
+		if pattern.Kind == parser.PatternObject && !inherits(value, pattern.Name) {
+			return "expected an object of " + pattern.Name + " but found " + typeName(value)
+		}
+
+		if !ok {
+			return "expected a dictionary or an object but found " + typeName(value)
+		}
+
+		for i, key := range pattern.Keys {
+			field, exists := fields[key]
+
+			if !exists {
+				return "the key '" + key + "' does not exist in " + typeName(value)
+			}
+
+			if reason := mismatch(pattern.Items[i], field); reason != "" {
+				return reason
+			}
+		}
+
+	case parser.PatternEnum:
+		member, ok := value.(values.EnumMemberValue)
+
+		if ok && len(member.Payload) == len(pattern.Items) {
+			for i, item := range pattern.Items {
+				if reason := mismatch(item, member.Payload[i]); reason != "" {
+					return reason
+				}
+			}
+		}
+		return "the value " + value.GetString() + " does not match"
+
+	case parser.PatternLiteral, parser.PatternAlternative:
+		return "the value " + value.GetString() + " does not match"
+	}
+
+	return ""
+}
+
+// Checks by name if the value is an object of the struct or of one that inherits from it
+// Interfaces are found by name only when the struct declares them with implements
+func inherits(value values.RuntimeValue, name string) bool {
+	object, ok := value.(*values.ObjectValue)
+
+	if !ok {
+		return false
+	}
+
+	for actual := &object.Struct; actual != nil; actual = actual.Parent {
+		if actual.Name == name {
+			return true
+		}
+		for _, iface := range actual.Interfaces {
+			if iface.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,1083 @@
+package evruntime
+
+import (
+	environment "evie/env"
+	"evie/lib"
+	"evie/native"
+	"evie/parser"
+	"evie/resolver"
+	"evie/values"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Operations over already evaluated values
+// They are shared by the tree walker and the bytecode vm so both engines behave the same
+
+// Applies an arithmetic operator
+func (e Evaluator) BinaryOperation(operator parser.OperatorType, left values.RuntimeValue, right values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	type1 := left.GetType()
+	type2 := right.GetType()
+
+	if type1 == values.ErrorType {
+		return left
+	}
+	if type2 == values.ErrorType {
+		return right
+	}
+
+	// Structs opt into the arithmetic operators with methods like Vector -> __add(other)
+	if object, ok := left.(*values.ObjectValue); ok {
+		if name, overloadable := operatorMethods[operator]; overloadable {
+			if result, found := e.callOperator(object, name, right, line, column, env); found {
+				return result
+			}
+		}
+	}
+
+	equalTypes := type1 == type2
+
+	if !equalTypes {
+		return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), line, column, env)
+	}
+
+	if operator == parser.OperatorAdd {
+
+		if type1 == values.NumberType {
+			return values.NumberValue{Value: left.GetNumber() + right.GetNumber()}
+		} else if type1 == values.StringType {
+			return values.StringValue{Value: left.(values.StringValue).Value + right.(values.StringValue).Value}
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator + with type "+type1.String(), line, column, env)
+		}
+
+	} else if operator == parser.OperatorSubtract {
+
+		if type1 == values.NumberType {
+			val := left.(values.NumberValue)
+			val.Value = val.Value - right.(values.NumberValue).Value
+			return val
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator - with type "+type1.String(), line, column, env)
+		}
+	} else if operator == parser.OperatorMultiply {
+
+		if type1 == values.NumberType {
+			return values.NumberValue{Value: left.(values.NumberValue).Value * right.(values.NumberValue).Value}
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator * with type "+type1.String(), line, column, env)
+		}
+	} else if operator == parser.OperatorDivide {
+
+		if type1 == values.NumberType {
+			if right.(values.NumberValue).Value == 0.0 {
+				return e.Panic(values.ZeroDivisionError, "Division by zero", line, column, env)
+			}
+			return values.NumberValue{Value: left.(values.NumberValue).Value / right.(values.NumberValue).Value}
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator / with type "+type1.String(), line, column, env)
+		}
+	} else if operator == parser.OperatorModulo {
+
+		if type1 == values.NumberType {
+			if right.(values.NumberValue).Value == 0.0 {
+				return e.Panic(values.ZeroDivisionError, "Modulo by zero", line, column, env)
+			}
+			return values.NumberValue{Value: math.Mod(left.(values.NumberValue).Value, right.(values.NumberValue).Value)}
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator % with type "+type1.String(), line, column, env)
+		}
+	} else if operator == parser.OperatorPower {
+
+		if type1 == values.NumberType {
+			return values.NumberValue{Value: math.Pow(left.(values.NumberValue).Value, right.(values.NumberValue).Value)}
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator ** with type "+type1.String(), line, column, env)
+		}
+	} else if operator == parser.OperatorIntDivide {
+
+		if type1 == values.NumberType {
+			if right.(values.NumberValue).Value == 0.0 {
+				return e.Panic(values.ZeroDivisionError, "Division by zero", line, column, env)
+			}
+			return values.NumberValue{Value: math.Floor(left.(values.NumberValue).Value / right.(values.NumberValue).Value)}
+		} else {
+			return e.Panic(values.RuntimeError, "Cant use operator ~/ with type "+type1.String(), line, column, env)
+		}
+	} else if symbol, ok := bitwiseSymbols[operator]; ok {
+
+		a, okLeft := integerValue(left)
+		b, okRight := integerValue(right)
+
+		if !okLeft || !okRight {
+			return e.Panic(values.TypeError, "Operator "+symbol+" only works with integer numbers up to 2^53", line, column, env)
+		}
+
+		var result int64
+
+		switch operator {
+		case parser.OperatorBitAnd:
+			result = a & b
+		case parser.OperatorBitOr:
+			result = a | b
+		case parser.OperatorBitXor:
+			result = a ^ b
+		case parser.OperatorShiftLeft, parser.OperatorShiftRight:
+			if b < 0 {
+				return e.Panic(values.TypeError, "Negative shift count "+strconv.FormatInt(b, 10), line, column, env)
+			}
+			if operator == parser.OperatorShiftRight {
+				result = a >> b
+			} else if b >= 64 || (a<<b)>>b != a {
+				return e.Panic(values.TypeError, "Result of "+symbol+" is too big for an integer", line, column, env)
+			} else {
+				result = a << b
+			}
+		}
+
+		if result > maxSafeInteger || result < -maxSafeInteger {
+			return e.Panic(values.TypeError, "Result of "+symbol+" is too big for an integer", line, column, env)
+		}
+		return values.NumberValue{Value: float64(result)}
+	}
+
+	return e.Panic(values.RuntimeError, "Unknown operator", line, column, env)
+}
+
+// Methods that overload the arithmetic operators
+var operatorMethods = map[parser.OperatorType]string{
+	parser.OperatorAdd:      "__add",
+	parser.OperatorSubtract: "__sub",
+	parser.OperatorMultiply: "__mul",
+	parser.OperatorDivide:   "__div",
+	parser.OperatorModulo:   "__mod",
+	parser.OperatorPower:    "__pow",
+}
+
+// Calls the method of the object that overloads an operator, found is false when its struct does not have it
+func (e Evaluator) callOperator(object *values.ObjectValue, name string, argument values.RuntimeValue, line int, column int, env *environment.Environment) (result values.RuntimeValue, found bool) {
+
+	method, exists := object.Struct.Method(name)
+	fn, ok := method.(values.FunctionValue)
+
+	if !exists || !ok {
+		return nil, false
+	}
+
+	// Each engine runs the functions it created
+	fn.StructObjRef = object
+	result, _ = fn.Evaluator.ExecuteCallback(fn, []interface{}{argument}).(values.RuntimeValue)
+
+	if result == nil {
+		return values.NothingValue{}, true
+	}
+
+	// Errors raised inside the method already have their position
+	if err, isError := result.(values.ErrorValue); isError && err.Object == nil {
+		return e.Panic(err.ErrorType, err.Value, line, column, env), true
+	}
+
+	return result, true
+}
+
+// Compares an object with the __eq and __lt methods of its struct, found is false when it does not have the one needed
+// The other comparisons come from those two, like a >= b being not a.__lt(b)
+func (e Evaluator) compareObject(operator parser.OperatorType, object *values.ObjectValue, right values.RuntimeValue, line int, column int, env *environment.Environment) (values.RuntimeValue, bool) {
+
+	if isEquality(operator) {
+		equal, found := e.callOperator(object, "__eq", right, line, column, env)
+
+		if !found {
+			return nil, false
+		}
+		if equal = e.operatorResult(equal, object, "__eq", line, column, env); equal.GetType() == values.ErrorType {
+			return equal, true
+		}
+		return values.BoolValue{Value: equal.GetBool() == (operator == parser.OperatorEquals)}, true
+	}
+
+	less, found := e.callOperator(object, "__lt", right, line, column, env)
+
+	if !found {
+		return nil, false
+	}
+	if less = e.operatorResult(less, object, "__lt", line, column, env); less.GetType() == values.ErrorType {
+		return less, true
+	}
+
+	switch operator {
+	case parser.OperatorLessThan:
+		return less, true
+	case parser.OperatorGreaterOrEqThan:
+		return values.BoolValue{Value: !less.GetBool()}, true
+	}
+
+	// > and <= also need to know if both are equal, without __eq they are compared by their fields like ==
+	equal, hasEq := e.compareObject(parser.OperatorEquals, object, right, line, column, env)
+
+	if !hasEq {
+		same, _ := values.Equals(object, right)
+		equal = values.BoolValue{Value: same}
+	} else if equal.GetType() == values.ErrorType {
+		return equal, true
+	}
+
+	if operator == parser.OperatorLessOrEqThan {
+		return values.BoolValue{Value: less.GetBool() || equal.GetBool()}, true
+	}
+	return values.BoolValue{Value: !less.GetBool() && !equal.GetBool()}, true
+}
+
+// The comparison methods must return a boolean
+func (e Evaluator) operatorResult(result values.RuntimeValue, object *values.ObjectValue, name string, line int, column int, env *environment.Environment) values.RuntimeValue {
+	if result.GetType() == values.ErrorType || result.GetType() == values.BoolType {
+		return result
+	}
+	return e.Panic(values.TypeError, "The method "+name+" of "+object.Struct.Name+" must return a boolean, not "+result.GetType().String(), line, column, env)
+}
+
+var bitwiseSymbols = map[parser.OperatorType]string{
+	parser.OperatorBitAnd:     "&",
+	parser.OperatorBitOr:      "|",
+	parser.OperatorBitXor:     "^",
+	parser.OperatorShiftLeft:  "<<",
+	parser.OperatorShiftRight: ">>",
+}
+
+// Biggest integer that a number keeps without losing precision
+const maxSafeInteger = 1 << 53
+
+// Numbers without decimals up to maxSafeInteger, the only ones accepted by the bitwise operators
+func integerValue(value values.RuntimeValue) (int64, bool) {
+	number, ok := value.(values.NumberValue)
+	if !ok || number.Value != math.Trunc(number.Value) || math.Abs(number.Value) > maxSafeInteger {
+		return 0, false
+	}
+	return int64(number.Value), true
+}
+
+// Applies 'and' or 'or', both sides are converted to booleans
+func (e Evaluator) LogicOperation(operator parser.OperatorType, left values.RuntimeValue, right values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if left.GetType() == values.ErrorType {
+		return left
+	}
+	if right.GetType() == values.ErrorType {
+		return right
+	}
+
+	if operator != parser.OperatorAnd && operator != parser.OperatorOr {
+		return values.ErrorValue{Value: "Unknown operator"}
+	}
+
+	leftValue, err := e.EvaluateImplicitBoolConversion(left)
+
+	if err != nil {
+		return e.Panic(values.RuntimeError, err.Error(), line, column, env)
+	}
+
+	rightValue, err := e.EvaluateImplicitBoolConversion(right)
+
+	if err != nil {
+		return e.Panic(values.RuntimeError, err.Error(), line, column, env)
+	}
+
+	if operator == parser.OperatorAnd {
+		return values.BoolValue{Value: leftValue && rightValue}
+	}
+
+	return values.BoolValue{Value: leftValue || rightValue}
+}
+
+// Applies a comparison operator
+func (e Evaluator) ComparisonOperation(operator parser.OperatorType, left values.RuntimeValue, right values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	type1 := left.GetType()
+	type2 := right.GetType()
+
+	if type1 == values.ErrorType {
+		return left
+	}
+	if type2 == values.ErrorType {
+		return right
+	}
+
+	var symbol string
+
+	switch operator {
+	case parser.OperatorEquals:
+		symbol = "=="
+	case parser.OperatorNotEquals:
+		symbol = "!="
+	case parser.OperatorGreaterThan:
+		symbol = ">"
+	case parser.OperatorLessThan:
+		symbol = "<"
+	case parser.OperatorLessOrEqThan:
+		symbol = "<="
+	case parser.OperatorGreaterOrEqThan:
+		symbol = ">="
+	case parser.OperatorIs:
+		return e.IsInstance(left, right, line, column, env)
+	default:
+		return values.ErrorValue{Value: "Unknown operator"}
+	}
+
+	if object, ok := left.(*values.ObjectValue); ok {
+		if result, found := e.compareObject(operator, object, right, line, column, env); found {
+			return result
+		}
+	}
+
+	// Anything can be compared with Nothing
+	if type1 != type2 && !(isEquality(operator) && (type1 == values.NothingType || type2 == values.NothingType)) {
+		return e.Panic(values.RuntimeError, "Type mismatch: "+type1.String()+" and "+type2.String(), line, column, env)
+	}
+
+	if isEquality(operator) {
+		equal, ok := values.Equals(left, right)
+		if !ok {
+			return e.Panic(values.RuntimeError, "Cant use operator "+symbol+" with type "+type1.String(), line, column, env)
+		}
+		return values.BoolValue{Value: equal == (operator == parser.OperatorEquals)}
+	}
+
+	if type1 != values.NumberType {
+		return e.Panic(values.RuntimeError, "Operator "+symbol+" only can be used with numbers, not with type "+type1.String(), line, column, env)
+	}
+
+	l := left.(values.NumberValue).Value
+	r := right.(values.NumberValue).Value
+
+	switch operator {
+	case parser.OperatorGreaterThan:
+		return values.BoolValue{Value: l > r}
+	case parser.OperatorLessThan:
+		return values.BoolValue{Value: l < r}
+	case parser.OperatorLessOrEqThan:
+		return values.BoolValue{Value: l <= r}
+	default:
+		return values.BoolValue{Value: l >= r}
+	}
+}
+
+// Checks if the value is an object of the struct or of a struct that inherits from it
+// With an interface it checks if the object has all of its methods
+func (e Evaluator) IsInstance(value values.RuntimeValue, structValue values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if iface, ok := structValue.(values.InterfaceValue); ok {
+		_, isObject := value.(*values.ObjectValue)
+		return values.BoolValue{Value: isObject && iface.ImplementedBy(value)}
+	}
+
+	expected, ok := structValue.(values.StructValue)
+
+	if !ok {
+		return e.Panic(values.TypeError, "The right side of 'is' must be a struct or an interface, not "+typeName(structValue), line, column, env)
+	}
+
+	object, ok := value.(*values.ObjectValue)
+
+	return values.BoolValue{Value: ok && object.Struct.Is(expected)}
+}
+
+func isEquality(operator parser.OperatorType) bool {
+	return operator == parser.OperatorEquals || operator == parser.OperatorNotEquals
+}
+
+// Applies '-' or 'not' to a value
+func (e Evaluator) UnaryOperation(operator string, value values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if value.GetType() == values.ErrorType {
+		return value
+	}
+
+	if operator == "-" && value.GetType() == values.NumberType {
+		return values.NumberValue{Value: -value.(values.NumberValue).Value}
+	} else if operator == "~" {
+		integer, ok := integerValue(value)
+		if !ok {
+			return e.Panic(values.TypeError, "Operator ~ only works with integer numbers up to 2^53", line, column, env)
+		}
+		if ^integer < -maxSafeInteger {
+			return e.Panic(values.TypeError, "Result of ~ is too big for an integer", line, column, env)
+		}
+		return values.NumberValue{Value: float64(^integer)}
+	} else if operator == "not" {
+		res, err := e.EvaluateImplicitBoolConversion(value)
+
+		if err != nil {
+			return e.Panic(values.RuntimeError, err.Error(), line, column, env)
+		}
+		return values.BoolValue{Value: !res}
+	}
+
+	return e.Panic(values.RuntimeError, "Unknown operator '"+operator+"'", line, column, env)
+}
+
+// Joins the parts of an interpolated string, converted like the string function does
+func (e Evaluator) Interpolate(parts []values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	var text strings.Builder
+
+	for _, part := range parts {
+		if object, ok := part.(*values.ObjectValue); ok {
+			part = object.ToString()
+		}
+
+		if err, ok := part.(values.ErrorValue); ok {
+			if err.Object != nil {
+				return err
+			}
+			return e.Panic(err.ErrorType, err.Value, line, column, env)
+		}
+
+		text.WriteString(part.GetString())
+	}
+
+	return values.StringValue{Value: text.String()}
+}
+
+// Builds the range from..to, step is nil when it is not given
+func (e Evaluator) MakeRange(from values.RuntimeValue, to values.RuntimeValue, step values.RuntimeValue, inclusive bool, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if step == nil {
+		step = values.NumberValue{Value: 1}
+	}
+
+	if from.GetType() != values.NumberType || to.GetType() != values.NumberType || step.GetType() != values.NumberType {
+		return e.Panic(values.TypeError, "Ranges only work with numbers", line, column, env)
+	}
+
+	if step.GetNumber() == 0 {
+		return e.Panic(values.InvalidArgumentError, "The step of a range can not be 0", line, column, env)
+	}
+
+	return values.RangeValue{From: from.GetNumber(), To: to.GetNumber(), Step: step.GetNumber(), Inclusive: inclusive}
+}
+
+// Returns the iterator of a for in loop over the value
+// Objects are iterable if their struct has an iter method, that gives the iterable to use, or a next method
+func (e Evaluator) GetIterator(value values.RuntimeValue, line int, column int, env *environment.Environment) (values.Iterator, values.RuntimeValue) {
+
+	if object, ok := value.(*values.ObjectValue); ok {
+
+		if _, ok := object.Struct.Methods["iter"]; ok {
+			value = callMethod(object, "iter")
+
+			if value.GetType() == values.ErrorType {
+				return nil, value
+			}
+
+			// The iter method of an object that has next usually returns the object itself
+			if inner, ok := value.(*values.ObjectValue); ok {
+				if _, ok := inner.Struct.Methods["next"]; !ok {
+					return nil, e.Panic(values.TypeError, "The iter method of "+object.Struct.Name+" must return an iterable value", line, column, env)
+				}
+				return &objectIterator{object: inner}, nil
+			}
+		} else if _, ok := object.Struct.Methods["next"]; ok {
+			return &objectIterator{object: object}, nil
+		}
+	}
+
+	if iterable, ok := value.(values.Iterable); ok {
+		return iterable.Iterate(), nil
+	}
+
+	return nil, e.Panic(values.TypeError, "Type "+typeName(value)+" is not iterable", line, column, env)
+}
+
+// Walks an object calling its next method until it returns nothing
+type objectIterator struct {
+	object *values.ObjectValue
+	index  int
+}
+
+func (it *objectIterator) Next() (values.RuntimeValue, values.RuntimeValue, bool) {
+
+	value := callMethod(it.object, "next")
+
+	if value.GetType() == values.NothingType {
+		return nil, nil, false
+	}
+
+	it.index++
+	return value, values.NumberValue{Value: float64(it.index - 1)}, true
+}
+
+// Calls a method without arguments from the runtime
+func callMethod(object *values.ObjectValue, name string) values.RuntimeValue {
+
+	method, _ := object.GetProp(name)
+
+	var result interface{}
+
+	switch fn := method.(type) {
+	case values.FunctionValue:
+		result = fn.Evaluator.ExecuteCallback(fn, nil)
+	case values.NativeFunctionValue:
+		result = fn.Value(nil)
+	}
+
+	if value, ok := result.(values.RuntimeValue); ok && value != nil {
+		return value
+	}
+	return values.NothingValue{}
+}
+
+// The struct name for objects, the type for the rest
+func typeName(value values.RuntimeValue) string {
+	switch value := value.(type) {
+	case *values.ObjectValue:
+		return value.Struct.Name
+	case values.EnumMemberValue:
+		return value.Enum.Name
+	}
+	return value.GetType().String()
+}
+
+// Returns the element at the given index of an array, string or dictionary
+func (e Evaluator) IndexValue(identifier values.RuntimeValue, index values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	// The index can be a number for arrays or a string for dictionaries
+	// In both cases it is treated as a string and converted to int if needed
+	var i string = index.GetString()
+
+	switch identifier.GetType() {
+	case values.ObjectType:
+		// Structs opt into indexing with a __index(key) method
+		if result, found := e.callOperator(identifier.(*values.ObjectValue), "__index", index, line, column, env); found {
+			return result
+		}
+		return e.Panic(values.RuntimeError, "Only arrays and dictionaries can be accessed by index", line, column, env)
+	case values.ArrayType:
+		val := identifier.(*values.ArrayValue)
+		iToInt, _ := strconv.Atoi(i)
+		if iToInt < 0 {
+			iToInt = len(val.Value) + iToInt
+		}
+		if iToInt >= len(val.Value) {
+			return e.Panic(values.InvalidIndexError, "Index "+i+" out of range", line, column, env)
+		}
+		return val.Value[iToInt]
+	case values.StringType:
+		val := identifier.(values.StringValue).Value
+		iToInt, _ := strconv.Atoi(i)
+		if iToInt >= len(val) {
+			return e.Panic(values.InvalidIndexError, "Index "+i+" out of range", line, column, env)
+		}
+		return values.StringValue{Value: string(val[iToInt])}
+	case values.DictionaryType:
+		val := identifier.(*values.DictionaryValue)
+		item, exists := val.Value[i]
+
+		if !exists {
+			return e.Panic(values.RuntimeError, "Undefined key '"+i, line, column, env)
+		}
+
+		return item
+
+	default:
+		return e.Panic(values.RuntimeError, "Only arrays and dictionaries can be accessed by index", line, column, env)
+	}
+}
+
+// Sets the element at the given index of an array or dictionary
+func (e Evaluator) AssignIndex(identifier values.RuntimeValue, index values.RuntimeValue, right values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if identifier.GetType() == values.ArrayType {
+
+		if index.GetType() != values.NumberType {
+			return e.Panic(values.RuntimeError, "Invalid array index", line, column, env)
+		}
+
+		array := identifier.(*values.ArrayValue)
+
+		finalIndex := int(index.GetNumber())
+
+		if finalIndex < 0 {
+			finalIndex = len(array.Value) + finalIndex
+		}
+
+		if finalIndex < 0 || finalIndex >= len(array.Value) {
+			return e.Panic(values.RuntimeError, "Invalid array index or out of bounds with index: "+fmt.Sprint(index.GetNumber()), line, column, env)
+		}
+
+		array.Value[finalIndex] = right
+	} else if identifier.GetType() == values.DictionaryType {
+
+		if index.GetType() != values.StringType {
+			return e.Panic(values.RuntimeError, "Invalid dictionary key", line, column, env)
+		}
+
+		identifier.(*values.DictionaryValue).Value[index.GetString()] = right
+	}
+
+	return right
+}
+
+// Returns a property or method of a value, owner is the struct of the method where it is used
+func (e Evaluator) MemberValue(value values.RuntimeValue, member string, owner *values.StructValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if err := e.checkPrivate(value, member, owner, line, column, env); err != nil {
+		return err
+	}
+
+	prop, err := value.GetProp(member)
+
+	if err != nil {
+		return e.Panic(values.RuntimeError, err.Error(), line, column, env)
+	}
+
+	return prop
+}
+
+// Private members can only be used in the methods of the struct that has them or of the structs it inherits from
+// Modules never give their private names
+func (e Evaluator) checkPrivate(value values.RuntimeValue, member string, owner *values.StructValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if !values.IsPrivate(member) {
+		return nil
+	}
+
+	var structValue values.StructValue
+
+	switch value := value.(type) {
+	case *values.ObjectValue:
+		structValue = value.Struct
+	case values.StructValue:
+		structValue = value
+	case values.NamespaceValue:
+		return e.Panic(values.PropertyError, "'"+member+"' is private to its module", line, column, env)
+	default:
+		return nil
+	}
+
+	// Another struct with the same name, like one of another module, is not the owner
+	if owner != nil && structValue.Is(*owner) {
+		return nil
+	}
+
+	return e.Panic(values.PropertyError, "'"+member+"' is private to "+structValue.Name+" and can only be used in its methods", line, column, env)
+}
+
+// Sets a property of an object
+func (e Evaluator) AssignMember(value values.RuntimeValue, member string, right values.RuntimeValue, owner *values.StructValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if err := e.checkPrivate(value, member, owner, line, column, env); err != nil {
+		return err
+	}
+
+	// Only the module itself can change its variables
+	if value.GetType() == values.NamespaceType {
+		return e.Panic(values.RuntimeError, "'"+member+"' belongs to an imported module and can not be assigned", line, column, env)
+	}
+
+	// Static members are constants
+	if structValue, ok := value.(values.StructValue); ok {
+		return e.Panic(values.RuntimeError, "'"+member+"' is a static member of "+structValue.Name+" and can not be assigned", line, column, env)
+	}
+
+	if value.GetType() != values.ObjectType {
+		return e.Panic(values.RuntimeError, "Invalid object assignment", line, column, env)
+	}
+
+	value.(*values.ObjectValue).Value[member] = right
+
+	return right
+}
+
+// Slices an array or a string, end is nil when the slice has no upper bound
+func (e Evaluator) SliceValue(value values.RuntimeValue, init values.RuntimeValue, end values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if value.GetType() != values.ArrayType && value.GetType() != values.StringType {
+		return e.Panic(values.RuntimeError, "Expected array or string", line, column, env)
+	}
+
+	fn, err := value.GetProp("slice")
+
+	if err != nil {
+		if value.GetType() == values.ArrayType {
+			return e.Panic(values.PropertyError, err.Error(), line, column, env)
+		}
+		return e.Panic(values.RuntimeError, err.Error(), line, column, env)
+	}
+
+	var ret values.RuntimeValue
+
+	if end == nil {
+		ret = fn.(values.NativeFunctionValue).Value([]values.RuntimeValue{init})
+	} else {
+		ret = fn.(values.NativeFunctionValue).Value([]values.RuntimeValue{init, end})
+	}
+
+	if ret.GetType() == values.ErrorType {
+		return e.Panic(ret.(values.ErrorValue).ErrorType, ret.(values.ErrorValue).Value, line, column, env)
+	}
+
+	return ret
+}
+
+// Creates a new struct value from its declaration, parent is nil when it does not inherit from another struct
+// defaults has the function that gives the default value of each property, if it has one, and statics the value of each static member
+// interfaces has the value of each name after implements
+func (e Evaluator) NewStruct(node parser.StructDeclarationNode, parent values.RuntimeValue, defaults []values.RuntimeValue, statics []values.RuntimeValue, interfaces []values.RuntimeValue, env *environment.Environment) values.RuntimeValue {
+
+	structValue := values.StructValue{
+		Name:       node.Name,
+		Properties: node.Properties,
+		Methods:    make(map[string]values.RuntimeValue),
+		Defaults:   make(map[string]values.RuntimeValue),
+		Statics:    make(map[string]values.RuntimeValue),
+	}
+
+	if parent != nil {
+
+		if parent.GetType() == values.ErrorType {
+			return parent
+		}
+
+		parentStruct, ok := parent.(values.StructValue)
+
+		if !ok {
+			return e.Panic(values.TypeError, "'"+node.Parent+"' is not a struct, "+node.Name+" can not inherit from it", node.Line, node.Column, env)
+		}
+
+		// The inherited properties come first, declaring one of them again keeps only one
+		properties := append([]string{}, parentStruct.Properties...)
+
+		for _, prop := range node.Properties {
+			inherited := false
+			for _, parentProp := range parentStruct.Properties {
+				inherited = inherited || prop == parentProp
+			}
+			if !inherited {
+				properties = append(properties, prop)
+			}
+		}
+
+		for prop, value := range parentStruct.Defaults {
+			structValue.Defaults[prop] = value
+		}
+
+		structValue.Properties = properties
+		structValue.Parent = &parentStruct
+	}
+
+	for i, prop := range node.Properties {
+		if fn, ok := defaults[i].(values.FunctionValue); ok {
+			structValue.Defaults[prop] = fn
+		}
+	}
+
+	for i, static := range node.Statics {
+		structValue.Statics[static.Name] = statics[i]
+	}
+
+	for i, value := range interfaces {
+
+		if value.GetType() == values.ErrorType {
+			return value
+		}
+
+		iface, ok := value.(values.InterfaceValue)
+
+		if !ok {
+			return e.Panic(values.TypeError, "'"+node.Interfaces[i].Value+"' is not an interface, "+node.Name+" can not implement it", node.Line, node.Column, env)
+		}
+
+		structValue.Interfaces = append(structValue.Interfaces, iface)
+	}
+
+	return structValue
+}
+
+// Creates the value of an interface from its declaration
+func NewInterface(node parser.InterfaceDeclarationNode) values.InterfaceValue {
+	iface := values.InterfaceValue{Name: node.Name}
+
+	for _, method := range node.Methods {
+		iface.Methods = append(iface.Methods, values.InterfaceMethod{Name: method.Name, Arity: len(method.Parameters)})
+	}
+
+	return iface
+}
+
+// Creates the value of an enum from its declaration
+func NewEnum(node parser.EnumDeclarationNode) *values.EnumValue {
+	enum := &values.EnumValue{Name: node.Name}
+
+	for _, member := range node.Members {
+		enum.Add(member.Name, member.Fields)
+	}
+
+	return enum
+}
+
+// Calls a member of an enum declared with fields, like Result.Ok(5), giving it its payload
+func (e Evaluator) NewEnumMember(member values.EnumMemberValue, args []values.RuntimeValue, named map[string]values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	name := member.Enum.Name + "." + member.Name
+
+	if member.Fields == nil {
+		return e.Panic(values.TypeError, name+" has no payload and can not be called", line, column, env)
+	}
+
+	if member.Payload != nil {
+		return e.Panic(values.TypeError, name+" already has its payload and can not be called again", line, column, env)
+	}
+
+	if len(args)+len(named) != len(member.Fields) {
+		return e.Panic(values.InvalidArgumentError, name+" expects "+arguments(len(member.Fields))+" but got "+strconv.Itoa(len(args)+len(named)), line, column, env)
+	}
+
+	member.Payload = make([]values.RuntimeValue, len(member.Fields))
+	copy(member.Payload, args)
+
+	// Fields can also be given by name, like Result.Err(message: "failed")
+	for key, value := range named {
+		found := false
+		for i, field := range member.Fields {
+			if field == key && member.Payload[i] == nil {
+				member.Payload[i], found = value, true
+			}
+		}
+		if !found {
+			return e.Panic(values.InvalidArgumentError, name+" has no field '"+key+"' or it was already given", line, column, env)
+		}
+	}
+
+	return member
+}
+
+// Methods are added after the struct is declared, so the interfaces it implements are checked when an object is created
+func (e Evaluator) checkInterfaces(structValue values.StructValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+	for actual := &structValue; actual != nil; actual = actual.Parent {
+		for _, iface := range actual.Interfaces {
+			if reason := iface.Unmet(structValue); reason != "" {
+				return e.Panic(values.TypeError, "Struct "+structValue.Name+" does not implement "+iface.Name+", "+reason, line, column, env)
+			}
+		}
+	}
+	return nil
+}
+
+// Creates an object of the given struct, every property not given takes its default value or nothing
+// Private properties can only be given in the methods of the struct, owner is the struct of the method where it is created
+func (e Evaluator) NewObject(structLup values.RuntimeValue, properties map[string]values.RuntimeValue, owner *values.StructValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	// If when evaluating the struct it is not a struct, return error
+	if _, ok := structLup.(values.StructValue); !ok {
+		return e.Panic(values.RuntimeError, "You only can initialize objects of structs, not of "+structLup.GetType().String(), line, column, env)
+	}
+
+	if err := e.checkInterfaces(structLup.(values.StructValue), line, column, env); err != nil {
+		return err
+	}
+
+	val := values.ObjectValue{}
+
+	val.Struct = structLup.(values.StructValue)
+	val.Value = make(map[string]values.RuntimeValue)
+
+	// Initialize each property of the object with nothing
+	for _, prop := range val.Struct.Properties {
+		val.Value[prop] = values.NothingValue{}
+	}
+
+	// Every given property must be declared in the struct
+	for key, value := range properties {
+
+		if err := e.checkPrivate(&val, key, owner, line, column, env); err != nil {
+			return err
+		}
+
+		if _, ok := val.Value[key]; !ok {
+			return e.Panic(values.RuntimeError, "Unknown property "+key, line, column, env)
+		}
+
+		val.Value[key] = value
+	}
+
+	for _, prop := range val.Struct.Properties {
+		if _, given := properties[prop]; given {
+			continue
+		}
+
+		fn, ok := val.Struct.Defaults[prop].(values.FunctionValue)
+
+		if !ok {
+			continue
+		}
+
+		// Each engine runs the functions it created
+		value, _ := fn.Evaluator.ExecuteCallback(fn, nil).(values.RuntimeValue)
+
+		if value == nil {
+			continue
+		}
+
+		if value.GetType() == values.ErrorType {
+			return value
+		}
+
+		val.Value[prop] = value
+	}
+
+	return &val
+}
+
+// Creates the object of a call to a struct, like Person("Pedro", 24)
+// Also returns the init method bound to the object that must be called with the arguments, or nil if the struct has not
+func (e Evaluator) Construct(structValue values.RuntimeValue, arguments int, line int, column int, env *environment.Environment) (values.RuntimeValue, values.RuntimeValue) {
+
+	object := e.NewObject(structValue, nil, nil, line, column, env)
+
+	if object.GetType() == values.ErrorType {
+		return object, nil
+	}
+
+	method, exists := structValue.(values.StructValue).Method("init")
+	init, isFunction := method.(values.FunctionValue)
+
+	if !exists || !isFunction {
+		if arguments > 0 {
+			return e.Panic(values.InvalidArgumentError, "Struct "+structValue.(values.StructValue).Name+" has no init method to take arguments", line, column, env), nil
+		}
+		return object, nil
+	}
+
+	if init.Generator {
+		return e.Panic(values.TypeError, "The init method of "+structValue.(values.StructValue).Name+" can not yield values", line, column, env), nil
+	}
+
+	init.StructObjRef = object.(*values.ObjectValue)
+	return object, init
+}
+
+// Stores a function in the struct itself, it is called without an object like Person.create()
+func (e Evaluator) DeclareStaticMethod(structLup values.RuntimeValue, structName string, fn values.FunctionValue, name string, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if structLup.GetType() == values.ErrorType {
+		return structLup
+	}
+
+	if structLup.GetType() != values.StructType {
+		return e.Panic(values.TypeError, "Expected struct, got "+structLup.GetType().String(), line, column, env)
+	}
+
+	statics := structLup.(values.StructValue).Statics
+
+	// Built in structs are not declared by a script
+	if statics == nil {
+		return e.Panic(values.TypeError, "Static members can not be added to struct '"+structName+"'", line, column, env)
+	}
+
+	if _, exists := statics[name]; exists {
+		return e.Panic(values.RuntimeError, "Static member '"+name+"' already exists in struct '"+structName+"'", line, column, env)
+	}
+
+	owner := structLup.(values.StructValue)
+
+	fn.Name = structName + "." + name
+	fn.Owner = &owner
+	statics[name] = fn
+
+	return values.NothingValue{}
+}
+
+// Stores a function as a method of the struct declared with the given name
+func (e Evaluator) DeclareStructMethod(structLup values.RuntimeValue, structName string, fn values.FunctionValue, name string, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if structLup.GetType() == values.ErrorType {
+		return structLup
+	}
+
+	if structLup.GetType() != values.StructType {
+		return e.Panic(values.TypeError, "Expected struct, got "+structLup.GetType().String(), line, column, env)
+	}
+
+	methods := structLup.(values.StructValue).Methods
+
+	// Check if method already exists
+	if _, exists := methods[name]; exists {
+		return e.Panic(values.RuntimeError, "Method '"+name+"' already exists in struct '"+structName+"'", line, column, env)
+	}
+
+	owner := structLup.(values.StructValue)
+
+	fn.Name = structName + "." + name
+	fn.Struct = structName
+	fn.Super = owner.Parent
+	fn.Owner = &owner
+	methods[name] = fn
+
+	return values.NothingValue{}
+}
+
+// Loads a module into the environment under its alias
+// Files are parsed and handed to run, which evaluates them in the given environment
+func (e Evaluator) ImportModule(node parser.ImportNode, env *environment.Environment, run func(ast []parser.Stmt, moduleEnv *environment.Environment) values.RuntimeValue) values.RuntimeValue {
+
+	// Map std libraries load methods
+	libmap := lib.GetLibMap()
+
+	// Is a native library?
+	if val, ok := libmap[node.Path]; ok {
+		val(env)
+		return values.NothingValue{}
+	}
+
+	if _, ok := env.ImportChain[node.Path]; ok {
+		return e.Panic(values.CircularImportError, "Circular import with module: "+node.Path, node.Line, node.Column, env)
+	}
+
+	// Do not add .ev to modules ;)
+	path := node.Path + ".ev"
+
+	// Read file, parse and evaluate
+	content, err := os.ReadFile(e.RootPath + string(filepath.Separator) + path)
+
+	if err != nil {
+		return e.Panic(values.RuntimeError, "Can not read module "+node.Path+": "+err.Error(), node.Line, node.Column, env)
+	}
+
+	source := string(content)
+
+	ast, err := parser.Parse(source)
+
+	if err != nil {
+		return e.Panic(values.SyntaxError, "Syntax errors in module "+node.Path+":\n"+err.Error(), node.Line, node.Column, env)
+	}
+
+	// Create new environment for the module with the parent environment
+	envForModule := environment.NewEnvironment()
+	native.SetupEnvironment(envForModule)
+	envForModule.ImportChain = env.ImportChain
+	envForModule.ImportChain[env.ModuleName] = true
+	envForModule.ModuleName = node.Path
+
+	if err := resolver.Resolve(ast, envForModule); err != nil {
+		return e.Panic(values.IdentifierError, "Name errors in module "+node.Path+":\n"+err.Error(), node.Line, node.Column, env)
+	}
+
+	if e.Sources != nil {
+		e.Sources[node.Path] = source
+	}
+
+	// Errors of the module go up to the import, like the ones of a call
+	if err := run(ast, envForModule); err != nil {
+		return err
+	}
+
+	// Load all the variables of the module into the actual environment using a namespace
+	env.ForceDeclareConst(node.Alias, values.NamespaceValue{Value: envForModule.Variables})
+
+	return values.NothingValue{}
+}
@@ -0,0 +1,288 @@
+package evruntime
+
+import (
+	environment "evie/env"
+	"evie/parser"
+	"evie/resolver"
+	"evie/values"
+	"fmt"
+	"sort"
+)
+
+// Access to the variables where the resolver placed them
+// Locals use their slot, the rest are looked up by name
+
+func (e Evaluator) LookupVar(name string, binding *parser.Binding, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if binding != nil && binding.Local {
+		if value, ok := env.GetSlot(binding.Depth, binding.Slot); ok {
+			return value
+		}
+		return e.Panic(values.IdentifierError, fmt.Sprintf("variable '%s' not found", name), line, column, env)
+	}
+
+	value, err := env.GetVar(name)
+
+	if err != nil {
+		return e.Panic(values.IdentifierError, err.Error(), line, column, env)
+	}
+
+	return value
+}
+
+func (e Evaluator) AssignVar(name string, binding *parser.Binding, value values.RuntimeValue, line int, column int, env *environment.Environment) values.RuntimeValue {
+
+	if binding != nil && binding.Local {
+		if env.SetSlot(binding.Depth, binding.Slot, value) {
+			return value
+		}
+		return e.Panic(values.RuntimeError, fmt.Sprintf("variable '%s' not found", name), line, column, env)
+	}
+
+	if err := env.SetVar(name, value); err != nil {
+		return e.Panic(values.RuntimeError, err.Error(), line, column, env)
+	}
+
+	return value
+}
+
+// Declares a variable in the actual scope, locals were already checked for duplicates by the resolver
+func DeclareVar(name string, binding *parser.Binding, value values.RuntimeValue, env *environment.Environment) error {
+
+	if binding != nil && binding.Local {
+		env.DeclareSlot(binding.Slot, value)
+		return nil
+	}
+
+	return env.DeclareVar(name, value)
+}
+
+// Marks the names of a const declaration that live in the environment, the resolver checks the locals
+func MakeConstants(node parser.VarDeclarationNode, env *environment.Environment) {
+	for _, identifier := range node.Identifiers() {
+		if !identifier.Binding.Local {
+			env.MakeConstant(identifier.Value)
+		}
+	}
+}
+
+func ForceDeclareVar(name string, binding *parser.Binding, value values.RuntimeValue, env *environment.Environment) {
+
+	if binding != nil && binding.Local {
+		env.DeclareSlot(binding.Slot, value)
+		return
+	}
+
+	env.ForceDeclare(name, value)
+}
+
+// Creates the scope of a call, with the arguments in its first slots
+func NewCallEnv(fn values.FunctionValue, args []values.RuntimeValue) *environment.Environment {
+
+	fnEnv := environment.NewScopeEnv(fn.Environment.(*environment.Environment), len(fn.Parameters)+2)
+	fnEnv.Owner = fn.Owner
+	fnEnv.Slots = fnEnv.Slots[:len(fn.Parameters)+2]
+
+	SetCallSlots(fn, args, fnEnv.Slots)
+
+	return fnEnv
+}
+
+// Places the arguments of a call in the slots of its scope, 'this' and 'super' take the ones after the parameters
+// A rest parameter gets the remaining arguments as an array, parameters without argument are left empty
+// The arguments can be in the slots already, the vm calls functions with the arguments where it pushed them
+func SetCallSlots(fn values.FunctionValue, args []values.RuntimeValue, slots []values.RuntimeValue) {
+
+	fixed := len(fn.Parameters)
+	rest := HasRestParameter(fn)
+
+	var remaining *values.ArrayValue
+
+	if rest {
+		fixed--
+		remaining = &values.ArrayValue{Value: make([]values.RuntimeValue, 0)}
+		if len(args) > fixed {
+			remaining.Value = append(remaining.Value, args[fixed:]...)
+		}
+	}
+
+	for index := 0; index < fixed; index++ {
+		if index < len(args) {
+			slots[index] = args[index]
+		} else {
+			slots[index] = nil
+		}
+	}
+
+	if rest {
+		slots[fixed] = remaining
+	}
+
+	// Set this and super
+	if fn.Struct != "" {
+		slots[len(fn.Parameters)] = fn.StructObjRef
+		slots[len(fn.Parameters)+1] = values.SuperValue{Object: fn.StructObjRef, Struct: fn.Super, Owner: fn.Struct}
+	}
+}
+
+func HasRestParameter(fn values.FunctionValue) bool {
+	return len(fn.Parameters) > 0 && fn.Parameters[len(fn.Parameters)-1].Rest
+}
+
+// Checks that a call gives an argument for every required parameter and no more than the function takes
+func CheckArity(fn values.FunctionValue, count int) error {
+
+	required := 0
+	for _, param := range fn.Parameters {
+		if param.Default == nil && !param.Rest {
+			required++
+		}
+	}
+
+	rest := HasRestParameter(fn)
+	total := len(fn.Parameters)
+
+	if rest {
+		total--
+	}
+
+	if count >= required && (rest || count <= total) {
+		return nil
+	}
+
+	var expected string
+
+	if rest {
+		expected = "at least " + arguments(required)
+	} else if required == total {
+		expected = arguments(total)
+	} else {
+		expected = fmt.Sprintf("between %d and %s", required, arguments(total))
+	}
+
+	return fmt.Errorf("%s expects %s but got %d", functionName(fn), expected, count)
+}
+
+func functionName(fn values.FunctionValue) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return "function '" + fn.Name + "'"
+}
+
+func arguments(count int) string {
+	if count == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", count)
+}
+
+// Places the arguments given by name in the position of their parameters and checks the arity
+// Parameters skipped between them are left as nil so they take their default value
+func BindArguments(fn values.FunctionValue, args []values.RuntimeValue, named map[string]values.RuntimeValue) ([]values.RuntimeValue, error) {
+
+	if len(named) == 0 {
+		return args, CheckArity(fn, len(args))
+	}
+
+	if !HasRestParameter(fn) && len(args) > len(fn.Parameters) {
+		return nil, CheckArity(fn, len(args))
+	}
+
+	bound := append(make([]values.RuntimeValue, 0, len(fn.Parameters)), args...)
+
+	names := make([]string, 0, len(named))
+	for name := range named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		index := parameterIndex(fn, name)
+
+		if index < 0 {
+			return nil, fmt.Errorf("%s has no parameter called '%s'", functionName(fn), name)
+		}
+
+		if index < len(args) {
+			return nil, fmt.Errorf("%s got the argument '%s' more than once", functionName(fn), name)
+		}
+
+		for len(bound) <= index {
+			bound = append(bound, nil)
+		}
+		bound[index] = named[name]
+	}
+
+	for index, param := range fn.Parameters {
+		if param.Default == nil && !param.Rest && (index >= len(bound) || bound[index] == nil) {
+			return nil, fmt.Errorf("%s is missing the argument '%s'", functionName(fn), param.Name)
+		}
+	}
+
+	return bound, nil
+}
+
+// Index of the parameter that can be given by name, the rest parameter can not
+func parameterIndex(fn values.FunctionValue, name string) int {
+	for index, param := range fn.Parameters {
+		if param.Name == name && !param.Rest {
+			return index
+		}
+	}
+	return -1
+}
+
+// Adds the arguments given by name as the options of a native function, only the ones that take options accept them
+func NativeArguments(fn values.NativeFunctionValue, args []values.RuntimeValue, named map[string]values.RuntimeValue) ([]values.RuntimeValue, error) {
+
+	if len(named) == 0 {
+		return args, nil
+	}
+
+	if !fn.Options {
+		names := make([]string, 0, len(named))
+		for name := range named {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("native function has no parameter called '%s', it does not take arguments by name", names[0])
+	}
+
+	return append(args, values.OptionsValue{DictionaryValue: &values.DictionaryValue{Value: named}}), nil
+}
+
+// Evaluates the default values of the parameters that did not get an argument
+func (e Evaluator) DeclareDefaults(fn values.FunctionValue, fnEnv *environment.Environment) values.RuntimeValue {
+
+	for index, param := range fn.Parameters {
+
+		if param.Default == nil {
+			continue
+		}
+
+		if _, given := fnEnv.GetSlot(0, index); given {
+			continue
+		}
+
+		value := e.EvaluateExpression(param.Default, fnEnv)
+
+		if value.GetType() == values.ErrorType {
+			return value
+		}
+
+		fnEnv.DeclareSlot(index, value)
+	}
+
+	return nil
+}
+
+// Creates the scope of a block only when the block declares something
+func NewBlockEnv(stmts []parser.Stmt, env *environment.Environment) *environment.Environment {
+
+	if !resolver.NeedsScope(stmts) {
+		return env
+	}
+
+	return environment.NewScopeEnv(env, 0)
+}
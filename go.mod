@@ -3,7 +3,7 @@ module evie
 go 1.23.3
 
 require (
-	github.com/lib/pq v1.10.9 // indirect
-	github.com/sanity-io/litter v1.5.5 // indirect
-	golang.org/x/crypto v0.31.0 // indirect
+	github.com/lib/pq v1.10.9
+	github.com/sanity-io/litter v1.5.5
+	golang.org/x/crypto v0.31.0
 )
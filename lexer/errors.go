@@ -0,0 +1,32 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A character sequence that can not be read as a token
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+	Length  int
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// All the problems found while reading a source, returned by Tokenize
+type Errors []Error
+
+func (e Errors) Error() string {
+
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
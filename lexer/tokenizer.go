@@ -2,13 +2,22 @@ package lexer
 
 import (
 	"evie/common"
-	"fmt"
-	"os"
 	"strconv"
 	"unicode"
+	"unicode/utf8"
 )
 
-func Tokenize(input string) []Token {
+// A string with an interpolation being read
+type template struct {
+	// Open braces inside the interpolation
+	braces int
+	// Delimiter that closes the string
+	closing string
+}
+
+// Splits a source in tokens, the characters that can not be read are skipped
+// and returned as Errors along with the tokens found
+func Tokenize(input string) ([]Token, error) {
 
 	characters := []rune(input)
 
@@ -19,14 +28,127 @@ func Tokenize(input string) []Token {
 
 	var tokens []Token
 	word := ""
-	line := 1
 
-	tokens = append(tokens, Token{
-		Kind:   TOKEN_INIT,
-		Lexeme: "init",
-		Line:   line,
-		Column: 0,
-	})
+	pos := getPositions(characters)
+
+	// Adds a token that starts at the given character and ends at the actual one
+	addToken := func(kind TokenType, lexeme string, start int) {
+		tokens = append(tokens, Token{
+			Kind:      kind,
+			Lexeme:    lexeme,
+			Line:      pos.lines[start],
+			Column:    pos.columns[start],
+			Offset:    pos.offsets[start],
+			EndOffset: pos.offsets[t.Index],
+		})
+	}
+
+	var errs Errors
+
+	// Records a problem found in the given characters of the source
+	addError := func(message string, start int, length int) {
+		errs = append(errs, Error{
+			Message: message,
+			Line:    pos.lines[start],
+			Column:  pos.columns[start],
+			Length:  length,
+		})
+	}
+
+	addToken(TOKEN_INIT, "init", 0)
+
+	// Where the actual string started, for the error when it is not closed
+	stringStart := 0
+
+	// Interpolations being read, the string continues when their last brace is closed
+	templates := []template{}
+
+	// Checks if the text at the actual character is the given one
+	startsWith := func(text string) bool {
+		for i, char := range []rune(text) {
+			if t.Index+i >= len(characters) || characters[t.Index+i] != char {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Reads an escape sequence after its backslash into word
+	scanEscape := func() {
+		escapeStart := t.Index - 1
+		char := t.Eat()
+
+		switch char {
+		case 'n':
+			word += "\n"
+		case 'r':
+			word += "\r"
+		case 't':
+			word += "\t"
+		case 'u':
+			// \u{1F600}
+			code := ""
+			if !t.IsOutOfBounds() && t.Get() == '{' {
+				t.Eat()
+				for !t.IsOutOfBounds() && t.Get() != '}' && t.Get() != '\n' && len(code) <= 6 {
+					code += string(t.Eat())
+				}
+			}
+
+			value, err := strconv.ParseUint(code, 16, 32)
+
+			if t.IsOutOfBounds() || t.Get() != '}' || err != nil || !utf8.ValidRune(rune(value)) {
+				addError("Invalid unicode escape, expected something like \\u{1F600}", escapeStart, t.Index-escapeStart)
+				return
+			}
+			t.Eat()
+
+			word += string(rune(value))
+		default:
+			// Quotes, backslashes, $ and any other character are kept as they are
+			word += string(char)
+		}
+	}
+
+	// Reads the characters of a string into word until its closing delimiter or an interpolation
+	// Returns true if it stopped at the ${ of an interpolation
+	scanString := func(closing string) bool {
+
+		// Raw strings have no escapes nor interpolations
+		raw := closing == "`"
+
+		for {
+
+			if t.IsOutOfBounds() {
+				addError("String not closed, expected "+closing+" before the end of file", stringStart, 1)
+				return false
+			}
+
+			if startsWith(closing) {
+				t.Index += len(closing)
+				return false
+			}
+
+			if t.Get() == '\r' {
+				t.Eat()
+				continue
+			}
+
+			if !raw && t.Get() == '\\' && t.HasNext() {
+				t.Eat()
+				scanEscape()
+				continue
+			}
+
+			if !raw && startsWith("${") {
+				t.Eat()
+				t.Eat()
+				return true
+			}
+
+			word += string(t.Eat())
+		}
+	}
 
 	for {
 		if t.IsOutOfBounds() {
@@ -34,6 +156,7 @@ func Tokenize(input string) []Token {
 		}
 
 		token := t.Get()
+		start := t.Index
 
 		// If it is space
 		if token == ' ' || token == '\t' {
@@ -41,21 +164,31 @@ func Tokenize(input string) []Token {
 			continue
 		}
 
+		// If it is a block comment
+		if startsWith("/*") {
+			t.Eat()
+			t.Eat()
+			for !startsWith("*/") {
+				if t.IsOutOfBounds() {
+					addError("Comment not closed, expected */ before the end of file", start, 2)
+					break
+				}
+				t.Eat()
+			}
+			t.Index = min(t.Index+2, len(characters))
+			continue
+		}
+
 		// If it is comment
 		if token == '/' && t.HasNext() && t.GetNext() == '/' {
 			t.Eat()
 			t.Eat()
-			for {
-				if t.Get() == '\r' {
-					t.Eat()
-					continue
-				} else if t.Get() == '\n' {
-					line += 1
+			for !t.IsOutOfBounds() {
+				if t.Get() == '\n' {
 					t.Eat()
 					break
-				} else {
-					t.Eat()
 				}
+				t.Eat()
 			}
 			continue
 		}
@@ -64,17 +197,11 @@ func Tokenize(input string) []Token {
 			if t.HasNext() && t.GetNext() == '\n' {
 				t.Eat()
 				t.Eat()
-				line += 1
 
 				lastAdded := tokens[len(tokens)-1].Kind
 
 				if lastAdded != TOKEN_EOL && lastAdded != TOKEN_RBRACE && lastAdded != TOKEN_LBRACE && lastAdded != TOKEN_RBRACKET && lastAdded != TOKEN_LBRACKET && lastAdded != TOKEN_COMMA {
-					tokens = append(tokens, Token{
-						Kind:   TOKEN_EOL,
-						Lexeme: "eol",
-						Line:   line,
-						Column: 0,
-					})
+					addToken(TOKEN_EOL, "eol", start)
 				}
 				continue
 			} else {
@@ -85,16 +212,10 @@ func Tokenize(input string) []Token {
 
 		if token == '\n' {
 			t.Eat()
-			line += 1
 			lastAdded := tokens[len(tokens)-1].Kind
 
 			if lastAdded != TOKEN_EOL && lastAdded != TOKEN_RBRACE && lastAdded != TOKEN_LBRACE && lastAdded != TOKEN_RBRACKET && lastAdded != TOKEN_LBRACKET && lastAdded != TOKEN_COMMA {
-				tokens = append(tokens, Token{
-					Kind:   TOKEN_EOL,
-					Lexeme: "eol",
-					Line:   line,
-					Column: 0,
-				})
+				addToken(TOKEN_EOL, "eol", start)
 			}
 			continue
 		}
@@ -112,7 +233,7 @@ func Tokenize(input string) []Token {
 				}
 			}
 
-			tokens = append(tokens, TokenFromWord(word, line))
+			addToken(KeywordKind(word), word, start)
 			word = ""
 			continue
 		}
@@ -121,7 +242,8 @@ func Tokenize(input string) []Token {
 		if isNumber(token) {
 			word += string(token)
 			for {
-				if t.HasNext() && (isNumber(t.GetNext()) || t.GetNext() == '.') {
+				// A dot followed by another one is a range like 0..10
+				if t.HasNext() && (isNumber(t.GetNext()) || t.GetNext() == '.' && t.Index+2 < len(characters) && characters[t.Index+2] != '.') {
 					t.Eat()
 					word += string(t.Get())
 				} else {
@@ -130,303 +252,255 @@ func Tokenize(input string) []Token {
 				}
 			}
 
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_NUMBER,
-				Lexeme: word,
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_NUMBER, word, start)
 			word = ""
 			continue
 		}
 
-		// If it is a string
-		if token == '"' {
-			t.Eat()
+		// If it is a string, it can be split by interpolations like "a ${b} c"
+		// Strings are written with " or ', with """ when they have many lines or with ` when they are raw
+		if token == '"' || token == '\'' || token == '`' {
 
-			initLine := line
+			closing := string(token)
 
-			isScaped := false
+			if startsWith(`"""`) {
+				closing = `"""`
+			}
 
-			for {
+			t.Index += len(closing)
 
-				if t.HasNext() && (t.Get() != '"' || isScaped) {
-
-					if string(t.Get()) == "\\" && isScaped == false {
-						isScaped = true
-						t.Eat()
-						continue
-					}
-
-					if isScaped {
-						isScaped = false
-					}
-
-					if string(t.Get()) == "\\" && string(t.GetNext()) == "n" {
-						t.Eat()
-						t.Eat()
-						word += string('\n')
-						continue
-					} else if string(t.Get()) == "\\" && string(t.GetNext()) == "r" {
-						t.Eat()
-						t.Eat()
-						word += string('\r')
-						continue
-					} else if string(t.Get()) == "\\" && string(t.GetNext()) == "t" {
-						t.Eat()
-						t.Eat()
-						word += string('\t')
-						continue
-					}
-
-					if t.Get() == '\r' {
-						t.Eat()
-						continue
-					}
-
-					if t.Get() == '\n' {
-						line += 1
-					}
-					word += string(t.Eat())
-				} else {
-					if t.Get() != '"' {
-						fmt.Println("string started at line " + strconv.Itoa(initLine) + " not closed")
-						os.Exit(1)
-					}
-					t.Eat()
-					break
-				}
+			// The line break after the opening """ is not part of the string
+			if closing == `"""` && startsWith("\r\n") {
+				t.Eat()
+			}
+			if closing == `"""` && startsWith("\n") {
+				t.Eat()
 			}
 
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_STRING,
-				Lexeme: word,
-				Line:   line,
-				Column: 0,
-			})
+			stringStart = start
+
+			if scanString(closing) {
+				templates = append(templates, template{closing: closing})
+				addToken(TOKEN_TMPL_HEAD, "\""+word+"${", start)
+			} else {
+				addToken(TOKEN_STRING, word, start)
+			}
 
 			word = ""
 			continue
 		}
 
-		// if is an = or ==
+		// if is an =, == or =>
 		if token == '=' {
 			t.Eat()
 			if t.HasNext() && t.Get() == '=' {
 				t.Eat()
-				tokens = append(tokens, Token{
-					Kind:   TOKEN_OPERATOR,
-					Lexeme: "==",
-					Line:   line,
-					Column: 0,
-				})
+				addToken(TOKEN_OPERATOR, "==", start)
+				continue
+			} else if startsWith(">") {
+				t.Eat()
+				addToken(TOKEN_FAT_ARROW, "=>", start)
 				continue
 			} else {
-				tokens = append(tokens, Token{
-					Kind:   TOKEN_ASSIGN,
-					Lexeme: "=",
-					Line:   line,
-					Column: 0,
-				})
+				addToken(TOKEN_ASSIGN, "=", start)
 				continue
 			}
 		}
 
+		// !=
+		if token == '!' && t.HasNext() && t.GetNext() == '=' {
+			t.Eat()
+			t.Eat()
+			addToken(TOKEN_OPERATOR, "!=", start)
+			continue
+		}
+
 		// }
 		if token == '{' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_LBRACE,
-				Lexeme: "{",
-				Line:   line,
-				Column: 0,
-			})
+			if len(templates) > 0 {
+				templates[len(templates)-1].braces++
+			}
+			addToken(TOKEN_LBRACE, "{", start)
 			continue
 		}
 
-		// }
+		// } or the end of an interpolation
 		if token == '}' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_RBRACE,
-				Lexeme: "}",
-				Line:   line,
-				Column: 0,
-			})
+
+			if len(templates) > 0 && templates[len(templates)-1].braces == 0 {
+				actual := templates[len(templates)-1]
+				templates = templates[:len(templates)-1]
+
+				if scanString(actual.closing) {
+					templates = append(templates, actual)
+					addToken(TOKEN_TMPL_MID, "}"+word+"${", start)
+				} else {
+					addToken(TOKEN_TMPL_TAIL, "}"+word+"\"", start)
+				}
+
+				word = ""
+				continue
+			}
+
+			if len(templates) > 0 {
+				templates[len(templates)-1].braces--
+			}
+			addToken(TOKEN_RBRACE, "}", start)
 			continue
 		}
 
 		// [
 		if token == '[' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_LBRACKET,
-				Lexeme: "[",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_LBRACKET, "[", start)
 			continue
 		}
 
 		// ]
 		if token == ']' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_RBRACKET,
-				Lexeme: "]",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_RBRACKET, "]", start)
 			continue
 		}
 
 		// comma
 		if token == ',' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_COMMA,
-				Lexeme: ",",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_COMMA, ",", start)
 			continue
 		}
 
 		// colon
 		if token == ':' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_COLON,
-				Lexeme: ":",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_COLON, ":", start)
 			continue
 		}
 
 		// ternaryexp
 		if token == '?' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_TERNARY,
-				Lexeme: "?",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_TERNARY, "?", start)
 			continue
 		}
 
 		// lpar
 		if token == '(' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_LPAR,
-				Lexeme: "(",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_LPAR, "(", start)
 			continue
 		}
 
 		// rpar
 		if token == ')' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_RPAR,
-				Lexeme: ")",
-				Line:   line,
-				Column: 0,
-			})
+			addToken(TOKEN_RPAR, ")", start)
 			continue
 		}
 
-		// dot
+		// dot, ... and the ranges .. and ..=
 		if token == '.' {
 			t.Eat()
-			tokens = append(tokens, Token{
-				Kind:   TOKEN_DOT,
-				Lexeme: ".",
-				Line:   line,
-				Column: 0,
-			})
+			if startsWith("..") {
+				t.Eat()
+				t.Eat()
+				addToken(TOKEN_ELLIPSIS, "...", start)
+				continue
+			}
+			if startsWith(".=") {
+				t.Eat()
+				t.Eat()
+				addToken(TOKEN_RANGE, "..=", start)
+				continue
+			}
+			if startsWith(".") {
+				t.Eat()
+				addToken(TOKEN_RANGE, "..", start)
+				continue
+			}
+			addToken(TOKEN_DOT, ".", start)
 			continue
 		}
 
-		// < and > and <= and >=
+		// < and > and <= and >= and the shifts << and >>
 		if token == '<' || token == '>' {
 			firstSymbol := string(t.Eat())
-			if t.HasNext() && t.Get() == '=' {
+			if !t.IsOutOfBounds() && t.Get() == token {
+				t.Eat()
+				addToken(TOKEN_OPERATOR, firstSymbol+firstSymbol, start)
+			} else if t.HasNext() && t.Get() == '=' {
 				t.Eat()
-				tokens = append(tokens, Token{
-					Kind:   TOKEN_OPERATOR,
-					Lexeme: firstSymbol + "=",
-					Line:   line,
-					Column: 0,
-				})
+				addToken(TOKEN_OPERATOR, firstSymbol+"=", start)
 			} else {
-				tokens = append(tokens, Token{
-					Kind:   TOKEN_OPERATOR,
-					Lexeme: firstSymbol,
-					Line:   line,
-					Column: 0,
-				})
+				addToken(TOKEN_OPERATOR, firstSymbol, start)
 			}
 			continue
 		}
 
-		// + - * / and also check if after an '-' there is a > to get a '->'
-		if token == '+' || token == '-' || token == '*' || token == '/' {
+		// **
+		if token == '*' && t.HasNext() && t.GetNext() == '*' {
 			t.Eat()
-			if token == '-' {
+			t.Eat()
+			addToken(TOKEN_OPERATOR, "**", start)
+			continue
+		}
+
+		// + - * / % and also check if after an '-' there is a > to get a '->'
+		// followed by = they are compound assignments like +=
+		if token == '+' || token == '-' || token == '*' || token == '/' || token == '%' {
+			t.Eat()
+			if !t.IsOutOfBounds() && t.Get() == '=' {
+				t.Eat()
+				addToken(TOKEN_OP_ASSIGN, string(token)+"=", start)
+			} else if token == '-' {
 				if t.HasNext() && t.Get() == '>' {
 					t.Eat()
-					tokens = append(tokens, Token{
-						Kind:   TOKEN_LARROW,
-						Lexeme: "->",
-						Line:   line,
-						Column: 0,
-					})
+					addToken(TOKEN_LARROW, "->", start)
 				} else {
-					tokens = append(tokens, Token{
-						Kind:   TOKEN_OPERATOR,
-						Lexeme: "-",
-						Line:   line,
-						Column: 0,
-					})
+					addToken(TOKEN_OPERATOR, "-", start)
 				}
 			} else {
-				tokens = append(tokens, Token{
-					Kind:   TOKEN_OPERATOR,
-					Lexeme: string(token),
-					Line:   line,
-					Column: 0,
-				})
+				addToken(TOKEN_OPERATOR, string(token), start)
 			}
 			continue
 		}
 
-		fmt.Println("Unknown token: " + string(token) + " in line " + fmt.Sprint(line))
-		os.Exit(1)
-
-		if t.HasNext() {
+		// Bitwise & | ^ ~ and the integer division ~/
+		if token == '&' || token == '|' || token == '^' || token == '~' {
 			t.Eat()
+			if token == '~' && !t.IsOutOfBounds() && t.Get() == '/' {
+				t.Eat()
+				addToken(TOKEN_OPERATOR, "~/", start)
+			} else {
+				addToken(TOKEN_OPERATOR, string(token), start)
+			}
+			continue
 		}
 
+		// The parser skips the statement of the unknown token without reporting it again
+		t.Eat()
+		addToken(TOKEN_ERROR, string(token), start)
+		addError("Unknown token '"+string(token)+"'", start, 1)
+	}
+
+	addToken(TOKEN_EOF, "", len(characters))
+
+	if len(errs) > 0 {
+		return tokens[1:], errs
 	}
 
-	tokens = append(tokens, Token{
-		Kind:   TOKEN_EOF,
-		Lexeme: "",
-		Line:   line,
-		Column: 0,
-	})
-	return tokens[1:]
+	return tokens[1:], nil
 }
 
-func TokenFromWord(w string, l int) Token {
+// Returns the kind of token for a word, keywords have their own kinds
+func KeywordKind(w string) TokenType {
 	var Kind TokenType
 	if w == "var" {
 		Kind = TOKEN_VAR
+	} else if w == "const" {
+		Kind = TOKEN_CONST
 	} else if w == "fn" {
 		Kind = TOKEN_FN
 	} else if w == "if" {
@@ -461,6 +535,8 @@ func TokenFromWord(w string, l int) Token {
 		Kind = TOKEN_NOTHING
 	} else if w == "return" {
 		Kind = TOKEN_RETURN
+	} else if w == "yield" {
+		Kind = TOKEN_YIELD
 	} else if w == "try" {
 		Kind = TOKEN_TRY
 	} else if w == "catch" {
@@ -471,16 +547,15 @@ func TokenFromWord(w string, l int) Token {
 		Kind = TOKEN_IMPORT
 	} else if w == "loop" {
 		Kind = TOKEN_LOOP
+	} else if w == "while" {
+		Kind = TOKEN_WHILE
+	} else if w == "match" {
+		Kind = TOKEN_MATCH
 	} else {
 		Kind = TOKEN_IDENTIFIER
 	}
 
-	return Token{
-		Kind:   Kind,
-		Lexeme: w,
-		Line:   l,
-		Column: 0,
-	}
+	return Kind
 }
 
 func IsAlpha(char rune) bool {
@@ -490,3 +565,46 @@ func IsAlpha(char rune) bool {
 func isNumber(char rune) bool {
 	return unicode.IsDigit(char)
 }
+
+// Line, column and byte offset of every character of a source
+type positions struct {
+	lines   []int
+	columns []int
+	offsets []int
+}
+
+// Calculates where each character is, with one extra position for the end of the source.
+// Lines and columns start at 1, columns are counted in characters and offsets in bytes
+func getPositions(characters []rune) positions {
+
+	pos := positions{
+		lines:   make([]int, len(characters)+1),
+		columns: make([]int, len(characters)+1),
+		offsets: make([]int, len(characters)+1),
+	}
+
+	line := 1
+	column := 1
+	offset := 0
+
+	for i, char := range characters {
+		pos.lines[i] = line
+		pos.columns[i] = column
+		pos.offsets[i] = offset
+
+		offset += utf8.RuneLen(char)
+
+		if char == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	pos.lines[len(characters)] = line
+	pos.columns[len(characters)] = column
+	pos.offsets[len(characters)] = offset
+
+	return pos
+}
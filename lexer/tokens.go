@@ -5,6 +5,10 @@ type Token struct {
 	Lexeme string
 	Line   int
 	Column int
+
+	// Byte offsets in the source where the token starts and ends
+	Offset    int
+	EndOffset int
 }
 
 type TokenType int
@@ -13,8 +17,11 @@ const (
 	TOKEN_IDENTIFIER = iota
 
 	TOKEN_VAR
+	TOKEN_CONST
 	TOKEN_IMPORT
 	TOKEN_LOOP
+	TOKEN_MATCH
+	TOKEN_WHILE
 	TOKEN_FN
 	TOKEN_IF
 	TOKEN_NOTHING
@@ -32,12 +39,20 @@ const (
 	TOKEN_TRUE
 	TOKEN_FALSE
 	TOKEN_RETURN
+	TOKEN_YIELD
 	TOKEN_TRY
 	TOKEN_CATCH
 	TOKEN_FINALLY
 
 	TOKEN_NUMBER
 	TOKEN_STRING
+
+	// Pieces of a string with interpolations, "a ${b} c ${d} e" is HEAD b MID d TAIL
+	// Their lexemes keep the delimiters, like '"a ${', so they are never taken as operators
+	TOKEN_TMPL_HEAD
+	TOKEN_TMPL_MID
+	TOKEN_TMPL_TAIL
+
 	TOKEN_BOOLEAN
 	TOKEN_LBRACKET
 	TOKEN_RBRACKET
@@ -49,57 +64,76 @@ const (
 	TOKEN_COLON
 	TOKEN_TERNARY
 	TOKEN_DOT
+	TOKEN_ELLIPSIS
+	TOKEN_RANGE
 	TOKEN_LARROW
+	TOKEN_FAT_ARROW
 	TOKEN_OPERATOR
 	TOKEN_ASSIGN
+	TOKEN_OP_ASSIGN
 	TOKEN_EOF
 	TOKEN_EOL
 	TOKEN_INIT
+	// A character that is not part of the language, the lexer already reported it
+	TOKEN_ERROR
 )
 
 var tokenTypeLookUp = map[TokenType]string{
-	TOKEN_VAR:      "var",
-	TOKEN_IMPORT:   "import",
-	TOKEN_LOOP:     "loop",
-	TOKEN_FN:       "fn",
-	TOKEN_IF:       "if",
-	TOKEN_NOTHING:  "Nothing",
-	TOKEN_AS:       "as",
-	TOKEN_ELSE:     "else",
-	TOKEN_ELSEIF:   "elseif",
-	TOKEN_STRUCT:   "struct",
-	TOKEN_FOR:      "for",
-	TOKEN_CONTINUE: "continue",
-	TOKEN_BREAK:    "break",
-	TOKEN_IN:       "in",
-	TOKEN_OR:       "or",
-	TOKEN_AND:      "and",
-	TOKEN_NOT:      "not",
-	TOKEN_TRUE:     "true",
-	TOKEN_FALSE:    "false",
-	TOKEN_RETURN:   "return",
-	TOKEN_TRY:      "try",
-	TOKEN_CATCH:    "catch",
-	TOKEN_FINALLY:  "finally",
-	TOKEN_NUMBER:   "number",
-	TOKEN_STRING:   "string",
-	TOKEN_BOOLEAN:  "boolean",
-	TOKEN_LBRACKET: "[",
-	TOKEN_RBRACKET: "]",
-	TOKEN_RBRACE:   "}",
-	TOKEN_LBRACE:   "{",
-	TOKEN_LPAR:     "(",
-	TOKEN_RPAR:     ")",
-	TOKEN_COMMA:    ",",
-	TOKEN_COLON:    ":",
-	TOKEN_TERNARY:  "?",
-	TOKEN_DOT:      ".",
-	TOKEN_LARROW:   "->",
-	TOKEN_OPERATOR: "operator",
-	TOKEN_ASSIGN:   "=",
-	TOKEN_EOF:      "eof",
-	TOKEN_EOL:      "eol",
-	TOKEN_INIT:     "init",
+	TOKEN_IDENTIFIER: "identifier",
+	TOKEN_VAR:        "var",
+	TOKEN_CONST:      "const",
+	TOKEN_IMPORT:     "import",
+	TOKEN_LOOP:       "loop",
+	TOKEN_MATCH:      "match",
+	TOKEN_WHILE:      "while",
+	TOKEN_FN:         "fn",
+	TOKEN_IF:         "if",
+	TOKEN_NOTHING:    "Nothing",
+	TOKEN_AS:         "as",
+	TOKEN_ELSE:       "else",
+	TOKEN_ELSEIF:     "elseif",
+	TOKEN_STRUCT:     "struct",
+	TOKEN_FOR:        "for",
+	TOKEN_CONTINUE:   "continue",
+	TOKEN_BREAK:      "break",
+	TOKEN_IN:         "in",
+	TOKEN_OR:         "or",
+	TOKEN_AND:        "and",
+	TOKEN_NOT:        "not",
+	TOKEN_TRUE:       "true",
+	TOKEN_FALSE:      "false",
+	TOKEN_RETURN:     "return",
+	TOKEN_YIELD:      "yield",
+	TOKEN_TRY:        "try",
+	TOKEN_CATCH:      "catch",
+	TOKEN_FINALLY:    "finally",
+	TOKEN_NUMBER:     "number",
+	TOKEN_STRING:     "string",
+	TOKEN_TMPL_HEAD:  "string",
+	TOKEN_TMPL_MID:   "string",
+	TOKEN_TMPL_TAIL:  "string",
+	TOKEN_BOOLEAN:    "boolean",
+	TOKEN_LBRACKET:   "[",
+	TOKEN_RBRACKET:   "]",
+	TOKEN_RBRACE:     "}",
+	TOKEN_LBRACE:     "{",
+	TOKEN_LPAR:       "(",
+	TOKEN_RPAR:       ")",
+	TOKEN_COMMA:      ",",
+	TOKEN_COLON:      ":",
+	TOKEN_TERNARY:    "?",
+	TOKEN_DOT:        ".",
+	TOKEN_ELLIPSIS:   "...",
+	TOKEN_RANGE:      "..",
+	TOKEN_LARROW:     "->",
+	TOKEN_FAT_ARROW:  "=>",
+	TOKEN_OPERATOR:   "operator",
+	TOKEN_ASSIGN:     "=",
+	TOKEN_OP_ASSIGN:  "operator assignment",
+	TOKEN_EOF:        "eof",
+	TOKEN_EOL:        "eol",
+	TOKEN_INIT:       "init",
+	TOKEN_ERROR:      "unknown token",
 }
 
 func GetTokenName(tokenType TokenType) string {
@@ -20,7 +20,7 @@ func Load(env *environment.Environment) {
 
 	nm.Value = fns
 
-	env.DeclareVar("crypt", nm)
+	env.DeclareConst("crypt", nm)
 
 }
 
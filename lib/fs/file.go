@@ -93,3 +93,30 @@ func (s FileValue) GetProp(name string) (values.RuntimeValue, error) {
 
 	return p, nil
 }
+
+// Files are iterated by line, from the actual position
+func (s FileValue) Iterate() values.Iterator {
+	return &lineIterator{file: s}
+}
+
+type lineIterator struct {
+	file  FileValue
+	index int
+}
+
+func (it *lineIterator) Next() (values.RuntimeValue, values.RuntimeValue, bool) {
+
+	if it.file.Closed {
+		return values.ErrorValue{Value: "File is closed"}, nil, true
+	}
+
+	if !it.file.Scanner.Scan() {
+		if err := it.file.Scanner.Err(); err != nil {
+			return values.ErrorValue{Value: err.Error()}, nil, true
+		}
+		return nil, nil, false
+	}
+
+	it.index++
+	return values.StringValue{Value: it.file.Scanner.Text()}, values.NumberValue{Value: float64(it.index - 1)}, true
+}
@@ -32,7 +32,7 @@ func Load(env *environment.Environment) {
 	methods["moveOrRenameDir"] = values.NativeFunctionValue{Value: MoveDir}
 	ns.Value = methods
 
-	env.ForceDeclare("fs", ns)
+	env.ForceDeclareConst("fs", ns)
 
 }
 
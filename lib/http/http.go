@@ -21,12 +21,12 @@ func Load(env *environment.Environment) {
 	namespace := values.NamespaceValue{Value: make(map[string]values.RuntimeValue)}
 
 	namespace.Value["route"] = values.NativeFunctionValue{Value: AddRoute}
-	namespace.Value["listen"] = values.NativeFunctionValue{Value: ListenAndServe}
+	namespace.Value["listen"] = values.NativeFunctionValue{Value: ListenAndServe, Options: true}
 
-	env.DeclareVar("http", namespace)
+	env.DeclareConst("http", namespace)
 
 	// REQUEST VALUE STRUCT
-	env.DeclareVar("Request", GetRequestStructValue())
+	env.DeclareConst("Request", GetRequestStructValue())
 }
 
 func AddRoute(args []values.RuntimeValue) values.RuntimeValue {
@@ -63,14 +63,37 @@ func AddRoute(args []values.RuntimeValue) values.RuntimeValue {
 	return values.BoolValue{Value: true}
 }
 
+// Serves the routes on a port, http.listen(8080) or http.listen(port: 8080, host: "127.0.0.1")
 func ListenAndServe(args []values.RuntimeValue) values.RuntimeValue {
 
-	if len(args) == 0 {
+	args, options := values.SplitOptions(args)
+
+	var port values.RuntimeValue
+	host := ""
+
+	if len(args) > 0 {
+		port = args[0]
+	}
+
+	for name, option := range options {
+		switch name {
+		case "port":
+			if port != nil {
+				return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: "listen got the port more than once"}
+			}
+			port = option
+		case "host":
+			host = option.GetString()
+		default:
+			return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: "Unknown listen option '" + name + "'"}
+		}
+	}
+
+	if port == nil {
 		return values.ErrorValue{Value: "No port specified"}
 	}
 
-	port := args[0].GetString()
-	err := http.ListenAndServe(":"+port, nil)
+	err := http.ListenAndServe(host+":"+port.GetString(), nil)
 
 	if err != nil {
 		return values.ErrorValue{Value: err.Error()}
@@ -26,7 +26,7 @@ func Load(env *environment.Environment) {
 	namespace.Value["encode"] = values.NativeFunctionValue{Value: Encode}
 	namespace.Value["decode"] = values.NativeFunctionValue{Value: Decode}
 
-	env.DeclareVar("json", namespace)
+	env.DeclareConst("json", namespace)
 }
 
 func Encode(args []values.RuntimeValue) values.RuntimeValue {
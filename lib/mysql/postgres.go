@@ -12,50 +12,60 @@ import (
 func Load(env *environment.Environment) {
 	ns := values.NamespaceValue{Value: make(map[string]values.RuntimeValue)}
 
-	ns.Value["connect"] = values.NativeFunctionValue{Value: Connect}
+	ns.Value["connect"] = values.NativeFunctionValue{Value: Connect, Options: true}
 
-	env.DeclareVar("postgres", ns)
+	env.DeclareConst("postgres", ns)
 }
 
+// Settings of the connection, the first ones can also be given by position
+var connectSettings = []string{"user", "password", "dbname", "host", "port", "sslmode"}
+
+// The settings that can be given by position, in order
+const positionalSettings = 4
+
 func Connect(args []values.RuntimeValue) values.RuntimeValue {
 
-	argslen := len(args)
+	args, options := values.SplitOptions(args)
 
-	usern := "postgres"
-	pw := "admin"
-	dbname := "postgres"
-	host := "127.0.0.1"
+	config := map[string]string{
+		"user":     "postgres",
+		"password": "admin",
+		"dbname":   "postgres",
+		"host":     "127.0.0.1",
+		"port":     "5432",
+		"sslmode":  "disable",
+	}
 
-	if argslen > 0 {
-		usernStr, ok := args[0].(values.StringValue)
-		if !ok {
-			return values.ErrorValue{Value: "Expected username to be a string"}
-		}
-		usern = usernStr.Value
+	if len(args) > positionalSettings {
+		return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: "connect takes at most 4 arguments, the rest of the settings go by name"}
 	}
-	if argslen > 1 {
-		pwString, ok := args[1].(values.StringValue)
-		if !ok {
-			return values.ErrorValue{Value: "Expected password to be a string"}
+
+	for index, arg := range args {
+		name := connectSettings[index]
+		if _, exists := options[name]; exists {
+			return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: "connect got the setting '" + name + "' more than once"}
 		}
-		pw = pwString.Value
+		options[name] = arg
 	}
-	if argslen > 2 {
-		dbnameStr, ok := args[2].(values.StringValue)
-		if !ok {
-			return values.ErrorValue{Value: "Expected dbname to be a string"}
+
+	for name, option := range options {
+		if _, exists := config[name]; !exists {
+			return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: "Unknown connect option '" + name + "'"}
 		}
-		dbname = dbnameStr.Value
-	}
-	if argslen > 3 {
-		hostStr, ok := args[3].(values.StringValue)
-		if !ok {
-			return values.ErrorValue{Value: "Expected host to be a string"}
+
+		switch option.(type) {
+		case values.StringValue, values.NumberValue:
+			config[name] = option.GetString()
+		default:
+			return values.ErrorValue{Value: "Expected " + name + " to be a string"}
 		}
-		host = hostStr.Value
 	}
 
-	connStr := "user=" + usern + " password=" + pw + " dbname=" + dbname + " host=" + host + " sslmode=disable"
+	connStr := ""
+	for _, name := range connectSettings {
+		connStr += name + "=" + config[name] + " "
+	}
+
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		log.Fatal(err)
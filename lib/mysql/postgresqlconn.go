@@ -3,6 +3,7 @@ package postgresLib
 import (
 	"database/sql"
 	"evie/values"
+	"fmt"
 	"reflect"
 	"strings"
 )
@@ -104,15 +105,6 @@ func (db *PostgreSQLConnection) GetProp(name string) (values.RuntimeValue, error
 			return values.ErrorValue{Value: err.Error()}
 		}
 
-		// Make a list to hold the row values
-		// to load dynamicly the columns in runtime structures
-		rowsList := make([]interface{}, len(columnNames))
-		rowsListPtrs := make([]interface{}, len(columnNames))
-
-		for i := range rowsList {
-			rowsListPtrs[i] = &rowsList[i]
-		}
-
 		// Returned value will be an array of dictionaries
 		arr := values.ArrayValue{}
 		arr.Value = make([]values.RuntimeValue, 0)
@@ -120,38 +112,150 @@ func (db *PostgreSQLConnection) GetProp(name string) (values.RuntimeValue, error
 		// Load vals in dict
 		for rows.Next() {
 
-			dict := values.DictionaryValue{}
-			dict.Value = make(map[string]values.RuntimeValue)
-
-			err = rows.Scan(rowsListPtrs...)
-			// now, rowsList has the returned literals values of the columns
+			dict, err := scanRow(rows, columnNames)
 
 			if err != nil {
 				return values.ErrorValue{Value: err.Error()}
 			}
 
-			for i, colName := range columnNames {
+			// Add row
+			arr.Value = append(arr.Value, dict)
+		}
 
-				colVal := rowsList[i]
+		return &arr
 
-				switch reflect.TypeOf(colVal) {
-				case reflect.TypeOf(""):
-					dict.Value[colName] = values.StringValue{Value: colVal.(string)}
-				case reflect.TypeOf(0):
-					dict.Value[colName] = values.NumberValue{Value: colVal.(float64)}
-				case reflect.TypeOf(true):
-					dict.Value[colName] = values.BoolValue{Value: colVal.(bool)}
-				}
+	}}
 
-			}
+	// Like query but the rows are read while iterating them, instead of loading all of them at once
+	props["rows"] = values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
 
-			// Add row
-			arr.Value = append(arr.Value, &dict)
+		if len(args) == 0 {
+			return values.ErrorValue{Value: "No query specified, expected 1 argument"}
 		}
 
-		return &arr
+		queryStr, ok := args[0].(values.StringValue)
+
+		if !ok {
+			return values.ErrorValue{Value: "Expected query to be a string"}
+		}
+
+		rows, err := db.Value.Query(strings.TrimSpace(queryStr.Value))
+
+		if err != nil {
+			return values.ErrorValue{Value: err.Error()}
+		}
+
+		columnNames, err := rows.Columns()
 
+		if err != nil {
+			rows.Close()
+			return values.ErrorValue{Value: err.Error()}
+		}
+
+		return &PostgreSQLRows{Value: rows, Columns: columnNames}
 	}}
 
 	return props[name], nil
 }
+
+// Loads the actual row in a dictionary
+func scanRow(rows *sql.Rows, columnNames []string) (*values.DictionaryValue, error) {
+
+	// Make a list to hold the row values
+	// to load dynamicly the columns in runtime structures
+	rowsList := make([]interface{}, len(columnNames))
+	rowsListPtrs := make([]interface{}, len(columnNames))
+
+	for i := range rowsList {
+		rowsListPtrs[i] = &rowsList[i]
+	}
+
+	dict := values.DictionaryValue{}
+	dict.Value = make(map[string]values.RuntimeValue)
+
+	err := rows.Scan(rowsListPtrs...)
+	// now, rowsList has the returned literals values of the columns
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, colName := range columnNames {
+
+		colVal := rowsList[i]
+
+		switch reflect.TypeOf(colVal) {
+		case reflect.TypeOf(""):
+			dict.Value[colName] = values.StringValue{Value: colVal.(string)}
+		case reflect.TypeOf(0):
+			dict.Value[colName] = values.NumberValue{Value: colVal.(float64)}
+		case reflect.TypeOf(true):
+			dict.Value[colName] = values.BoolValue{Value: colVal.(bool)}
+		}
+
+	}
+
+	return &dict, nil
+}
+
+// Result of a query that gives its rows as dictionaries in a for in loop
+type PostgreSQLRows struct {
+	Value   *sql.Rows
+	Columns []string
+	index   int
+}
+
+func (r *PostgreSQLRows) GetNumber() float64 {
+	return 1
+}
+func (r *PostgreSQLRows) GetType() values.ValueType {
+	return values.CustomType
+}
+func (r *PostgreSQLRows) GetBool() bool {
+	return true
+}
+
+func (r *PostgreSQLRows) GetString() string {
+	return "Postgres Rows object"
+}
+
+func (r *PostgreSQLRows) GetProp(name string) (values.RuntimeValue, error) {
+	if name == "close" {
+		return values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
+			r.Close()
+			return values.BoolValue{Value: true}
+		}}, nil
+	}
+	return values.NothingValue{}, fmt.Errorf("property %s does not exists", name)
+}
+
+func (r *PostgreSQLRows) Iterate() values.Iterator {
+	return r
+}
+
+// Loops that stop before the last row release the connection they hold
+func (r *PostgreSQLRows) Close() {
+	r.Value.Close()
+}
+
+// The rows are closed after the last one
+func (r *PostgreSQLRows) Next() (values.RuntimeValue, values.RuntimeValue, bool) {
+
+	if !r.Value.Next() {
+		r.Value.Close()
+		if err := r.Value.Err(); err != nil {
+			return values.ErrorValue{Value: err.Error()}, nil, true
+		}
+		return nil, nil, false
+	}
+
+	dict, err := scanRow(r.Value, r.Columns)
+
+	if err != nil {
+		r.Value.Close()
+		return values.ErrorValue{Value: err.Error()}, nil, true
+	}
+
+	r.index++
+	return dict, values.NumberValue{Value: float64(r.index - 1)}, true
+}
@@ -24,7 +24,7 @@ func Load(env *environment.Environment) {
 
 	ns.Value["PATH_SEPARATOR"] = values.StringValue{Value: string(filepath.Separator)}
 
-	env.ForceDeclare("os", ns)
+	env.ForceDeclareConst("os", ns)
 
 }
 
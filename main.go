@@ -1,12 +1,15 @@
 package main
 
 import (
+	"errors"
 	"evie/common"
 	environment "evie/env"
 	"evie/evruntime"
-	"evie/lexer"
 	"evie/native"
 	"evie/parser"
+	"evie/resolver"
+	"evie/vm"
+	"flag"
 	"fmt"
 	"os"
 	"path"
@@ -14,23 +17,60 @@ import (
 	"time"
 )
 
+// Engines that can run a file
+const (
+	engineTree = "tree"
+	engineVM   = "vm"
+)
+
+var engine = flag.String("engine", engineTree, "engine used to run the file, '"+engineTree+"' or '"+engineVM+"'")
+
 func Init() {
 
 	// timer := profil.ObtenerInstancia()
 
 	file := GetFileName()
 
-	ast := ParseContent(file)
+	// Without a file to run, start the interactive mode
+	if file == "" {
+		StartRepl()
+		return
+	}
+
+	ast, source := ParseContent(file)
 
 	// GetRootPath(file)
 
 	var env *environment.Environment = SetupInitialEnv(file)
 
+	// Variables are checked and placed before running anything
+	if err := resolver.Resolve(ast, env); err != nil {
+		PrintParseError(file, source, err)
+		os.Exit(1)
+	}
+
 	start := time.Now()
 
 	intr := evruntime.Evaluator{Nodes: ast}
 	intr.RootPath = GetRootPath(file)
-	intr.Evaluate(env)
+	intr.Sources = map[string]string{file: source}
+
+	switch *engine {
+	case engineTree:
+		intr.Evaluate(env)
+	case engineVM:
+		chunk, err := vm.Compile(ast)
+
+		if err != nil {
+			PrintParseError(file, source, err)
+			os.Exit(1)
+		}
+
+		vm.NewVM(intr).Evaluate(chunk, env)
+	default:
+		fmt.Println("Unknown engine '" + *engine + "', use '" + engineTree + "' or '" + engineVM + "'")
+		os.Exit(1)
+	}
 
 	fmt.Println("\nEval time: ", time.Since(start).Microseconds()/1000, "ms")
 	// timer.Display()
@@ -58,28 +98,51 @@ func GetRootPath(mainFileName string) string {
 	return root
 }
 
-func ParseContent(file string) []parser.Stmt {
+// Reads and parses a file, returns the AST and the source code
+func ParseContent(file string) ([]parser.Stmt, string) {
 	var source string = common.ReadFile(common.AddExtension(file))
 
-	tokens := lexer.Tokenize(source)
+	ast, err := parser.Parse(source)
 
-	ast := parser.NewParser(tokens).GetAST()
+	if err != nil {
+		PrintParseError(file, source, err)
+		os.Exit(1)
+	}
 
-	return ast
+	return ast, source
 }
 
-func GetFileName() string {
-	args := os.Args
+// Shows every syntax or name error found in a module, with the line of code where it is
+func PrintParseError(moduleName string, source string, err error) {
 
-	var file string = "main"
+	var parseErr parser.ParseError
+	var nameErr resolver.NameError
 
-	if len(args) > 1 {
-		file = args[1]
+	if errors.As(err, &nameErr) {
+		fmt.Println("\n >>> DONT PANIC, but there are name errors at module " + moduleName + ":")
+		parseErr = nameErr.ParseError
 	} else {
-		panic("Please specify a file")
+		fmt.Println("\n >>> DONT PANIC, but there are syntax errors at module " + moduleName + ":")
+
+		if !errors.As(err, &parseErr) {
+			fmt.Println("\t " + err.Error() + "\n")
+			return
+		}
 	}
 
-	return file
+	for _, d := range parseErr.Diagnostics {
+		fmt.Println("\n\t " + d.Error())
+
+		if excerpt := common.SourceExcerpt(source, d.Line, d.Column, d.Length); excerpt != "" {
+			fmt.Println(excerpt)
+		}
+	}
+
+	fmt.Println()
+}
+
+func GetFileName() string {
+	return flag.Arg(0)
 }
 
 func SetupInitialEnv(moduleName string) *environment.Environment {
@@ -95,6 +158,7 @@ func SetupInitialEnv(moduleName string) *environment.Environment {
 func main() {
 
 	// Parse cl arguments
+	flag.Parse()
 
 	Init()
 
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Scripts run by TestEnginesMatch besides the examples, for the places where
+// the engines are easy to get apart
+var scripts = map[string]string{
+	"try_loops": `
+for i in [0, 1, 2, 3, 4] {
+	try {
+		if i == 1 { continue }
+		if i == 3 { break }
+		print(i)
+	} catch {} finally { print("finally " + string(i)) }
+}
+for i in 0..5 {
+	try {
+		panic("oops")
+	} catch {
+		if i == 1 { continue }
+		if i == 3 { break }
+		print("catch " + string(i))
+	}
+}
+var f = fn() {
+	for i in 0..3 {
+		try { if i == 1 { return i } } catch {} finally { print("finally") }
+	}
+}
+print(f())
+`,
+	"errors": `
+try { -"a" } catch { print(error.type, error.message) }
+try { 5[1:2] } catch { print(error.type, error.message) }
+try { [1, 2][5] } catch { print(error.type, error.message) }
+try { 1 + "a" } catch { print(error.type, error.message) }
+-"a"
+`,
+	"loop_closures": `
+var fns = []
+for i in [1, 2, 3] { fns.add(fn() { return i }) }
+for i in 0..3 { fns.add(fn() { return i * 10 }) }
+var dict = {"a": 1, "b": 2}
+for key in dict { fns.add(fn() { return key + string(dict[key]) }) }
+for f in fns { print(f()) }
+`,
+	"generators": `
+fn count(n) {
+	for i in 0..n { yield i }
+}
+for x in count(5) {
+	if x == 3 { break }
+	print(x)
+}
+var gen = count(3)
+print(gen.next(), gen.next(), gen.next(), gen.next())
+`,
+	"structs": `
+struct Point {
+	x = 0
+	y = 0
+}
+Point -> __add(other) { return Point{x: this.x + other.x, y: this.y + other.y} }
+Point -> __str() { return "(${this.x}, ${this.y})" }
+var p = Point{x: 1} + Point{y: 2}
+print(p, "${p}", p is Point)
+`,
+	"enums": `
+enum Shape {
+	Circle(radius)
+	Square(side)
+	Empty
+}
+for shape in [Shape.Circle(1), Shape.Square(2), Shape.Empty] {
+	match shape {
+		Shape.Circle(r) => print("circle", r)
+		Shape.Square(s) => print("square", s)
+		_ => print("empty")
+	}
+}
+`,
+}
+
+// Lines that change between runs, like the time it took to evaluate
+var unstableLines = regexp.MustCompile(`(?m)^Eval time:.*$`)
+
+// The position and message of each diagnostic
+var diagnosticLines = regexp.MustCompile(`(?m)line \d+, column \d+: .*$`)
+
+// Runs the file with both engines and checks that they print the same
+func TestEnginesMatch(t *testing.T) {
+
+	files, err := filepath.Glob(filepath.Join("examples", "*.ev"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	for name, source := range scripts {
+		file := filepath.Join(dir, name+".ev")
+
+		if err := os.WriteFile(file, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		files = append(files, file)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".ev")
+
+		t.Run(filepath.Base(name), func(t *testing.T) {
+
+			switch filepath.Base(name) {
+			case "process":
+				t.Skip("kills the process it looks for")
+			case "fibonacci":
+				if testing.Short() {
+					t.Skip("slow")
+				}
+			}
+
+			t.Parallel()
+
+			tree := runEngine(t, engineTree, name)
+			vm := runEngine(t, engineVM, name)
+
+			if tree != vm {
+				t.Errorf("engines do not match\n--- tree:\n%s\n--- vm:\n%s", tree, vm)
+			}
+		})
+	}
+}
+
+// Functions declared in a loop see the values of their own iteration
+func TestClosuresCapturePerIteration(t *testing.T) {
+
+	source := `
+var fns = []
+for value in [10, 20, 30] { fns.add(fn() { return value }) }
+var ages = {"ana": 20, "bob": 30}
+for name in ages { fns.add(fn() { return name }) }
+for i in 0..3 { fns.add(fn() { return i }) }
+for f in fns { print(f()) }
+`
+	expectOutput(t, source, "10\n20\n30\nana\nbob\n0\n1\n2")
+}
+
+// Closures share the locals they take and keep them after the function that declares them returns
+func TestClosuresShareLocals(t *testing.T) {
+
+	source := `
+fn counter() {
+	var n = 0
+	return [fn() { n += 1 }, fn() { return n }]
+}
+var [inc, get] = counter()
+inc()
+inc()
+print(get())
+fn outer() {
+	var a = 1
+	fn mid() {
+		var b = 2
+		return fn() {
+			a += 100
+			return a + b
+		}
+	}
+	var inner = mid()
+	return [inner(), a]
+}
+print(outer())
+fn blocks() {
+	var out = []
+	if true {
+		var x = "first"
+		out.add(fn() { return x })
+	}
+	if true {
+		var y = "second"
+		out.add(fn() { return y })
+	}
+	return [out[0](), out[1]()]
+}
+print(blocks())
+fn early() {
+	var f = fn() { return late }
+	try { f() } catch { print(error.message) }
+	var late = 5
+	return f()
+}
+print(early())
+`
+	expectOutput(t, source, "2\n[ 103, 101, ] \n[ 'first', 'second', ] \nvariable 'late' not found\n5")
+}
+
+// A handler can check built in and custom errors the same way
+func TestMixedErrorTypes(t *testing.T) {
+
+	source := `
+fn fail(kind) {
+	if kind == "custom" {
+		panic(ErrorObject{message: "custom failure", type: "MyCustomError"})
+	}
+	if kind == "index" { return [1, 2][5] }
+	return 10 / 0
+}
+for kind in ["custom", "index", "zero"] {
+	try {
+		fail(kind)
+	} catch {
+		print(error.type == RuntimeError, error.type == "InvalidIndexError", error.type == "MyCustomError")
+		print("type: " + error.type)
+		match error.kind {
+			ErrorKind.ZeroDivisionError => print("zero")
+			ErrorKind.InvalidIndexError => print("index")
+			nothing => print("custom")
+		}
+	}
+}
+`
+	expectOutput(t, source, "falsefalsetrue\ntype: MyCustomError\ncustom\nfalsetruefalse\ntype: InvalidIndexError\nindex\nfalsefalsefalse\ntype: ZeroDivisionError\nzero")
+}
+
+// Negative indexes count from the end of the array when assigning too
+func TestNegativeIndexAssignment(t *testing.T) {
+
+	source := `
+var a = [1, 2, 3]
+a[-1] += 5
+a[-3] = 0
+print(a)
+try { a[-4] = 1 } catch { print(error.message) }
+`
+	expectOutput(t, source, "[ 0, 2, 8, ] \nInvalid array index or out of bounds with index: -4")
+}
+
+// The bitwise operators only give integers that a number keeps exactly
+func TestBitwiseLimits(t *testing.T) {
+
+	source := `
+var ops = [fn() { return 1 << 60 }, fn() { return (2 ** 53) | 1 }, fn() { return 1 << -1 }, fn() { return 5 & 3 }]
+for op in ops {
+	try { print(op()) } catch { print(error.type, " ", error.message) }
+}
+`
+	expectOutput(t, source, "TypeError Result of << is too big for an integer\nTypeError Result of | is too big for an integer\nTypeError Negative shift count -1\n1")
+}
+
+// Only the natives that take options accept arguments by name
+func TestNamedArgumentsToNatives(t *testing.T) {
+
+	source := `
+var a = [1]
+try { print("x", sep: 1) } catch { print(error.type, " ", error.message) }
+try { a.add(2, x: 1) } catch { print(error.type, " ", error.message) }
+print(a)
+`
+	expectOutput(t, source, "InvalidArgumentError native function has no parameter called 'sep', it does not take arguments by name\n"+
+		"InvalidArgumentError native function has no parameter called 'x', it does not take arguments by name\n[ 1, ]")
+}
+
+// A struct of another module with the same name does not get the private members
+func TestPrivateMembersOfSameNamedStruct(t *testing.T) {
+
+	wallet := `
+struct Wallet {
+	_money = 100
+}
+Wallet -> money() { return this._money }
+var wallet = Wallet{}
+`
+	source := `
+import wallet
+struct Wallet {
+	_money = 0
+}
+Wallet -> steal(other) { return other._money }
+var mine = Wallet{}
+try { print(mine.steal(wallet.wallet)) } catch { print(error.type, " ", error.message) }
+print(wallet.wallet.money(), mine.steal(Wallet{}))
+`
+	expectModulesOutput(t, source, map[string]string{"wallet": wallet}, "PropertyError '_money' is private to Wallet and can only be used in its methods\n1000")
+}
+
+// A module that can not be read or that fails raises an error where it is imported
+func TestImportErrors(t *testing.T) {
+
+	source := `
+try { import "nosuch" as missing } catch { print(error.type) }
+try { import failing } catch { print(error.type, " ", error.message) }
+print("still running")
+`
+	expectModulesOutput(t, source, map[string]string{"failing": "var x = 1 / 0"}, "RuntimeError\nZeroDivisionError Division by zero\nstill running")
+}
+
+// The interactive mode keeps going after an import fails
+func TestReplImportErrors(t *testing.T) {
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "failing.ev"), []byte("var x = 1 / 0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "EVIE_TEST_REPL=1")
+	cmd.Stdin = strings.NewReader("import \"nosuch\" as missing\nimport failing\nprint(\"still running\")\n")
+
+	output, err := cmd.CombinedOutput()
+
+	if err != nil {
+		t.Fatalf("the repl stopped: %v\n%s", err, output)
+	}
+
+	if !strings.Contains(string(output), "still running") {
+		t.Errorf("the repl did not run the input after the imports\n%s", output)
+	}
+}
+
+// Syntax errors are shown in the order of the source, an unknown token does not cause more errors
+func TestSyntaxErrorsInOrder(t *testing.T) {
+
+	source := `var a = (
+var x = @
+print("ok" $ 1)
+var y = (
+`
+	expectDiagnostics(t, source, []string{
+		"line 1, column 10: Unexpected line break",
+		"line 2, column 9: Unknown token '@'",
+		"line 3, column 12: Unknown token '$'",
+		"line 4, column 10: Unexpected line break",
+	})
+}
+
+func TestNameErrors(t *testing.T) {
+
+	source := `print(missing)
+print(later)
+var later = 1
+var dup = 1
+var dup = 2
+fn f() {
+    var inner = 1
+    var inner = 2
+    import "other" as other
+    return inner
+}
+`
+	expectDiagnostics(t, source, []string{
+		"line 1, column 7: 'missing' is not declared",
+		"line 2, column 7: 'later' is used before its declaration",
+		"line 5, column 5: 'dup' is already declared in this scope",
+		"line 8, column 9: 'inner' is already declared in this scope",
+		"line 9, column 5: Modules can only be imported at the top level",
+	})
+}
+
+func TestNameErrorsAreNotSyntaxErrors(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "script")
+
+	if err := os.WriteFile(file+".ev", []byte("print(missing)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, engine := range []string{engineTree, engineVM} {
+		output := runEngine(t, engine, file)
+
+		if !strings.Contains(output, "there are name errors") || strings.Contains(output, "syntax errors") {
+			t.Errorf("%s engine: expected the name errors heading, found\n%s", engine, output)
+		}
+	}
+}
+
+// Checks the diagnostics that running the source shows, in order
+func expectDiagnostics(t *testing.T, source string, expected []string) {
+
+	file := filepath.Join(t.TempDir(), "script")
+
+	if err := os.WriteFile(file+".ev", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := diagnosticLines.FindAllString(runEngine(t, engineTree, file), -1)
+
+	if strings.Join(found, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("found the diagnostics\n%s\nexpected\n%s", strings.Join(found, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+// Runs the source with both engines and checks what they print
+func expectOutput(t *testing.T, source string, expected string) {
+	expectModulesOutput(t, source, nil, expected)
+}
+
+// Like expectOutput, with the modules that the source can import next to it
+func expectModulesOutput(t *testing.T, source string, modules map[string]string, expected string) {
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "script")
+
+	if err := os.WriteFile(file+".ev", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, module := range modules {
+		if err := os.WriteFile(filepath.Join(dir, name+".ev"), []byte(module), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, engine := range []string{engineTree, engineVM} {
+		output := strings.TrimSpace(runEngine(t, engine, file))
+
+		if output != expected {
+			t.Errorf("%s engine printed\n%s\nexpected\n%s", engine, output, expected)
+		}
+	}
+}
+
+// Runs a file in a new process, as the interpreter exits on errors
+func runEngine(t *testing.T, engine string, file string) string {
+
+	// Modules are imported from the directory of the file
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Dir = filepath.Dir(file)
+	cmd.Env = append(os.Environ(), "EVIE_TEST_ENGINE="+engine, "EVIE_TEST_FILE="+filepath.Base(file))
+
+	// print writes the line breaks to stderr, both are needed to see the lines
+	output, err := cmd.CombinedOutput()
+
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Fatal(err)
+	}
+
+	return unstableLines.ReplaceAllString(string(output), "")
+}
+
+// When the test binary is started by runEngine it works as the interpreter, or as the repl without a file
+func TestMain(m *testing.M) {
+
+	if file := os.Getenv("EVIE_TEST_FILE"); file != "" {
+		os.Args = []string{os.Args[0], "-engine=" + os.Getenv("EVIE_TEST_ENGINE"), file}
+		main()
+		os.Exit(0)
+	}
+
+	if os.Getenv("EVIE_TEST_REPL") != "" {
+		os.Args = []string{os.Args[0]}
+		main()
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
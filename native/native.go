@@ -14,46 +14,45 @@ import (
 )
 
 func SetupEnvironment(env *environment.Environment) {
+	// Built in names are constants, they can be shadowed by locals but not assigned
 
-	env.ForceDeclare("RuntimeError", values.StringValue{Value: "RuntimeError"})
-	env.ForceDeclare("TypeError", values.StringValue{Value: "TypeError"})
-	env.ForceDeclare("InvalidIndexError", values.StringValue{Value: "InvalidIndexError"})
-	env.ForceDeclare("IdentifierError", values.StringValue{Value: "IdentifierError"})
-	env.ForceDeclare("ZeroDivisionError", values.StringValue{Value: "ZeroDivisionError"})
-	env.ForceDeclare("InvalidArgumentError", values.StringValue{Value: "InvalidArgumentError"})
-	env.ForceDeclare("InvalidConversionError", values.StringValue{Value: "InvalidConversionError"})
-	env.ForceDeclare("CircularImportError", values.StringValue{Value: "CircularImportError"})
-	env.ForceDeclare("PropertyError", values.StringValue{Value: "PropertyError"})
-
-	env.DeclareVar("ErrorObject", values.StructValue{
+	// The names of the error kinds are the strings that the type of their errors has, like RuntimeError
+	env.ForceDeclareConst("ErrorKind", values.ErrorKinds)
+
+	for _, kind := range values.ErrorKinds.Members {
+		env.ForceDeclareConst(kind.Name, values.StringValue{Value: kind.Name})
+	}
+
+	env.DeclareConst("ErrorObject", values.StructValue{
 		Name:    "ErrorObject",
 		Methods: make(map[string]values.RuntimeValue),
 		Properties: []string{
-			"message", "type",
+			"message", "type", "kind",
 		},
 	})
 
-	env.DeclareVar("input", values.NativeFunctionValue{Value: ReadUserInput})
-	env.DeclareVar("print", values.NativeFunctionValue{Value: PrintStdOut})
-	env.DeclareVar("number", values.NativeFunctionValue{Value: ToNumber})
-	env.DeclareVar("int", values.NativeFunctionValue{Value: ToInteger})
-	env.DeclareVar("string", values.NativeFunctionValue{Value: ToString})
-	env.DeclareVar("bool", values.NativeFunctionValue{Value: ToBool})
-	env.DeclareVar("isNothing", values.NativeFunctionValue{Value: IsNothing})
-	env.DeclareVar("type", values.NativeFunctionValue{Value: Type})
-
-	env.DeclareVar("time", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
+	env.DeclareConst("input", values.NativeFunctionValue{Value: ReadUserInput})
+	env.DeclareConst("print", values.NativeFunctionValue{Value: PrintStdOut})
+	env.DeclareConst("number", values.NativeFunctionValue{Value: ToNumber})
+	env.DeclareConst("int", values.NativeFunctionValue{Value: ToInteger})
+	env.DeclareConst("string", values.NativeFunctionValue{Value: ToString})
+	env.DeclareConst("bool", values.NativeFunctionValue{Value: ToBool})
+	env.DeclareConst("isNothing", values.NativeFunctionValue{Value: IsNothing})
+	env.DeclareConst("type", values.NativeFunctionValue{Value: Type})
+	env.DeclareConst("implements", values.NativeFunctionValue{Value: Implements})
+
+	env.DeclareConst("time", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
 		now := time.Now()
 		milliseconds := now.UnixMilli()
 		return values.NumberValue{Value: float64(milliseconds)}
 	}})
 
-	env.DeclareVar("litter", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
+	env.DeclareConst("litter", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
 		litter.Dump(args[0])
 		return values.BoolValue{Value: true}
 	}})
 
-	env.DeclareVar("panic", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
+	env.DeclareConst("panic", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
 		if len(args) == 0 {
 			return values.ErrorValue{Value: "Why?", ErrorType: values.RuntimeError}
 		}
@@ -73,7 +72,7 @@ func SetupEnvironment(env *environment.Environment) {
 		arguments.Value = append(arguments.Value, values.StringValue{Value: arg})
 	}
 
-	env.DeclareVar("getArgs", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
+	env.DeclareConst("getArgs", values.NativeFunctionValue{Value: func(args []values.RuntimeValue) values.RuntimeValue {
 		return &arguments
 	}})
 
@@ -93,6 +92,21 @@ func IsNothing(args []values.RuntimeValue) values.RuntimeValue {
 	return values.BoolValue{Value: true}
 }
 
+// implements(value, Shape) checks if the value has every method of the interface
+func Implements(args []values.RuntimeValue) values.RuntimeValue {
+	if len(args) < 2 {
+		return values.ErrorValue{Value: "Missing arguments to implements function"}
+	}
+
+	iface, ok := args[1].(values.InterfaceValue)
+
+	if !ok {
+		return values.ErrorValue{ErrorType: values.TypeError, Value: "The second argument of implements must be an interface, not " + args[1].GetType().String()}
+	}
+
+	return values.BoolValue{Value: iface.ImplementedBy(args[0])}
+}
+
 func ToNumber(args []values.RuntimeValue) values.RuntimeValue {
 	if len(args) == 0 {
 		return values.ErrorValue{Value: "Missing argument to number parse function"}
@@ -140,6 +154,10 @@ func ToString(args []values.RuntimeValue) values.RuntimeValue {
 	}
 	value := args[0]
 
+	if object, ok := value.(*values.ObjectValue); ok {
+		return object.ToString()
+	}
+
 	return values.StringValue{Value: value.GetString()}
 }
 
@@ -187,6 +205,8 @@ func PrintValues(args []values.RuntimeValue, verboseStrings bool) {
 			fmt.Print(arg.(values.NumberValue).Value)
 		} else if valType == values.BoolType {
 			fmt.Print(arg.(values.BoolValue).Value)
+		} else if valType == values.RangeType || valType == values.ObjectType || valType == values.EnumType || valType == values.EnumMemberType {
+			fmt.Print(arg.GetString())
 		} else if valType == values.DictionaryType {
 			fmt.Print("{ ")
 			for key, value := range arg.(*values.DictionaryValue).Value {
@@ -204,7 +224,20 @@ func PrintValues(args []values.RuntimeValue, verboseStrings bool) {
 }
 
 func PrintStdOut(args []values.RuntimeValue) values.RuntimeValue {
-	PrintValues(args, false)
+	// Objects are printed with their __str method, if it fails nothing is printed
+	printed := make([]values.RuntimeValue, len(args))
+
+	for i, arg := range args {
+		printed[i] = arg
+		if object, ok := arg.(*values.ObjectValue); ok {
+			printed[i] = object.ToString()
+		}
+		if printed[i].GetType() == values.ErrorType && arg.GetType() != values.ErrorType {
+			return printed[i]
+		}
+	}
+
+	PrintValues(printed, false)
 	print("\n")
 
 	return values.BoolValue{Value: true}
@@ -214,8 +247,11 @@ func Type(args []values.RuntimeValue) values.RuntimeValue {
 	if len(args) == 0 {
 		return values.ErrorValue{Value: "Missing argument for type function"}
 	}
-	if args[0].GetType() == values.ObjectType {
-		return values.StringValue{Value: args[0].(*values.ObjectValue).Struct.Name}
+	switch value := args[0].(type) {
+	case *values.ObjectValue:
+		return values.StringValue{Value: value.Struct.Name}
+	case values.EnumMemberValue:
+		return values.StringValue{Value: value.Enum.Name}
 	}
 	return values.StringValue{Value: args[0].GetType().String()}
 }
@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"evie/lexer"
+	"fmt"
+	"strings"
+)
+
+// A single problem found while parsing
+type Diagnostic struct {
+	Message  string
+	Line     int
+	Column   int
+	Length   int
+	Expected string
+	Found    string
+}
+
+func (d Diagnostic) Error() string {
+
+	msg := d.Message
+
+	if d.Found != "" {
+		msg += " but found " + d.Found
+	}
+
+	return fmt.Sprintf("line %d, column %d: %s", d.Line, d.Column, msg)
+}
+
+// All the problems found while parsing a source, returned by GetAST
+type ParseError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e ParseError) Error() string {
+
+	messages := make([]string, len(e.Diagnostics))
+
+	for i, d := range e.Diagnostics {
+		messages[i] = d.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Used to unwind the parser until the nearest statement boundary
+type parseAbort struct{}
+
+// Returns a human readable description of a token to use in diagnostics
+func describeToken(token lexer.Token) string {
+	switch token.Kind {
+	case lexer.TOKEN_EOF:
+		return "end of file"
+	case lexer.TOKEN_EOL:
+		return "line break"
+	case lexer.TOKEN_STRING:
+		return "string \"" + token.Lexeme + "\""
+	default:
+		return "'" + token.Lexeme + "'"
+	}
+}
+
+// Returns a human readable description of a kind of token to use in diagnostics
+func describeKind(kind lexer.TokenType) string {
+	switch kind {
+	case lexer.TOKEN_IDENTIFIER, lexer.TOKEN_NUMBER, lexer.TOKEN_STRING:
+		return kind.String()
+	case lexer.TOKEN_EOF:
+		return "end of file"
+	case lexer.TOKEN_EOL:
+		return "line break"
+	default:
+		return "'" + kind.String() + "'"
+	}
+}
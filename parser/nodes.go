@@ -3,6 +3,12 @@ package parser
 type NodeType uint8
 type OperatorType uint8
 
+// Nodes keep the Line and Column of their first token and not its offsets.
+// Runtime errors only point at one character and find its line in the source
+// to show the excerpt, the offsets are used by the parser to underline whole
+// tokens in its diagnostics, and an offset in every node would only be a copy
+// of what Line and Column already say.
+
 // INTERFACES
 type Stmt interface {
 	StmtType() NodeType
@@ -18,14 +24,23 @@ const (
 	OperatorMultiply
 	OperatorDivide
 	OperatorModulo
+	OperatorPower
+	OperatorIntDivide
+	OperatorBitAnd
+	OperatorBitOr
+	OperatorBitXor
+	OperatorShiftLeft
+	OperatorShiftRight
 	OperatorAnd
 	OperatorOr
 	OperatorNot
 	OperatorEquals
+	OperatorNotEquals
 	OperatorGreaterThan
 	OperatorLessThan
 	OperatorGreaterOrEqThan
 	OperatorLessOrEqThan
+	OperatorIs
 )
 
 const (
@@ -46,16 +61,23 @@ const (
 	NodeMemberExp
 	NodeSliceExp
 	NodeTernaryExp
+	NodeInterpolationExp
+	NodeRangeExp
 
 	NodeStructDeclaration
+	NodeInterfaceDeclaration
+	NodeEnumDeclaration
 
 	NodeVarDeclaration
 	NodeIfStatement
 	NodeForInStatement
 	NodeLoopStatement
+	NodeWhileStatement
+	NodeMatchStatement
 	NodeFunctionDeclaration
 	NodeAnonFunctionDeclaration
 	NodeReturnStatement
+	NodeYieldStatement
 	NodeBreakStatement
 	NodeContinueStatement
 	NodeStructMethodDeclaration
@@ -83,16 +105,23 @@ var NodeTypeStringLookup = map[NodeType]string{
 	NodeObjectInitExp:           "Object expression",
 	NodeSliceExp:                "Slice expression",
 	NodeTernaryExp:              "Ternary expression",
+	NodeInterpolationExp:        "String",
+	NodeRangeExp:                "Range expression",
 	NodeBinaryComparisonExp:     "Binary expression",
 	NodeBinaryLogicExp:          "Binary expression",
 	NodeStructDeclaration:       "Declaration",
+	NodeInterfaceDeclaration:    "Declaration",
+	NodeEnumDeclaration:         "Declaration",
 	NodeVarDeclaration:          "Declaration",
 	NodeIfStatement:             "If statement",
 	NodeForInStatement:          "For statement",
 	NodeLoopStatement:           "Loop statement",
+	NodeWhileStatement:          "While statement",
+	NodeMatchStatement:          "Match statement",
 	NodeFunctionDeclaration:     "Function declaration",
 	NodeAnonFunctionDeclaration: "Anon function declaration",
 	NodeReturnStatement:         "Return statement",
+	NodeYieldStatement:          "Yield statement",
 	NodeBreakStatement:          "Break statement",
 	NodeContinueStatement:       "Continue statement",
 	NodeStructMethodDeclaration: "Struct method declaration",
@@ -104,6 +133,14 @@ func (nt NodeType) String() string {
 	return NodeTypeStringLookup[nt]
 }
 
+// Where a variable is stored, filled by the resolver
+// Locals are found by Slot in the scope Depth levels up, the rest by name in the module environment
+type Binding struct {
+	Local bool
+	Depth int
+	Slot  int
+}
+
 // EXPRESIONES
 type ExpressionStmtNode struct {
 	Expression Exp
@@ -112,35 +149,62 @@ type ExpressionStmtNode struct {
 func (e ExpressionStmtNode) StmtType() NodeType { return NodeExpStmt }
 
 type NumberNode struct {
-	Value float64
-	Line  int
+	Value  float64
+	Line   int
+	Column int
 }
 
 func (n NumberNode) ExpType() NodeType { return NodeNumber }
 
 type StringNode struct {
-	Value string
-	Line  int
+	Value  string
+	Line   int
+	Column int
 }
 
 func (n StringNode) ExpType() NodeType { return NodeString }
 
+// A string with embedded expressions, "a ${b} c" has the parts "a ", b and " c"
+type InterpolationNode struct {
+	Parts  []Exp
+	Line   int
+	Column int
+}
+
+func (n InterpolationNode) ExpType() NodeType { return NodeInterpolationExp }
+
+// From..To or From..=To when it includes To, Step is nil when not given
+type RangeExpNode struct {
+	From      Exp
+	To        Exp
+	Step      Exp
+	Inclusive bool
+	Line      int
+	Column    int
+}
+
+func (n RangeExpNode) ExpType() NodeType { return NodeRangeExp }
+
 type BooleanNode struct {
-	Value bool
-	Line  int
+	Value  bool
+	Line   int
+	Column int
 }
 
 func (n BooleanNode) ExpType() NodeType { return NodeBoolean }
 
 type IdentifierNode struct {
-	Value string
-	Line  int
+	Value   string
+	Binding *Binding
+	Line    int
+	Column  int
 }
 
 func (n IdentifierNode) ExpType() NodeType { return NodeIdentifier }
 
 type NothingNode struct {
-	Line int
+	Line   int
+	Column int
 }
 
 func (n NothingNode) ExpType() NodeType { return NodeNothing }
@@ -150,15 +214,34 @@ type AssignmentNode struct {
 	Operator string
 	Right    Exp
 	Line     int
+	Column   int
 }
 
 func (n AssignmentNode) ExpType() NodeType { return NodeAssignment }
 
+// Operator applied by a compound assignment like +=, false if it is a plain assignment
+func (n AssignmentNode) CompoundOperator() (OperatorType, bool) {
+	switch n.Operator {
+	case "+=":
+		return OperatorAdd, true
+	case "-=":
+		return OperatorSubtract, true
+	case "*=":
+		return OperatorMultiply, true
+	case "/=":
+		return OperatorDivide, true
+	case "%=":
+		return OperatorModulo, true
+	}
+	return 0, false
+}
+
 type BinaryExpNode struct {
 	Left     Exp
 	Operator OperatorType
 	Right    Exp
 	Line     int
+	Column   int
 }
 
 func (n BinaryExpNode) ExpType() NodeType { return NodeBinaryExp }
@@ -168,6 +251,7 @@ type BinaryComparisonExpNode struct {
 	Operator OperatorType
 	Right    Exp
 	Line     int
+	Column   int
 }
 
 func (n BinaryComparisonExpNode) ExpType() NodeType { return NodeBinaryComparisonExp }
@@ -177,6 +261,7 @@ type BinaryLogicExpNode struct {
 	Operator OperatorType
 	Right    Exp
 	Line     int
+	Column   int
 }
 
 func (n BinaryLogicExpNode) ExpType() NodeType { return NodeBinaryLogicExp }
@@ -185,36 +270,50 @@ type UnaryExpNode struct {
 	Operator string
 	Right    Exp
 	Line     int
+	Column   int
 }
 
 func (n UnaryExpNode) ExpType() NodeType { return NodeUnaryExp }
 
 type CallExpNode struct {
-	Args []Exp
-	Name Exp
-	Line int
+	Args      []Exp
+	NamedArgs []NamedArg
+	Name      Exp
+	Line      int
+	Column    int
+}
+
+// An argument given by the name of the parameter, like verbose in f(x, verbose: true)
+type NamedArg struct {
+	Name   string
+	Value  Exp
+	Line   int
+	Column int
 }
 
 func (n CallExpNode) ExpType() NodeType { return NodeCallExp }
 
 type ArrayExpNode struct {
-	Value []Exp
-	Line  int
+	Value  []Exp
+	Line   int
+	Column int
 }
 
 func (n ArrayExpNode) ExpType() NodeType { return NodeArrayExp }
 
 type IndexAccessExpNode struct {
-	Left  Exp
-	Index Exp
-	Line  int
+	Left   Exp
+	Index  Exp
+	Line   int
+	Column int
 }
 
 func (n IndexAccessExpNode) ExpType() NodeType { return NodeIndexAccessExp }
 
 type DictionaryExpNode struct {
-	Value map[string]Exp
-	Line  int
+	Value  map[string]Exp
+	Line   int
+	Column int
 }
 
 func (n DictionaryExpNode) ExpType() NodeType { return NodeDictionaryExp }
@@ -224,6 +323,7 @@ type ObjectInitExpNode struct {
 	Struct Exp
 	Value  DictionaryExpNode
 	Line   int
+	Column int
 }
 
 func (n ObjectInitExpNode) ExpType() NodeType { return NodeObjectInitExp }
@@ -233,6 +333,7 @@ type MemberExpNode struct {
 	Left   Exp
 	Member string
 	Line   int
+	Column int
 }
 
 func (n MemberExpNode) ExpType() NodeType { return NodeMemberExp }
@@ -240,10 +341,11 @@ func (n MemberExpNode) ExpType() NodeType { return NodeMemberExp }
 // slice
 
 type SliceExpNode struct {
-	Left Exp
-	From Exp
-	To   Exp
-	Line int
+	Left   Exp
+	From   Exp
+	To     Exp
+	Line   int
+	Column int
 }
 
 func (n SliceExpNode) ExpType() NodeType { return NodeSliceExp }
@@ -253,6 +355,7 @@ type TernaryExpNode struct {
 	Left      Exp
 	Right     Exp
 	Line      int
+	Column    int
 }
 
 func (n TernaryExpNode) ExpType() NodeType { return NodeTernaryExp }
@@ -260,53 +363,140 @@ func (n TernaryExpNode) ExpType() NodeType { return NodeTernaryExp }
 // STATEMENTS
 
 type VarDeclarationNode struct {
-	Left     IdentifierNode
+	Left IdentifierNode
+	// Set instead of Left in declarations like var [a, b] = array
+	Pattern *Pattern
+	// Declared with const, its names can not be assigned again
+	Constant bool
 	Operator string
 	Right    Exp
 	Line     int
+	Column   int
 }
 
 func (n VarDeclarationNode) StmtType() NodeType { return NodeVarDeclaration }
 
+// Names declared by the statement
+func (n VarDeclarationNode) Names() []string {
+	names := make([]string, 0)
+	for _, identifier := range n.Identifiers() {
+		names = append(names, identifier.Value)
+	}
+	return names
+}
+
+func (n VarDeclarationNode) Identifiers() []IdentifierNode {
+	if n.Pattern != nil {
+		return n.Pattern.Identifiers()
+	}
+	return []IdentifierNode{n.Left}
+}
+
 type IfStatementNode struct {
 	ElseIf    []IfStatementNode
 	Body      []Stmt
 	ElseBody  []Stmt
 	Condition Exp
 	Line      int
+	Column    int
 }
 
 func (n IfStatementNode) StmtType() NodeType { return NodeIfStatement }
 
+// A function parameter, it can have a default value or collect the rest of the arguments
+type Parameter struct {
+	Name    string
+	Default Exp
+	Rest    bool
+}
+
 type FunctionDeclarationNode struct {
 	Name       string
+	Binding    *Binding
 	Body       []Stmt
-	Parameters []string
+	Parameters []Parameter
+	Generator  bool
 	Line       int
+	Column     int
 }
 
 func (n FunctionDeclarationNode) StmtType() NodeType { return NodeFunctionDeclaration }
 
 type AnonFunctionDeclarationNode struct {
 	Body       []Stmt
-	Parameters []string
+	Parameters []Parameter
+	Generator  bool
 	Line       int
+	Column     int
 }
 
 func (n AnonFunctionDeclarationNode) ExpType() NodeType { return NodeAnonFunctionDeclaration }
 
 type StructDeclarationNode struct {
-	Name       string
+	Name    string
+	Binding *Binding
+	// Struct that it inherits from, empty when it has not
+	Parent        string
+	ParentBinding *Binding
+	// Interfaces that its objects must implement, checked when they are created
+	Interfaces []IdentifierNode
 	Properties []string
-	Line       int
+	// Default value of each property as a function that returns it, nil when it has not
+	Defaults []Exp
+	Statics  []StaticMember
+	Line     int
+	Column   int
+}
+
+// Value declared with static in the body of a struct, it belongs to the struct and not to its objects
+type StaticMember struct {
+	Name  string
+	Value Exp
 }
 
 func (n StructDeclarationNode) StmtType() NodeType { return NodeStructDeclaration }
 
+// interface Shape { area() scale(factor) }
+type InterfaceDeclarationNode struct {
+	Name    string
+	Binding *Binding
+	Methods []InterfaceMethod
+	Line    int
+	Column  int
+}
+
+type InterfaceMethod struct {
+	Name       string
+	Parameters []string
+}
+
+func (n InterfaceDeclarationNode) StmtType() NodeType { return NodeInterfaceDeclaration }
+
+// enum Result { Ok(value), Err(message) }
+type EnumDeclarationNode struct {
+	Name    string
+	Binding *Binding
+	Members []EnumMember
+	Line    int
+	Column  int
+}
+
+type EnumMember struct {
+	Name string
+	// Names of the payload, nil when the member does not take one
+	Fields []string
+}
+
+func (n EnumDeclarationNode) StmtType() NodeType { return NodeEnumDeclaration }
+
 type StructMethodDeclarationNode struct {
-	Struct   string
-	Function FunctionDeclarationNode
-	Line     int
+	Struct        string
+	StructBinding *Binding
+	Function      FunctionDeclarationNode
+	// Static functions are called on the struct, like Person.create(), and have no this
+	Static bool
+	Line   int
+	Column int
 }
 
 func (n StructMethodDeclarationNode) StmtType() NodeType { return NodeStructMethodDeclaration }
@@ -316,50 +506,153 @@ type ForInSatementNode struct {
 	Body         []Stmt
 	IndexVarName string
 	LocalVarName string
-	Line         int
+	IndexBinding *Binding
+	LocalBinding *Binding
+	// Set instead of LocalVarName in loops like for [key, value] in pairs
+	Pattern *Pattern
+	Line    int
+	Column  int
 }
 
 func (n ForInSatementNode) StmtType() NodeType { return NodeForInStatement }
 
 type BreakNode struct {
-	Line int
+	Line   int
+	Column int
 }
 
 func (n BreakNode) StmtType() NodeType { return NodeBreakStatement }
 
 type ContinueNode struct {
-	Line int
+	Line   int
+	Column int
 }
 
 func (n ContinueNode) StmtType() NodeType { return NodeContinueStatement }
 
 type ReturnNode struct {
-	Right Exp
-	Line  int
+	Right  Exp
+	Line   int
+	Column int
 }
 
 func (n ReturnNode) StmtType() NodeType { return NodeReturnStatement }
 
+type YieldNode struct {
+	Value  Exp
+	Line   int
+	Column int
+}
+
+func (n YieldNode) StmtType() NodeType { return NodeYieldStatement }
+
 type TryCatchNode struct {
-	Body    []Stmt
-	Catch   []Stmt
-	Finally []Stmt
-	Line    int
+	Body         []Stmt
+	Catch        []Stmt
+	Finally      []Stmt
+	ErrorBinding *Binding
+	Line         int
+	Column       int
 }
 
 func (n TryCatchNode) StmtType() NodeType { return NodeTryCatchStatement }
 
 type LoopStmtNode struct {
-	Body []Stmt
-	Line int
+	Body   []Stmt
+	Line   int
+	Column int
 }
 
 func (n LoopStmtNode) StmtType() NodeType { return NodeLoopStatement }
 
+type WhileStmtNode struct {
+	Condition Exp
+	Body      []Stmt
+	Line      int
+	Column    int
+}
+
+func (n WhileStmtNode) StmtType() NodeType { return NodeWhileStatement }
+
+type MatchStmtNode struct {
+	Value  Exp
+	Arms   []MatchArm
+	Line   int
+	Column int
+}
+
+func (n MatchStmtNode) StmtType() NodeType { return NodeMatchStatement }
+
+// The first arm whose pattern matches and whose guard is true runs its body
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Exp
+	Body    []Stmt
+}
+
+type PatternKind uint8
+
+const (
+	PatternWildcard    PatternKind = iota // _
+	PatternBinding                        // A name that takes the value
+	PatternLiteral                        // A number, string, boolean, nothing or a member like Colors.red
+	PatternAlternative                    // "a" | "b"
+	PatternArray                          // [first, ...rest]
+	PatternDictionary                     // {key: pattern}
+	PatternObject                         // Person{name: pattern}
+	PatternEnum                           // Result.Ok(pattern), a member of an enum with the patterns of its payload
+)
+
+// Shape of a value, the names in it are declared when the value matches
+type Pattern struct {
+	Kind PatternKind
+	// Declared name, or struct of an object pattern
+	Name    string
+	Binding *Binding
+	// Literal value
+	Value Exp
+	// Alternatives, elements of an array or values of the keys
+	Items []Pattern
+	Keys  []string
+	// Rest of an array, nil when it has not
+	Rest   *Pattern
+	Line   int
+	Column int
+}
+
+// Checks if the pattern declares some name
+func (p Pattern) Binds() bool {
+	if p.Kind == PatternBinding || p.Rest != nil && p.Rest.Binds() {
+		return true
+	}
+	for _, item := range p.Items {
+		if item.Binds() {
+			return true
+		}
+	}
+	return false
+}
+
+// Variables declared by the pattern, in order
+func (p Pattern) Identifiers() []IdentifierNode {
+	identifiers := make([]IdentifierNode, 0)
+	if p.Kind == PatternBinding {
+		identifiers = append(identifiers, IdentifierNode{Value: p.Name, Binding: p.Binding, Line: p.Line, Column: p.Column})
+	}
+	for _, item := range p.Items {
+		identifiers = append(identifiers, item.Identifiers()...)
+	}
+	if p.Rest != nil {
+		identifiers = append(identifiers, p.Rest.Identifiers()...)
+	}
+	return identifiers
+}
+
 type ImportNode struct {
-	Line  int
-	Path  string
-	Alias string
+	Line   int
+	Column int
+	Path   string
+	Alias  string
 }
 
 func (n ImportNode) StmtType() NodeType { return NodeImportStatement }
@@ -1,9 +1,10 @@
 package parser
 
 import (
+	"errors"
 	"evie/lexer"
 	"fmt"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -12,19 +13,48 @@ import (
 type ParserContext struct {
 	AvoidStructInit bool
 	Debug           bool
+
+	// Set while parsing the body of a function, Yields is true when it has a yield statement
+	InFunction bool
+	Yields     bool
 }
 
 // Parser
 type Parser struct {
-	t       TokenIterator
-	context ParserContext
+	t           TokenIterator
+	context     ParserContext
+	diagnostics []Diagnostic
+
+	// Index of the first token of the statement being parsed
+	stmtStart int
 }
 
 func NewParser(tokens []lexer.Token) Parser {
 	return Parser{t: TokenIterator{Items: tokens}, context: ParserContext{AvoidStructInit: false, Debug: false}}
 }
 
-func (p Parser) GetAST() []Stmt {
+// Reads and parses a source, the characters the lexer could not read are
+// reported as diagnostics together with the ones found by the parser
+func Parse(source string) ([]Stmt, error) {
+
+	tokens, err := lexer.Tokenize(source)
+
+	p := NewParser(tokens)
+
+	var lexErrs lexer.Errors
+
+	if errors.As(err, &lexErrs) {
+		for _, e := range lexErrs {
+			p.diagnostics = append(p.diagnostics, Diagnostic{Message: e.Message, Line: e.Line, Column: e.Column, Length: e.Length})
+		}
+	}
+
+	return p.GetAST()
+}
+
+// Parses all the tokens, when something is wrong the parser skips to the next statement
+// and keeps going, so all the problems found are returned together as a ParseError
+func (p Parser) GetAST() ([]Stmt, error) {
 	// empty array
 	ast := make([]Stmt, 0)
 
@@ -34,14 +64,166 @@ func (p Parser) GetAST() []Stmt {
 			break
 		}
 
-		parsed := p.ParseStmt()
+		parsed := p.ParseStmtRecovering()
 
 		if parsed != nil {
 			ast = append(ast, parsed)
 		}
 	}
 
-	return ast
+	if len(p.diagnostics) > 0 {
+		// The ones of the lexer come first, they are shown in the order of the source
+		sort.SliceStable(p.diagnostics, func(i, j int) bool {
+			a, b := p.diagnostics[i], p.diagnostics[j]
+			return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+		})
+		return ast, ParseError{Diagnostics: p.diagnostics}
+	}
+
+	return ast, nil
+}
+
+// Parses a statement, if it fails the error is recorded and the tokens until the next
+// statement boundary are skipped, returning nil
+func (p *Parser) ParseStmtRecovering() (stmt Stmt) {
+
+	start := p.t.Index
+	context := p.context
+
+	outer := p.stmtStart
+	p.stmtStart = start
+
+	defer func() {
+		p.stmtStart = outer
+
+		if r := recover(); r != nil {
+			if _, ok := r.(parseAbort); !ok {
+				panic(r)
+			}
+			p.context = context
+			p.synchronize(start)
+			stmt = nil
+		}
+	}()
+
+	return p.ParseStmt()
+}
+
+// Skips tokens until the end of the statement that started at the given index.
+// A statement ends with a line break or with the brace that closes its block,
+// the closing brace of the enclosing block is left for the block parser
+func (p *Parser) synchronize(start int) {
+
+	if p.t.Index == start && p.t.Get().Kind != lexer.TOKEN_EOF {
+		p.t.Eat()
+	}
+
+	depth := 0
+
+	for i := start; i < p.t.Index && i < len(p.t.Items); i++ {
+		if p.t.Items[i].Kind == lexer.TOKEN_LBRACE {
+			depth++
+		} else if p.t.Items[i].Kind == lexer.TOKEN_RBRACE {
+			depth--
+		}
+	}
+
+	if depth < 0 {
+		depth = 0
+	}
+
+	// The line break was already consumed
+	if depth == 0 && p.t.Items[p.t.Index-1].Kind == lexer.TOKEN_EOL {
+		return
+	}
+
+	for {
+		token := p.t.Get()
+
+		switch token.Kind {
+		case lexer.TOKEN_EOF:
+			return
+		case lexer.TOKEN_EOL:
+			if depth == 0 {
+				p.t.Eat()
+				return
+			}
+		case lexer.TOKEN_LBRACE:
+			depth++
+		case lexer.TOKEN_RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 {
+				p.t.Eat()
+				return
+			}
+		}
+
+		p.t.Eat()
+	}
+}
+
+// Records a diagnostic at the given token without stopping the parser
+func (p *Parser) Report(token lexer.Token, msg string) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Message: msg,
+		Line:    token.Line,
+		Column:  token.Column,
+		Length:  token.EndOffset - token.Offset,
+	})
+}
+
+// Records a diagnostic at the given token and abandons the actual statement
+// A statement with an unknown token was already reported by the lexer, what
+// fails after it is only a consequence and is not reported
+func (p *Parser) FailAt(token lexer.Token, msg string) {
+	if !p.hasUnknownToken() {
+		p.Report(token, msg)
+	}
+	panic(parseAbort{})
+}
+
+// Checks if there is an unknown token between the start of the statement and the actual token
+func (p *Parser) hasUnknownToken() bool {
+	for i := p.stmtStart; i <= p.t.Index && i < len(p.t.Items); i++ {
+		if p.t.Items[i].Kind == lexer.TOKEN_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// Records a diagnostic at the actual token and abandons the actual statement
+func (p *Parser) Fail(msg string) {
+	p.FailAt(p.t.Get(), msg)
+}
+
+// Consumes the actual token if it is of the given kind, otherwise the statement is abandoned
+func (p *Parser) Expect(kind lexer.TokenType, context string) lexer.Token {
+
+	token := p.t.Get()
+
+	if token.Kind != kind {
+		if p.hasUnknownToken() {
+			panic(parseAbort{})
+		}
+
+		expected := describeKind(kind)
+
+		p.diagnostics = append(p.diagnostics, Diagnostic{
+			Message:  strings.TrimSpace("Expected " + expected + " " + context),
+			Line:     token.Line,
+			Column:   token.Column,
+			Length:   token.EndOffset - token.Offset,
+			Expected: expected,
+			Found:    describeToken(token),
+		})
+		panic(parseAbort{})
+	}
+
+	return p.t.Eat()
 }
 
 func (p *Parser) ParseStmt() Stmt {
@@ -56,7 +238,7 @@ func (p *Parser) ParseStmt() Stmt {
 
 	token := p.t.Get()
 
-	if token.Kind == lexer.TOKEN_VAR {
+	if token.Kind == lexer.TOKEN_VAR || token.Kind == lexer.TOKEN_CONST {
 		return p.ParseVarDeclaration()
 	} else if token.Kind == lexer.TOKEN_TRY {
 		return p.ParseTryStmt()
@@ -66,6 +248,10 @@ func (p *Parser) ParseStmt() Stmt {
 		return p.ParseImportStmt()
 	} else if token.Kind == lexer.TOKEN_LOOP {
 		return p.ParseLoopStmt()
+	} else if token.Kind == lexer.TOKEN_WHILE {
+		return p.ParseWhileStmt()
+	} else if token.Kind == lexer.TOKEN_MATCH {
+		return p.ParseMatchStmt()
 	} else if token.Kind == lexer.TOKEN_FN && p.t.GetNext().Kind == lexer.TOKEN_IDENTIFIER {
 		p.t.Eat()
 		return p.ParseFunctionDeclaration()
@@ -77,10 +263,17 @@ func (p *Parser) ParseStmt() Stmt {
 		return p.ParseContinueStmt()
 	} else if token.Kind == lexer.TOKEN_RETURN {
 		return p.ParseReturnStmt()
+	} else if token.Kind == lexer.TOKEN_YIELD {
+		return p.ParseYieldStmt()
 	} else if token.Kind == lexer.TOKEN_IDENTIFIER && p.t.GetNext().Kind == lexer.TOKEN_LARROW {
 		return p.ParseStructMethodDeclaration()
 	} else if token.Kind == lexer.TOKEN_STRUCT {
 		return p.ParseStructDeclaration()
+	} else if token.Lexeme == "interface" && p.t.GetNext().Kind == lexer.TOKEN_IDENTIFIER {
+		// interface and enum are only keywords before a name, so they can still be used as variables
+		return p.ParseInterfaceDeclaration()
+	} else if token.Lexeme == "enum" && p.t.GetNext().Kind == lexer.TOKEN_IDENTIFIER {
+		return p.ParseEnumDeclaration()
 	} else {
 
 		return p.ParseExpressionStmt()
@@ -88,22 +281,48 @@ func (p *Parser) ParseStmt() Stmt {
 
 }
 
+// Parses a list of statements between braces, the context is used in the diagnostics
+func (p *Parser) ParseBlock(context string) []Stmt {
+
+	body := make([]Stmt, 0)
+
+	p.Expect(lexer.TOKEN_LBRACE, "in "+context)
+
+	for {
+
+		if p.t.Get().Kind == lexer.TOKEN_EOL {
+			p.t.Eat()
+			continue
+		}
+		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
+			break
+		}
+
+		stmt := p.ParseStmtRecovering()
+
+		if stmt != nil {
+			body = append(body, stmt)
+		}
+	}
+
+	p.Expect(lexer.TOKEN_RBRACE, "in "+context)
+
+	return body
+}
+
 func (p *Parser) ParseImportStmt() ImportNode {
 	node := ImportNode{}
-	node.Line = p.t.Eat().Line
+	node.Line, node.Column = position(p.t.Eat())
 
 	if p.t.Get().Kind != lexer.TOKEN_IDENTIFIER && p.t.Get().Kind != lexer.TOKEN_STRING {
-		Stop("Expected module name but found: " + lexer.GetTokenName(p.t.Get().Kind) + " in line " + fmt.Sprint(node.Line))
+		p.Fail("Expected module name but found " + describeToken(p.t.Get()))
 	}
 
 	node.Path = p.t.Eat().Lexeme
 
 	if p.t.Get().Kind == lexer.TOKEN_AS {
 		p.t.Eat()
-		if p.t.Get().Kind != lexer.TOKEN_IDENTIFIER {
-			Stop("Expected identifier after 'as' in line " + fmt.Sprint(node.Line))
-		}
-		node.Alias = p.t.Eat().Lexeme
+		node.Alias = p.Expect(lexer.TOKEN_IDENTIFIER, "after 'as'").Lexeme
 	} else {
 		node.Alias = node.Path
 
@@ -118,129 +337,285 @@ func (p *Parser) ParseImportStmt() ImportNode {
 
 func (p *Parser) ParseLoopStmt() LoopStmtNode {
 	node := LoopStmtNode{}
-	node.Line = p.t.Eat().Line
+	token := p.t.Eat()
+	node.Line, node.Column = token.Line, token.Column
+
+	node.Body = p.ParseBlock("loop statement")
 
-	node.Body = make([]Stmt, 0)
+	if len(node.Body) == 0 {
+		p.Report(token, "Empty loop statement")
+	}
+	return node
+}
+
+func (p *Parser) ParseWhileStmt() WhileStmtNode {
+	node := WhileStmtNode{}
+	token := p.t.Eat()
+	node.Line, node.Column = token.Line, token.Column
+
+	p.context.AvoidStructInit = true
+	node.Condition = p.ParseExp()
+	p.context.AvoidStructInit = false
+
+	node.Body = p.ParseBlock("while statement")
 
-	if p.t.Get().Kind != lexer.TOKEN_LBRACE {
-		Stop("Expected '{' in loop statement in line " + fmt.Sprint(p.t.Get().Line))
+	if len(node.Body) == 0 {
+		p.Report(token, "Empty loop statement")
 	}
-	p.t.Eat() // {
+	return node
+}
+
+func (p *Parser) ParseMatchStmt() MatchStmtNode {
+	node := MatchStmtNode{}
+	token := p.t.Eat()
+	node.Line, node.Column = token.Line, token.Column
+
+	p.context.AvoidStructInit = true
+	node.Value = p.ParseExp()
+	p.context.AvoidStructInit = false
+
+	p.Expect(lexer.TOKEN_LBRACE, "in match statement")
 
 	for {
+		kind := p.t.Get().Kind
 
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
+		if kind == lexer.TOKEN_EOL || kind == lexer.TOKEN_COMMA {
 			p.t.Eat()
 			continue
 		}
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
+		if kind == lexer.TOKEN_RBRACE || kind == lexer.TOKEN_EOF {
 			break
 		}
 
-		node.Body = append(node.Body, p.ParseStmt())
-	}
+		arm := MatchArm{Pattern: p.ParsePattern()}
 
-	if p.t.Get().Kind == lexer.TOKEN_EOL {
-		p.t.Eat()
-	}
+		if p.t.Get().Kind == lexer.TOKEN_IF {
+			p.t.Eat()
+			arm.Guard = p.ParseExp()
+		}
 
-	if p.t.Get().Kind == lexer.TOKEN_RBRACE {
-		p.t.Eat()
-	} else {
-		Stop("Expected '}' in loop statement in line " + fmt.Sprint(p.t.Get().Line))
+		p.Expect(lexer.TOKEN_FAT_ARROW, "after pattern in match statement")
+
+		// The body is a block or a single statement
+		if p.t.Get().Kind == lexer.TOKEN_LBRACE {
+			arm.Body = p.ParseBlock("match statement")
+		} else {
+			arm.Body = []Stmt{p.ParseStmt()}
+		}
+
+		node.Arms = append(node.Arms, arm)
 	}
 
-	if len(node.Body) == 0 {
-		Stop("Empty loop statement in line " + fmt.Sprint(p.t.Get().Line))
+	p.Expect(lexer.TOKEN_RBRACE, "in match statement")
+
+	if len(node.Arms) == 0 {
+		p.Report(token, "Empty match statement")
 	}
 	return node
 }
 
-func (p *Parser) ParseTryStmt() TryCatchNode {
-	node := TryCatchNode{}
-	node.Line = p.t.Eat().Line
+// Parses a pattern, with its alternatives separated by |
+func (p *Parser) ParsePattern() Pattern {
+	start := p.t.Get()
+	first := p.parsePrimaryPattern()
 
-	node.Body = make([]Stmt, 0)
+	if p.t.Get().Kind != lexer.TOKEN_OPERATOR || !p.t.Is("|") {
+		return first
+	}
 
-	if p.t.Get().Kind != lexer.TOKEN_LBRACE {
-		Stop("Expected '{' in try statement in line " + fmt.Sprint(p.t.Get().Line))
+	pattern := Pattern{Kind: PatternAlternative, Items: []Pattern{first}, Line: first.Line, Column: first.Column}
+
+	for p.t.Get().Kind == lexer.TOKEN_OPERATOR && p.t.Is("|") {
+		p.t.Eat()
+		pattern.Items = append(pattern.Items, p.parsePrimaryPattern())
 	}
 
-	p.t.Eat() // {
+	// Only one of them matches, so the names of the others would not exist
+	if pattern.Binds() {
+		p.Report(start, "Alternative patterns can not declare variables")
+	}
 
-	for {
+	return pattern
+}
 
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
-			p.t.Eat()
-			continue
+func (p *Parser) parsePrimaryPattern() Pattern {
+	token := p.t.Get()
+	line, column := position(token)
+
+	switch token.Kind {
+	case lexer.TOKEN_NUMBER, lexer.TOKEN_STRING, lexer.TOKEN_BOOLEAN, lexer.TOKEN_NOTHING:
+		return Pattern{Kind: PatternLiteral, Value: p.parsePrimaryExp(), Line: line, Column: column}
+	case lexer.TOKEN_OPERATOR:
+		if token.Lexeme == "-" && p.t.GetNext().Kind == lexer.TOKEN_NUMBER {
+			return Pattern{Kind: PatternLiteral, Value: p.parseUnaryExp(), Line: line, Column: column}
 		}
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-			break
+	case lexer.TOKEN_IDENTIFIER:
+
+		// Members like Colors.red are compared by value
+		if p.t.GetNext().Kind == lexer.TOKEN_DOT {
+			return p.parseMemberPattern()
 		}
 
-		node.Body = append(node.Body, p.ParseStmt())
-	}
+		p.t.Eat()
 
-	if p.t.Get().Kind != lexer.TOKEN_RBRACE {
-		Stop("Expected '}' in try statement in line " + fmt.Sprint(p.t.Get().Line))
-	}
-	p.t.Eat()
+		if token.Lexeme == "_" {
+			return Pattern{Kind: PatternWildcard, Line: line, Column: column}
+		}
 
-	if p.t.Eat().Kind != lexer.TOKEN_CATCH {
-		Stop("Expected catch after try statement in line " + fmt.Sprint(p.t.Get().Line))
-	} else {
+		if p.t.Get().Kind == lexer.TOKEN_LBRACE {
+			pattern := Pattern{Kind: PatternObject, Name: token.Lexeme, Binding: &Binding{}, Line: line, Column: column}
+			pattern.Keys, pattern.Items = p.parseKeyPatterns("struct pattern")
+			return pattern
+		}
+
+		return Pattern{Kind: PatternBinding, Name: token.Lexeme, Binding: &Binding{}, Line: line, Column: column}
+	case lexer.TOKEN_LBRACE:
+		pattern := Pattern{Kind: PatternDictionary, Line: line, Column: column}
+		pattern.Keys, pattern.Items = p.parseKeyPatterns("dictionary pattern")
+		return pattern
+	case lexer.TOKEN_LBRACKET:
 		p.t.Eat()
-	}
+		pattern := Pattern{Kind: PatternArray, Line: line, Column: column}
 
-	node.Catch = make([]Stmt, 0)
+		for p.skipLineBreaks() != lexer.TOKEN_RBRACKET {
 
-	for {
+			if p.t.Get().Kind == lexer.TOKEN_ELLIPSIS {
+				dots := p.t.Eat()
+				name := p.Expect(lexer.TOKEN_IDENTIFIER, "after '...' in array pattern")
+				rest := Pattern{Kind: PatternBinding, Name: name.Lexeme, Binding: &Binding{}, Line: name.Line, Column: name.Column}
+				if name.Lexeme == "_" {
+					rest.Kind = PatternWildcard
+				}
+				if pattern.Rest != nil {
+					p.Report(dots, "An array pattern can only have one rest")
+				}
+				pattern.Rest = &rest
+			} else {
+				if pattern.Rest != nil {
+					p.Report(p.t.Get(), "The rest must be the last element of an array pattern")
+				}
+				pattern.Items = append(pattern.Items, p.ParsePattern())
+			}
 
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
+			if p.t.Get().Kind != lexer.TOKEN_COMMA {
+				break
+			}
 			p.t.Eat()
-			continue
-		}
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-			break
 		}
 
-		node.Catch = append(node.Catch, p.ParseStmt())
+		p.skipLineBreaks()
+		p.Expect(lexer.TOKEN_RBRACKET, "to close the array pattern")
+		return pattern
 	}
-	if p.t.Get().Kind != lexer.TOKEN_RBRACE {
-		Stop("Expected '}' in catch statement in line " + fmt.Sprint(p.t.Get().Line))
+
+	p.Fail("Expected a pattern but found " + describeToken(token))
+	return Pattern{}
+}
+
+// Colors.red is compared by value and Result.Ok(value) also matches the payload of the member
+func (p *Parser) parseMemberPattern() Pattern {
+	token := p.t.Eat()
+	line, column := position(token)
+
+	var value Exp = IdentifierNode{Value: token.Lexeme, Binding: &Binding{}, Line: line, Column: column}
+
+	for p.t.Get().Kind == lexer.TOKEN_DOT {
+		dot := p.t.Eat()
+		value = MemberExpNode{Left: value, Member: p.Expect(lexer.TOKEN_IDENTIFIER, "after '.'").Lexeme, Line: dot.Line, Column: dot.Column}
+	}
+
+	if p.t.Get().Kind != lexer.TOKEN_LPAR {
+		return Pattern{Kind: PatternLiteral, Value: value, Line: line, Column: column}
 	}
+
 	p.t.Eat()
+	pattern := Pattern{Kind: PatternEnum, Value: value, Items: []Pattern{}, Line: line, Column: column}
 
-	if p.t.Get().Kind == lexer.TOKEN_FINALLY {
+	for p.skipLineBreaks() != lexer.TOKEN_RPAR {
+		pattern.Items = append(pattern.Items, p.ParsePattern())
+
+		if p.t.Get().Kind != lexer.TOKEN_COMMA {
+			break
+		}
 		p.t.Eat()
+	}
 
-		node.Finally = make([]Stmt, 0)
+	p.skipLineBreaks()
+	p.Expect(lexer.TOKEN_RPAR, "to close the enum pattern")
+	return pattern
+}
 
-		if p.t.Get().Kind == lexer.TOKEN_LBRACE {
-			p.t.Eat()
-		} else {
-			Stop("Expected '{' in finally statement in line " + fmt.Sprint(p.t.Get().Line))
-		}
+// Arrays, dictionaries, objects and enum members can be destructured: [a, b], {a, b}, Person{a, b} or Result.Ok(a)
+func (p *Parser) startsDestructuring() bool {
+	switch p.t.Get().Kind {
+	case lexer.TOKEN_LBRACKET, lexer.TOKEN_LBRACE:
+		return true
+	case lexer.TOKEN_IDENTIFIER:
+		return p.t.GetNext().Kind == lexer.TOKEN_LBRACE || p.t.GetNext().Kind == lexer.TOKEN_DOT
+	}
+	return false
+}
 
-		for {
-			if p.t.Get().Kind == lexer.TOKEN_EOL {
-				p.t.Eat()
-				continue
-			}
+// Parses {key: pattern, ...}, a key alone declares a variable with its name
+func (p *Parser) parseKeyPatterns(context string) ([]string, []Pattern) {
+	keys := make([]string, 0)
+	items := make([]Pattern, 0)
 
-			if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-				break
-			}
+	p.Expect(lexer.TOKEN_LBRACE, "in "+context)
+
+	for p.skipLineBreaks() != lexer.TOKEN_RBRACE {
 
-			node.Finally = append(node.Finally, p.ParseStmt())
+		key := p.t.Get()
+		if key.Kind != lexer.TOKEN_IDENTIFIER && key.Kind != lexer.TOKEN_STRING {
+			p.Fail("Expected key in " + context + " but found " + describeToken(key))
 		}
+		p.t.Eat()
 
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE {
+		keys = append(keys, key.Lexeme)
+
+		if p.t.Get().Kind == lexer.TOKEN_COLON {
 			p.t.Eat()
+			items = append(items, p.ParsePattern())
+		} else if key.Kind == lexer.TOKEN_IDENTIFIER {
+			items = append(items, Pattern{Kind: PatternBinding, Name: key.Lexeme, Binding: &Binding{}, Line: key.Line, Column: key.Column})
 		} else {
-			Stop("Expected '}' in finally statement in line " + fmt.Sprint(p.t.Get().Line))
+			p.Fail("Expected ':' after key " + describeToken(key) + " in " + context)
 		}
 
+		if p.t.Get().Kind != lexer.TOKEN_COMMA {
+			break
+		}
+		p.t.Eat()
+	}
+
+	p.skipLineBreaks()
+	p.Expect(lexer.TOKEN_RBRACE, "in "+context)
+
+	return keys, items
+}
+
+// Skips the line breaks and returns the kind of the next token
+func (p *Parser) skipLineBreaks() lexer.TokenType {
+	for p.t.Get().Kind == lexer.TOKEN_EOL {
+		p.t.Eat()
+	}
+	return p.t.Get().Kind
+}
+
+func (p *Parser) ParseTryStmt() TryCatchNode {
+	node := TryCatchNode{ErrorBinding: &Binding{}}
+	node.Line, node.Column = position(p.t.Eat())
+
+	node.Body = p.ParseBlock("try statement")
+
+	p.Expect(lexer.TOKEN_CATCH, "after try statement")
+
+	node.Catch = p.ParseBlock("catch statement")
+
+	if p.t.Get().Kind == lexer.TOKEN_FINALLY {
+		p.t.Eat()
+
+		node.Finally = p.ParseBlock("finally statement")
 	}
 
 	return node
@@ -248,51 +623,79 @@ func (p *Parser) ParseTryStmt() TryCatchNode {
 
 func (p *Parser) ParseReturnStmt() ReturnNode {
 	node := ReturnNode{}
-	node.Line = p.t.Eat().Line
+	node.Line, node.Column = position(p.t.Eat())
 	node.Right = p.ParseExp()
 
 	return node
 }
+func (p *Parser) ParseYieldStmt() YieldNode {
+	token := p.t.Eat()
+
+	if !p.context.InFunction {
+		p.Report(token, "yield can only be used inside a function")
+	}
+	p.context.Yields = true
+
+	node := YieldNode{Line: token.Line, Column: token.Column}
+	node.Value = p.ParseExp()
+
+	return node
+}
+
 func (p *Parser) ParseBreakStmt() BreakNode {
-	return BreakNode{Line: p.t.Eat().Line}
+	token := p.t.Eat()
+	return BreakNode{Line: token.Line, Column: token.Column}
 }
 
 func (p *Parser) ParseContinueStmt() ContinueNode {
-	return ContinueNode{Line: p.t.Eat().Line}
+	token := p.t.Eat()
+	return ContinueNode{Line: token.Line, Column: token.Column}
 }
 
 func (p *Parser) ParseForInStmt() ForInSatementNode {
-	node := ForInSatementNode{}
+	node := ForInSatementNode{IndexBinding: &Binding{}, LocalBinding: &Binding{}}
 
-	node.Body = make([]Stmt, 0)
+	token := p.t.Eat()
+	node.Line, node.Column = token.Line, token.Column
 
-	node.Line = p.t.Eat().Line
+	var firstVar string = ""
 
-	if p.t.Get().Kind != lexer.TOKEN_IDENTIFIER {
-		Stop("Expected at least one identifier after 'for' keyword in for-in statement in line " + fmt.Sprint(p.t.Get().Line))
+	if p.startsDestructuring() {
+		pattern := p.parsePrimaryPattern()
+		node.Pattern = &pattern
+	} else {
+		firstVar = p.Expect(lexer.TOKEN_IDENTIFIER, "after 'for' keyword in for-in statement").Lexeme
 	}
 
-	firstVar := p.t.Eat().Lexeme
-
 	var secondVar string = ""
 
 	if p.t.Get().Kind == lexer.TOKEN_COMMA {
-		p.t.Eat()
-		secondVar = p.t.Eat().Lexeme
+		comma := p.t.Eat()
+
+		// Only the element can be destructured, the index is always a name
+		if node.Pattern != nil {
+			p.Report(comma, "The index of a for-in statement must be a name")
+		}
+
+		if p.startsDestructuring() {
+			pattern := p.parsePrimaryPattern()
+			node.Pattern = &pattern
+			secondVar = firstVar
+		} else {
+			secondVar = p.Expect(lexer.TOKEN_IDENTIFIER, "after ',' in for-in statement").Lexeme
+		}
 	}
 
-	if secondVar != "" {
+	if secondVar != "" && node.Pattern != nil {
+		node.IndexVarName = secondVar
+	} else if secondVar != "" {
 		node.IndexVarName = firstVar
 		node.LocalVarName = secondVar
 	} else {
 		node.LocalVarName = firstVar
 	}
 
-	if p.t.Get().Kind == lexer.TOKEN_IN {
-		p.t.Eat()
-	} else {
-		Stop("Expected 'in' keyword in for in statement in line " + fmt.Sprint(p.t.Get().Line))
-	}
+	p.Expect(lexer.TOKEN_IN, "in for-in statement")
 
 	p.context.AvoidStructInit = true
 	iterator := p.ParseExp()
@@ -300,189 +703,304 @@ func (p *Parser) ParseForInStmt() ForInSatementNode {
 
 	node.Iterator = iterator
 
-	if p.t.Eat().Kind != lexer.TOKEN_LBRACE {
-		Stop("Expected '{' in for in statement in line " + fmt.Sprint(p.t.Get().Line))
+	node.Body = p.ParseBlock("for-in statement")
+
+	if len(node.Body) == 0 {
+		p.Report(token, "Empty loop statement")
 	}
+	return node
+}
 
-	for {
+func (p *Parser) ParseStructMethodDeclaration() StructMethodDeclarationNode {
+	node := StructMethodDeclarationNode{StructBinding: &Binding{}}
+
+	structNameToken := p.t.Eat()
+	node.Line, node.Column = structNameToken.Line, structNameToken.Column
+
+	node.Struct = structNameToken.Lexeme
+
+	p.t.Eat() // ->
+
+	// static is only a keyword before the name of the method
+	if p.t.Is("static") && p.t.GetNext().Kind == lexer.TOKEN_IDENTIFIER {
+		p.t.Eat()
+		node.Static = true
+	}
+
+	node.Function = p.ParseFunctionDeclaration()
 
+	return node
+}
+
+func (p *Parser) ParseStructDeclaration() StructDeclarationNode {
+	line, column := position(p.t.Eat()) // struct keyword
+
+	var node StructDeclarationNode = StructDeclarationNode{Binding: &Binding{}}
+
+	node.Line, node.Column = line, column
+
+	// struct name
+	node.Name = p.Expect(lexer.TOKEN_IDENTIFIER, "after 'struct' keyword").Lexeme
+	node.Properties = make([]string, 0)
+
+	// struct Employee : Person
+	if p.t.Get().Kind == lexer.TOKEN_COLON {
+		p.t.Eat()
+		node.Parent = p.Expect(lexer.TOKEN_IDENTIFIER, "after ':' in struct declaration").Lexeme
+		node.ParentBinding = &Binding{}
+	}
+
+	// struct Square implements Shape, Drawable
+	if p.t.Is("implements") && p.t.GetNext().Kind == lexer.TOKEN_IDENTIFIER {
+		p.t.Eat()
+		for {
+			name := p.Expect(lexer.TOKEN_IDENTIFIER, "after 'implements' in struct declaration")
+			node.Interfaces = append(node.Interfaces, IdentifierNode{Value: name.Lexeme, Binding: &Binding{}, Line: name.Line, Column: name.Column})
+
+			if p.t.Get().Kind != lexer.TOKEN_COMMA {
+				break
+			}
+			p.t.Eat()
+		}
+	}
+
+	p.Expect(lexer.TOKEN_LBRACE, "in struct declaration")
+
+	for {
 		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
 			break
 		}
 
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
+		if p.t.Get().Kind == lexer.TOKEN_EOL {
+			p.t.Eat()
+			continue
+		}
+
+		// static NAME = value
+		if p.t.Is("static") && p.t.GetNext().Kind == lexer.TOKEN_IDENTIFIER {
+			p.t.Eat()
+			name := p.t.Eat()
+			p.Expect(lexer.TOKEN_ASSIGN, "after the name of a static member")
+			node.Statics = append(node.Statics, StaticMember{Name: name.Lexeme, Value: p.ParseExp()})
+		} else {
+			property := p.Expect(lexer.TOKEN_IDENTIFIER, "in struct declaration")
+			node.Properties = append(node.Properties, property.Lexeme)
+
+			// The default value is evaluated again for each object, so they do not share arrays or dictionaries
+			var value Exp
+			if p.t.Get().Kind == lexer.TOKEN_ASSIGN {
+				p.t.Eat()
+				value = p.ParseExp()
+				value = AnonFunctionDeclarationNode{Body: []Stmt{ReturnNode{Right: value, Line: property.Line, Column: property.Column}}, Line: property.Line, Column: property.Column}
+			}
+			node.Defaults = append(node.Defaults, value)
+		}
+
+		if p.t.Get().Kind == lexer.TOKEN_COMMA {
 			p.t.Eat()
-			continue
 		}
-
-		node.Body = append(node.Body, p.ParseStmt())
 	}
 
-	if p.t.Get().Kind != lexer.TOKEN_RBRACE {
-		Stop("Expected '}' in for in statement in line " + fmt.Sprint(p.t.Get().Line))
-	} else {
-		p.t.Eat()
-	}
+	p.Expect(lexer.TOKEN_RBRACE, "in struct declaration")
 
-	if len(node.Body) == 0 {
-		Stop("Empty loop statement in line " + fmt.Sprint(p.t.Get().Line))
-	}
 	return node
 }
 
-func (p *Parser) ParseStructMethodDeclaration() StructMethodDeclarationNode {
-	node := StructMethodDeclarationNode{}
+func (p *Parser) ParseInterfaceDeclaration() InterfaceDeclarationNode {
+	node := InterfaceDeclarationNode{Binding: &Binding{}}
+	node.Line, node.Column = position(p.t.Eat()) // interface
 
-	structNameToken := p.t.Eat()
-	node.Line = structNameToken.Line
+	node.Name = p.t.Eat().Lexeme
 
-	node.Struct = structNameToken.Lexeme
+	p.Expect(lexer.TOKEN_LBRACE, "in interface declaration")
 
-	p.t.Eat() // ->
+	for {
+		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
+			break
+		}
 
-	node.Function = p.ParseFunctionDeclaration()
+		if p.t.Get().Kind == lexer.TOKEN_EOL || p.t.Get().Kind == lexer.TOKEN_COMMA {
+			p.t.Eat()
+			continue
+		}
 
-	return node
-}
+		// Only the name and the parameters of each method, the structs give the body
+		method := InterfaceMethod{Name: p.Expect(lexer.TOKEN_IDENTIFIER, "in interface declaration").Lexeme}
+		p.Expect(lexer.TOKEN_LPAR, "after the name of an interface method")
 
-func (p *Parser) ParseStructDeclaration() StructDeclarationNode {
-	line := p.t.Eat().Line // struct keyword
+		for p.t.Get().Kind != lexer.TOKEN_RPAR {
+			method.Parameters = append(method.Parameters, p.Expect(lexer.TOKEN_IDENTIFIER, "as interface method parameter").Lexeme)
 
-	var node StructDeclarationNode = StructDeclarationNode{}
+			if p.t.Get().Kind != lexer.TOKEN_COMMA {
+				break
+			}
+			p.t.Eat()
+		}
 
-	node.Line = line
+		p.Expect(lexer.TOKEN_RPAR, "after interface method parameters")
+		node.Methods = append(node.Methods, method)
+	}
 
-	// struct name
+	p.Expect(lexer.TOKEN_RBRACE, "in interface declaration")
 
-	if p.t.Get().Kind != lexer.TOKEN_IDENTIFIER {
-		Stop("Expected identifier after 'struct' keyword in struct declaration in line " + fmt.Sprint(p.t.Get().Line))
-	}
+	return node
+}
 
-	node.Name = p.t.Eat().Lexeme
-	node.Properties = make([]string, 0)
+func (p *Parser) ParseEnumDeclaration() EnumDeclarationNode {
+	node := EnumDeclarationNode{Binding: &Binding{}}
+	node.Line, node.Column = position(p.t.Eat()) // enum
 
-	if p.t.Get().Kind != lexer.TOKEN_LBRACE {
-		Stop("Expected '{' in struct declaration in line " + fmt.Sprint(node.Line))
-	}
+	node.Name = p.t.Eat().Lexeme
 
-	p.t.Eat()
+	p.Expect(lexer.TOKEN_LBRACE, "in enum declaration")
 
 	for {
 		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
 			break
 		}
 
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
+		if p.t.Get().Kind == lexer.TOKEN_EOL || p.t.Get().Kind == lexer.TOKEN_COMMA {
 			p.t.Eat()
 			continue
 		}
 
-		if p.t.Get().Kind != lexer.TOKEN_IDENTIFIER {
-			Stop("Expected identifier in struct declaration but found: " + lexer.GetTokenName(p.t.Get().Kind) + " in line " + fmt.Sprint(node.Line))
-		}
+		name := p.Expect(lexer.TOKEN_IDENTIFIER, "in enum declaration")
+		member := EnumMember{Name: name.Lexeme}
 
-		node.Properties = append(node.Properties, p.t.Eat().Lexeme)
+		for _, declared := range node.Members {
+			if declared.Name == member.Name {
+				p.Report(name, "The enum "+node.Name+" already has a member called "+member.Name)
+			}
+		}
 
-		if p.t.Get().Kind == lexer.TOKEN_COMMA {
+		// Ok(value) takes a payload when it is called
+		if p.t.Get().Kind == lexer.TOKEN_LPAR {
 			p.t.Eat()
+			member.Fields = []string{}
+
+			for p.t.Get().Kind != lexer.TOKEN_RPAR {
+				member.Fields = append(member.Fields, p.Expect(lexer.TOKEN_IDENTIFIER, "as enum member field").Lexeme)
+
+				if p.t.Get().Kind != lexer.TOKEN_COMMA {
+					break
+				}
+				p.t.Eat()
+			}
+
+			p.Expect(lexer.TOKEN_RPAR, "after enum member fields")
 		}
-	}
 
-	if p.t.Get().Kind != lexer.TOKEN_RBRACE {
-		Stop("Expected '}' in struct declaration in line " + fmt.Sprint(node.Line))
+		node.Members = append(node.Members, member)
 	}
-	p.t.Eat()
+
+	p.Expect(lexer.TOKEN_RBRACE, "in enum declaration")
 
 	return node
 }
 
 func (p *Parser) ParseFunctionDeclaration() FunctionDeclarationNode {
 
-	var node FunctionDeclarationNode = FunctionDeclarationNode{}
-	node.Body = make([]Stmt, 0)
+	var node FunctionDeclarationNode = FunctionDeclarationNode{Binding: &Binding{}}
 
 	if p.t.Get().Kind == lexer.TOKEN_IDENTIFIER {
 		node.Name = p.t.Get().Lexeme
-		node.Line = p.t.Eat().Line
+		node.Line, node.Column = position(p.t.Eat())
 	} else if p.t.Get().Kind == lexer.TOKEN_LPAR {
 		node.Name = ""
-		node.Line = p.t.Get().Line
+		node.Line, node.Column = position(p.t.Get())
 	} else {
-		Stop("Expected identifier or '(' in function declaration in line " + fmt.Sprint(p.t.Get().Line))
+		p.Fail("Expected identifier or '(' in function declaration but found " + describeToken(p.t.Get()))
 	}
 
-	args := p.ParseArgs()
+	node.Parameters = p.ParseParameters()
 
-	for _, arg := range args {
-		node.Parameters = append(node.Parameters, arg.(IdentifierNode).Value)
-	}
+	node.Body, node.Generator = p.ParseFunctionBody("function declaration")
+
+	return node
+}
+
+// Parses the body of a function, it is a generator if it has a yield statement
+func (p *Parser) ParseFunctionBody(context string) ([]Stmt, bool) {
+	inFunction, yields := p.context.InFunction, p.context.Yields
+	p.context.InFunction, p.context.Yields = true, false
+
+	body := p.ParseBlock(context)
+	generator := p.context.Yields
+
+	p.context.InFunction, p.context.Yields = inFunction, yields
+
+	return body, generator
+}
+
+// Parses the list of parameters of a function declaration
+// Parameters with default values go after the required ones and the rest parameter goes last
+func (p *Parser) ParseParameters() []Parameter {
+
+	params := make([]Parameter, 0)
 
-	if p.t.Get().Kind != lexer.TOKEN_LBRACE {
-		Stop("Expected '{' in function declaration in line " + fmt.Sprint(p.t.Get().Line))
+	p.Expect(lexer.TOKEN_LPAR, "before function parameters")
+
+	if p.t.Get().Kind == lexer.TOKEN_RPAR {
+		p.t.Eat()
+		return params
 	}
 
-	p.t.Eat() // open brace
+	hasDefaults := false
 
 	for {
+		param := Parameter{}
 
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-			break
+		if p.t.Get().Kind == lexer.TOKEN_ELLIPSIS {
+			p.t.Eat()
+			param.Rest = true
 		}
 
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
+		name := p.Expect(lexer.TOKEN_IDENTIFIER, "as function parameter")
+		param.Name = name.Lexeme
+
+		if p.t.Get().Kind == lexer.TOKEN_ASSIGN {
+			if param.Rest {
+				p.Fail("Rest parameter '" + param.Name + "' can not have a default value")
+			}
 			p.t.Eat()
-			continue
+			param.Default = p.ParseTernaryExp()
+			hasDefaults = true
+		} else if hasDefaults && !param.Rest {
+			p.FailAt(name, "Parameter '"+param.Name+"' needs a default value because it goes after parameters with default values")
 		}
-		node.Body = append(node.Body, p.ParseStmt())
 
-	}
+		params = append(params, param)
 
-	if p.t.Get().Kind == lexer.TOKEN_EOL {
-		p.t.Eat()
-	}
+		if p.t.Get().Kind != lexer.TOKEN_COMMA {
+			break
+		}
 
-	if p.t.Get().Kind != lexer.TOKEN_RBRACE {
-		Stop("Expected '}' in function declaration in line " + fmt.Sprint(p.t.Get().Line))
-	} else {
+		if param.Rest {
+			p.Fail("Rest parameter '" + param.Name + "' must be the last parameter")
+		}
 		p.t.Eat()
 	}
 
-	return node
+	p.Expect(lexer.TOKEN_RPAR, "after function parameters")
+
+	return params
 }
 
 func (p *Parser) ParseIfStmt() IfStatementNode {
 	// If token
-	line := p.t.Eat().Line
+	line, column := position(p.t.Eat())
 
 	// Node
 	var node IfStatementNode = IfStatementNode{}
 
-	node.Line = line
+	node.Line, node.Column = line, column
 
 	// Parse condition
 	p.context.AvoidStructInit = true
 	node.Condition = p.ParseExp()
 	p.context.AvoidStructInit = false
 
-	p.t.Eat() // open brace
-
-	for {
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-			break
-		}
-
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
-			p.t.Eat()
-			continue
-		}
-
-		node.Body = append(node.Body, p.ParseStmt())
-	}
-
-	if p.t.Get().Kind == lexer.TOKEN_EOL {
-		p.t.Eat()
-	}
-	p.t.Eat() // close brace
+	node.Body = p.ParseBlock("if statement")
 
 	// ELSE IF
 
@@ -496,56 +1014,23 @@ func (p *Parser) ParseIfStmt() IfStatementNode {
 		p.t.Eat() // if
 
 		var elseifnode IfStatementNode = IfStatementNode{}
-		elseifnode.Body = make([]Stmt, 0)
 
-		elseifnode.Line = line
+		elseifnode.Line, elseifnode.Column = line, column
 
 		p.context.AvoidStructInit = true
 		elseifnode.Condition = p.ParseExp()
 		p.context.AvoidStructInit = false
 
-		p.t.Eat() // open brace
-		for {
-			if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-				break
-			}
-
-			if p.t.Get().Kind == lexer.TOKEN_EOL {
-				p.t.Eat()
-				continue
-			}
-
-			elseifnode.Body = append(elseifnode.Body, p.ParseStmt())
-		}
-
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
-			p.t.Eat()
-		}
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE {
-			p.t.Eat()
-		}
+		elseifnode.Body = p.ParseBlock("else if statement")
 
 		node.ElseIf = append(node.ElseIf, elseifnode)
 	}
 
 	if p.t.Get().Kind == lexer.TOKEN_ELSE {
-		node.ElseBody = make([]Stmt, 0)
-		p.t.Eat()
 		p.t.Eat()
-		for {
-			if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-				break
-			}
-
-			if p.t.Get().Kind == lexer.TOKEN_EOL {
-				p.t.Eat()
-				continue
-			}
 
-			node.ElseBody = append(node.ElseBody, p.ParseStmt())
-		}
+		node.ElseBody = p.ParseBlock("else statement")
 
-		p.t.Eat()
 		if p.t.Get().Kind == lexer.TOKEN_EOL {
 			p.t.Eat()
 		}
@@ -555,30 +1040,41 @@ func (p *Parser) ParseIfStmt() IfStatementNode {
 }
 
 func (p *Parser) ParseExpressionStmt() ExpressionStmtNode {
-	token := p.t.Get()
 	val := p.ParseExp()
-	if val == nil {
-		fmt.Println("Bad expression after token " + token.Lexeme + " line " + strconv.Itoa(token.Line))
-		os.Exit(1)
-	}
 	return ExpressionStmtNode{Expression: val}
 }
 
 func (p *Parser) ParseVarDeclaration() Stmt {
-	line := p.t.Eat().Line
+	keyword := p.t.Eat()
+	line, column := position(keyword)
 
-	node := VarDeclarationNode{}
+	node := VarDeclarationNode{Constant: keyword.Kind == lexer.TOKEN_CONST}
 
-	node.Line = line
+	node.Line, node.Column = line, column
 
-	identifier := p.t.Eat()
+	// var [a, b] = array and var {a, b} = dict take the values out of the right side
+	if p.startsDestructuring() {
+		pattern := p.parsePrimaryPattern()
+		node.Pattern = &pattern
+		node.Operator = p.Expect(lexer.TOKEN_ASSIGN, "after the pattern of a destructuring declaration").Lexeme
+		node.Right = p.ParseExp()
+		return node
+	}
 
-	node.Left = IdentifierNode{Value: identifier.Lexeme}
+	identifier := p.Expect(lexer.TOKEN_IDENTIFIER, "after '"+keyword.Lexeme+"' keyword")
 
-	if p.t.Get().Kind == lexer.TOKEN_EOL {
-		node.Right = NothingNode{Line: line}
+	node.Left = IdentifierNode{Value: identifier.Lexeme, Binding: &Binding{}, Line: identifier.Line, Column: identifier.Column}
+
+	next := p.t.Get().Kind
+
+	if next == lexer.TOKEN_EOL || next == lexer.TOKEN_EOF || next == lexer.TOKEN_RBRACE {
+		node.Right = NothingNode{Line: line, Column: column}
+
+		if node.Constant {
+			p.Report(identifier, "The constant '"+identifier.Lexeme+"' needs a value")
+		}
 	} else {
-		operator := p.t.Eat()
+		operator := p.Expect(lexer.TOKEN_ASSIGN, "after variable name")
 		node.Operator = operator.Lexeme
 
 		node.Right = p.ParseExp()
@@ -595,50 +1091,16 @@ func (p *Parser) ParseExp() Exp {
 
 func (p *Parser) ParseAnonFnExp() Exp {
 
-	if p.t.Get().Lexeme != "fn" {
+	if !p.t.Is("fn") {
 		return p.ParseAssignmentExp()
 	}
 
-	p.t.Eat()
-
 	var node AnonFunctionDeclarationNode = AnonFunctionDeclarationNode{}
-	node.Body = make([]Stmt, 0)
-
-	args := p.ParseArgs()
-
-	for _, arg := range args {
-		node.Parameters = append(node.Parameters, arg.(IdentifierNode).Value)
-	}
-
-	if p.t.Get().Kind != lexer.TOKEN_LBRACE {
-		Stop("Expected '{' in anon function declaration in line " + fmt.Sprint(p.t.Get().Line))
-	}
-
-	p.t.Eat() // open brace
-
-	for {
-
-		if p.t.Get().Kind == lexer.TOKEN_RBRACE || p.t.Get().Kind == lexer.TOKEN_EOF {
-			break
-		}
-
-		if p.t.Get().Kind == lexer.TOKEN_EOL {
-			p.t.Eat()
-			continue
-		}
-		node.Body = append(node.Body, p.ParseStmt())
-
-	}
+	node.Line, node.Column = position(p.t.Eat())
 
-	if p.t.Get().Kind == lexer.TOKEN_EOL {
-		p.t.Eat()
-	}
+	node.Parameters = p.ParseParameters()
 
-	if p.t.Get().Kind != lexer.TOKEN_RBRACE {
-		Stop("Expected '}' in function declaration in line " + fmt.Sprint(p.t.Get().Line))
-	} else {
-		p.t.Eat()
-	}
+	node.Body, node.Generator = p.ParseFunctionBody("anon function declaration")
 
 	return node
 
@@ -647,12 +1109,12 @@ func (p *Parser) ParseAnonFnExp() Exp {
 func (p *Parser) ParseAssignmentExp() Exp {
 	left := p.ParseTernaryExp()
 
-	if p.t.Get().Lexeme == "=" {
+	if p.t.Get().Kind == lexer.TOKEN_ASSIGN || p.t.Get().Kind == lexer.TOKEN_OP_ASSIGN {
 
 		operator := p.t.Eat()
 		right := p.ParseTernaryExp()
 		n := AssignmentNode{}
-		n.Line = operator.Line
+		n.Line, n.Column = operator.Line, operator.Column
 		n.Left = left
 		n.Operator = operator.Lexeme
 		n.Right = right
@@ -665,19 +1127,16 @@ func (p *Parser) ParseTernaryExp() Exp {
 
 	left := p.ParseDictionaryInitialization()
 
-	if p.t.Get().Lexeme == "?" {
+	if p.t.Is("?") {
 
 		n := TernaryExpNode{}
-		n.Line = p.t.Eat().Line
+		n.Line, n.Column = position(p.t.Eat())
 
 		n.Condition = left
 
 		n.Left = p.ParseDictionaryInitialization()
 
-		if p.t.Get().Lexeme != ":" {
-			Stop("Missing ':' inside ternary expression")
-		}
-		p.t.Eat()
+		p.Expect(lexer.TOKEN_COLON, "inside ternary expression")
 
 		n.Right = p.ParseDictionaryInitialization()
 
@@ -689,15 +1148,15 @@ func (p *Parser) ParseTernaryExp() Exp {
 
 func (p *Parser) ParseDictionaryInitialization() Exp {
 
-	if p.t.Get().Lexeme != "{" || p.context.AvoidStructInit == true {
+	if !p.t.Is("{") || p.context.AvoidStructInit == true {
 		return p.ParseBinaryExp()
 	}
 
-	line := p.t.Eat().Line
+	line, column := position(p.t.Eat())
 
 	node := DictionaryExpNode{}
 
-	node.Line = line
+	node.Line, node.Column = line, column
 
 	node.Value = make(map[string]Exp, 0)
 
@@ -707,7 +1166,7 @@ func (p *Parser) ParseDictionaryInitialization() Exp {
 			break
 		}
 
-		if p.t.Get().Lexeme == "," {
+		if p.t.Is(",") {
 			p.t.Eat()
 			continue
 		}
@@ -724,9 +1183,7 @@ func (p *Parser) ParseDictionaryInitialization() Exp {
 		// Luego deberia haber un :
 		// ex -> {a: 2, b: 3}
 
-		if p.t.Eat().Lexeme != ":" {
-			Stop("Expected ':' in line " + fmt.Sprint(p.t.Get().Line) + " after key " + key.Lexeme)
-		}
+		p.Expect(lexer.TOKEN_COLON, "after key "+key.Lexeme)
 
 		value := p.ParseExp()
 
@@ -737,7 +1194,7 @@ func (p *Parser) ParseDictionaryInitialization() Exp {
 	if p.t.Get().Kind == lexer.TOKEN_EOL {
 		p.t.Eat()
 	}
-	p.t.Eat()
+	p.Expect(lexer.TOKEN_RBRACE, "at the end of the dictionary")
 
 	return node
 }
@@ -749,10 +1206,10 @@ func (p *Parser) ParseBinaryExp() Exp {
 func (p *Parser) parseLogicOrExpression() Exp {
 	left := p.parseLogicAndExpression()
 
-	for p.t.Get().Lexeme == "or" {
+	for p.t.Is("or") {
 		op := p.t.Eat()
 		n := BinaryLogicExpNode{}
-		n.Line = op.Line
+		n.Line, n.Column = op.Line, op.Column
 		n.Left = left
 		n.Operator = OperatorOr
 		n.Right = p.parseLogicAndExpression()
@@ -765,10 +1222,10 @@ func (p *Parser) parseLogicOrExpression() Exp {
 func (p *Parser) parseLogicAndExpression() Exp {
 	left := p.parseComparisonExp()
 
-	for p.t.Get().Lexeme == "and" {
+	for p.t.Is("and") {
 		op := p.t.Eat()
 		n := BinaryLogicExpNode{}
-		n.Line = op.Line
+		n.Line, n.Column = op.Line, op.Column
 		n.Left = left
 		n.Operator = OperatorAnd
 		n.Right = p.parseComparisonExp()
@@ -780,15 +1237,18 @@ func (p *Parser) parseLogicAndExpression() Exp {
 }
 
 func (p *Parser) parseComparisonExp() Exp {
-	left := p.parseAdditiveExp()
+	left := p.parseRangeExp()
 
-	for p.t.Get().Lexeme == "==" || p.t.Get().Lexeme == "!=" || p.t.Get().Lexeme == ">" || p.t.Get().Lexeme == "<" || p.t.Get().Lexeme == ">=" || p.t.Get().Lexeme == "<=" {
+	// is is only a keyword after a value, like step in ranges
+	for p.t.Is("==") || p.t.Is("!=") || p.t.Is(">") || p.t.Is("<") || p.t.Is(">=") || p.t.Is("<=") || p.t.Get().Kind == lexer.TOKEN_IDENTIFIER && p.t.Is("is") {
 		op := p.t.Eat()
 		n := BinaryComparisonExpNode{}
-		n.Line = op.Line
+		n.Line, n.Column = op.Line, op.Column
 		n.Left = left
 		if op.Lexeme == "==" {
 			n.Operator = OperatorEquals
+		} else if op.Lexeme == "!=" {
+			n.Operator = OperatorNotEquals
 		} else if op.Lexeme == ">" {
 			n.Operator = OperatorGreaterThan
 		} else if op.Lexeme == "<" {
@@ -797,22 +1257,92 @@ func (p *Parser) parseComparisonExp() Exp {
 			n.Operator = OperatorGreaterOrEqThan
 		} else if op.Lexeme == "<=" {
 			n.Operator = OperatorLessOrEqThan
+		} else if op.Lexeme == "is" {
+			n.Operator = OperatorIs
+		}
+		n.Right = p.parseRangeExp()
+		left = n
+	}
+
+	return left
+}
+
+// 0..10, 0..=10 and 10..0 step -2
+func (p *Parser) parseRangeExp() Exp {
+	left := p.parseBitOrExp()
+
+	if p.t.Get().Kind != lexer.TOKEN_RANGE {
+		return left
+	}
+
+	op := p.t.Eat()
+	n := RangeExpNode{From: left, Inclusive: op.Lexeme == "..="}
+	n.Line, n.Column = op.Line, op.Column
+	n.To = p.parseBitOrExp()
+
+	// step is only a keyword after a range
+	if p.t.Get().Kind == lexer.TOKEN_IDENTIFIER && p.t.Is("step") {
+		p.t.Eat()
+		n.Step = p.parseBitOrExp()
+	}
+
+	return n
+}
+
+// Binary operators of each precedence level, from the loosest to the tightest
+var (
+	bitOrOperators          = map[string]OperatorType{"|": OperatorBitOr}
+	bitXorOperators         = map[string]OperatorType{"^": OperatorBitXor}
+	bitAndOperators         = map[string]OperatorType{"&": OperatorBitAnd}
+	shiftOperators          = map[string]OperatorType{"<<": OperatorShiftLeft, ">>": OperatorShiftRight}
+	multiplicativeOperators = map[string]OperatorType{"*": OperatorMultiply, "/": OperatorDivide, "%": OperatorModulo, "~/": OperatorIntDivide}
+)
+
+// Parses a left associative chain of the given operators
+func (p *Parser) parseBinaryLevel(operators map[string]OperatorType, next func() Exp) Exp {
+	left := next()
+
+	for p.t.Get().Kind == lexer.TOKEN_OPERATOR {
+		operator, ok := operators[p.t.Get().Lexeme]
+		if !ok {
+			break
 		}
-		n.Right = p.parseAdditiveExp()
+		op := p.t.Eat()
+		n := BinaryExpNode{}
+		n.Left = left
+		n.Line, n.Column = op.Line, op.Column
+		n.Operator = operator
+		n.Right = next()
 		left = n
 	}
 
 	return left
 }
 
+func (p *Parser) parseBitOrExp() Exp {
+	return p.parseBinaryLevel(bitOrOperators, p.parseBitXorExp)
+}
+
+func (p *Parser) parseBitXorExp() Exp {
+	return p.parseBinaryLevel(bitXorOperators, p.parseBitAndExp)
+}
+
+func (p *Parser) parseBitAndExp() Exp {
+	return p.parseBinaryLevel(bitAndOperators, p.parseShiftExp)
+}
+
+func (p *Parser) parseShiftExp() Exp {
+	return p.parseBinaryLevel(shiftOperators, p.parseAdditiveExp)
+}
+
 func (p *Parser) parseAdditiveExp() Exp {
 	left := p.parseObjectInitExp()
 
-	for p.t.Get().Lexeme == "+" || p.t.Get().Lexeme == "-" {
+	for p.t.Is("+") || p.t.Is("-") {
 		op := p.t.Eat()
 		n := BinaryExpNode{}
 		n.Left = left
-		n.Line = op.Line
+		n.Line, n.Column = op.Line, op.Column
 		if op.Lexeme == "+" {
 			n.Operator = OperatorAdd
 		} else {
@@ -829,10 +1359,10 @@ func (p *Parser) parseAdditiveExp() Exp {
 func (p *Parser) parseObjectInitExp() Exp {
 	left := p.parseMultiplicativeExp()
 
-	for p.t.Get().Lexeme == "{" && p.context.AvoidStructInit == false {
+	for p.t.Is("{") && p.context.AvoidStructInit == false {
 
 		node := ObjectInitExpNode{}
-		node.Line = p.t.Get().Line
+		node.Line, node.Column = position(p.t.Get())
 
 		p.context.AvoidStructInit = true
 		p.context.AvoidStructInit = false
@@ -848,21 +1378,21 @@ func (p *Parser) parseObjectInitExp() Exp {
 }
 
 func (p *Parser) parseMultiplicativeExp() Exp {
+	return p.parseBinaryLevel(multiplicativeOperators, p.parsePowerExp)
+}
+
+// ** is right associative, 2 ** 3 ** 2 is 2 ** 9
+func (p *Parser) parsePowerExp() Exp {
 	left := p.ParseMemberExp()
 
-	for p.t.Get().Lexeme == "*" || p.t.Get().Lexeme == "/" {
+	if p.t.Is("**") && p.t.Get().Kind == lexer.TOKEN_OPERATOR {
 		op := p.t.Eat()
 		n := BinaryExpNode{}
 		n.Left = left
-		n.Line = op.Line
-		if op.Lexeme == "*" {
-			n.Operator = OperatorMultiply
-		} else {
-			n.Operator = OperatorDivide
-		}
-
-		n.Right = p.ParseMemberExp()
-		left = n
+		n.Line, n.Column = op.Line, op.Column
+		n.Operator = OperatorPower
+		n.Right = p.parsePowerExp()
+		return n
 	}
 
 	return left
@@ -872,44 +1402,40 @@ func (p *Parser) ParseMemberExp() Exp {
 
 	left := p.ParseCallMemberExp()
 
-	for p.t.Get().Lexeme == "." || p.t.Get().Lexeme == "[" {
+	for p.t.Is(".") || p.t.Is("[") {
 
-		if p.t.Get().Lexeme == "." {
-			line := p.t.Eat().Line
+		if p.t.Is(".") {
+			line, column := position(p.t.Eat())
 
 			n := MemberExpNode{}
-			n.Line = line
+			n.Line, n.Column = line, column
 			n.Left = left
-			n.Member = p.t.Eat().Lexeme
+			n.Member = p.Expect(lexer.TOKEN_IDENTIFIER, "after '.'").Lexeme
 			left = n
 
-			if p.t.Get().Lexeme == "(" {
+			if p.t.Is("(") {
 				left = p.ParseCallExpr(left)
 			}
 		} else {
-			for p.t.Get().Lexeme == "[" {
+			for p.t.Is("[") {
 
-				line := p.t.Eat().Line
+				line, column := position(p.t.Eat())
 
-				if p.t.Get().Lexeme == ":" {
+				if p.t.Is(":") {
 					p.t.Eat()
 
-					if p.t.Get().Lexeme == "]" {
-						Stop("Empty slice expression at line " + fmt.Sprint(line))
+					if p.t.Is("]") {
+						p.Fail("Empty slice expression")
 					}
 
 					sliceNode := SliceExpNode{}
-					sliceNode.Line = line
+					sliceNode.Line, sliceNode.Column = line, column
 					sliceNode.Left = left
 					sliceNode.From = NumberNode{Value: 0}
 					sliceNode.To = p.ParseExp()
 					left = sliceNode
 
-					if p.t.Get().Lexeme != "]" {
-						Stop("Expected ']' at the end of a slice expression at line " + fmt.Sprint(line))
-					}
-
-					p.t.Eat()
+					p.Expect(lexer.TOKEN_RBRACKET, "at the end of a slice expression")
 
 					return left
 				}
@@ -917,17 +1443,17 @@ func (p *Parser) ParseMemberExp() Exp {
 				index := p.ParseExp()
 
 				n := IndexAccessExpNode{}
-				n.Line = line
+				n.Line, n.Column = line, column
 				n.Left = left
 				n.Index = index
 
-				if p.t.Get().Lexeme == ":" {
+				if p.t.Is(":") {
 					p.t.Eat()
 					sliceNode := SliceExpNode{}
-					sliceNode.Line = line
+					sliceNode.Line, sliceNode.Column = line, column
 					sliceNode.Left = left
 					sliceNode.From = index
-					if p.t.Get().Lexeme == "]" {
+					if p.t.Is("]") {
 						sliceNode.To = nil
 					} else {
 						sliceNode.To = p.ParseExp()
@@ -937,16 +1463,12 @@ func (p *Parser) ParseMemberExp() Exp {
 					left = n
 				}
 
-				if p.t.Get().Lexeme == "]" {
-					p.t.Eat()
-				} else {
-					Stop("Expected ']' at line " + fmt.Sprint(line))
-				}
+				p.Expect(lexer.TOKEN_RBRACKET, "after index")
 			}
 		}
 	}
 
-	if p.t.Get().Lexeme == "(" {
+	if p.t.Is("(") {
 		return p.ParseCallExpr(left)
 	}
 	// litter.Dump(p.t.Get())
@@ -958,7 +1480,7 @@ func (p *Parser) ParseMemberExp() Exp {
 func (p *Parser) ParseCallMemberExp() Exp {
 	member := p.parseUnaryExp()
 
-	if p.t.Get().Lexeme == "(" {
+	if p.t.Is("(") {
 		return p.ParseCallExpr(member)
 	}
 
@@ -967,17 +1489,17 @@ func (p *Parser) ParseCallMemberExp() Exp {
 
 func (p *Parser) parseUnaryExp() Exp {
 
-	if p.t.Get().Lexeme == "-" && p.t.Get().Kind == lexer.TOKEN_OPERATOR {
+	if (p.t.Is("-") || p.t.Is("~")) && p.t.Get().Kind == lexer.TOKEN_OPERATOR {
 		op := p.t.Eat()
 		n := UnaryExpNode{}
-		n.Line = op.Line
+		n.Line, n.Column = op.Line, op.Column
 		n.Operator = op.Lexeme
-		n.Right = p.ParseExp()
+		n.Right = p.parsePowerExp()
 		return n
-	} else if p.t.Get().Lexeme == "not" {
+	} else if p.t.Is("not") {
 		op := p.t.Eat()
 		n := UnaryExpNode{}
-		n.Line = op.Line
+		n.Line, n.Column = op.Line, op.Column
 		n.Operator = op.Lexeme
 		n.Right = p.ParseExp()
 		return n
@@ -990,14 +1512,19 @@ func (p *Parser) parsePrimaryExp() Exp {
 	token := p.t.Eat()
 
 	if token.Kind == lexer.TOKEN_IDENTIFIER {
-		return IdentifierNode{Value: token.Lexeme, Line: token.Line}
+		return IdentifierNode{Value: token.Lexeme, Binding: &Binding{}, Line: token.Line, Column: token.Column}
 	} else if token.Kind == lexer.TOKEN_NUMBER {
-		f64, _ := strconv.ParseFloat(token.Lexeme, 64)
-		return NumberNode{Value: f64, Line: token.Line}
+		f64, err := strconv.ParseFloat(token.Lexeme, 64)
+		if err != nil {
+			p.FailAt(token, "Invalid number "+token.Lexeme)
+		}
+		return NumberNode{Value: f64, Line: token.Line, Column: token.Column}
 	} else if token.Kind == lexer.TOKEN_NOTHING {
-		return NothingNode{Line: token.Line}
+		return NothingNode{Line: token.Line, Column: token.Column}
 	} else if token.Kind == lexer.TOKEN_STRING {
-		return StringNode{Value: token.Lexeme, Line: token.Line}
+		return StringNode{Value: token.Lexeme, Line: token.Line, Column: token.Column}
+	} else if token.Kind == lexer.TOKEN_TMPL_HEAD {
+		return p.ParseInterpolation(token)
 	} else if token.Kind == lexer.TOKEN_LBRACKET {
 		return p.ParseArrayInitializationExp()
 	} else if token.Kind == lexer.TOKEN_BOOLEAN {
@@ -1007,88 +1534,124 @@ func (p *Parser) parsePrimaryExp() Exp {
 		} else if token.Lexeme == "false" {
 			value = false
 		}
-		return BooleanNode{Value: value, Line: token.Line}
+		return BooleanNode{Value: value, Line: token.Line, Column: token.Column}
 	} else if token.Kind == lexer.TOKEN_LPAR {
 		v := p.ParseExp()
-		p.t.Eat()
+		p.Expect(lexer.TOKEN_RPAR, "to close the parenthesis")
 		return v
 	} else if token.Kind == lexer.TOKEN_EOF {
-		Stop("Unexpected end of file in line " + fmt.Sprint(token.Line))
-		return nil
+		p.FailAt(token, "Unexpected end of file")
 	} else if token.Kind == lexer.TOKEN_EOL {
-		Stop("Unexpected line break in line " + fmt.Sprint(token.Line))
-		return nil
-	} else {
-		Stop("Unexpected token: " + token.Lexeme + " in line " + fmt.Sprint(token.Line))
-		return nil
+		p.FailAt(token, "Unexpected line break")
 	}
+
+	p.FailAt(token, "Unexpected token "+describeToken(token))
+	return nil
 }
 
 func (p *Parser) ParseCallExpr(member Exp) Exp {
 
 	node := CallExpNode{}
-	node.Line = p.t.Get().Line
+	node.Line, node.Column = position(p.t.Get())
 
 	if member.ExpType() != NodeIdentifier && member.ExpType() != NodeMemberExp && member.ExpType() != NodeIndexAccessExp {
-		Stop(member.ExpType().String() + " is not callable at line: " + fmt.Sprint(p.t.Get().Line))
+		p.Fail(member.ExpType().String() + " is not callable")
 	}
 
 	node.Name = member
 
-	args := p.ParseArgs()
-
-	node.Args = args
+	node.Args, node.NamedArgs = p.ParseArgs()
 
 	return node
 }
 
-func (p *Parser) ParseArgs() []Exp {
+func (p *Parser) ParseArgs() ([]Exp, []NamedArg) {
 
-	if p.t.Get().Kind != lexer.TOKEN_LPAR {
-		Stop("Expected '(' after function arguments but found " + p.t.Get().Lexeme + " in line " + fmt.Sprint(p.t.Get().Line))
+	p.Expect(lexer.TOKEN_LPAR, "before function arguments")
+
+	if p.t.Is(")") {
+		p.t.Eat()
+		return nil, nil
 	}
 
-	p.t.Eat()
+	return p.ParseArgumentsList()
+}
 
+// ParseArgumentsList parses a list of expressions separated by commas.
+// Arguments written as name: value go after the positional ones and are returned apart.
+func (p *Parser) ParseArgumentsList() ([]Exp, []NamedArg) {
 	var args []Exp
+	var named []NamedArg
+
+	for {
+		if p.t.Get().Kind == lexer.TOKEN_IDENTIFIER && p.t.GetNext().Kind == lexer.TOKEN_COLON {
+			name := p.t.Eat()
+			p.t.Eat()
+
+			for _, arg := range named {
+				if arg.Name == name.Lexeme {
+					p.FailAt(name, "Argument '"+name.Lexeme+"' is given more than once")
+				}
+			}
+
+			named = append(named, NamedArg{Name: name.Lexeme, Value: p.ParseExp(), Line: name.Line, Column: name.Column})
+		} else {
+			if len(named) > 0 {
+				p.Fail("Positional arguments must go before the named ones")
+			}
+			args = append(args, p.ParseExp())
+		}
 
-	if p.t.Get().Lexeme == ")" {
+		if !p.t.Is(",") {
+			break
+		}
 		p.t.Eat()
-		return args
 	}
 
-	args = p.ParseArgumentsList()
-
-	return args
+	p.Expect(lexer.TOKEN_RPAR, "after function arguments")
 
+	return args, named
 }
 
-// ParseArgumentsList parses a list of expressions separated by commas.
-// It returns a slice of expressions representing the parsed arguments.
-func (p *Parser) ParseArgumentsList() []Exp {
-	var args []Exp
+// Parses the expressions of a string with interpolations, the first piece of text is already eaten
+func (p *Parser) ParseInterpolation(head lexer.Token) Exp {
 
-	// p.context.AvoidStructInit = true
+	node := InterpolationNode{Line: head.Line, Column: head.Column}
 
-	args = append(args, p.ParseExp())
+	text := head
 
-	for p.t.Get().Lexeme == "," {
-		p.t.Eat()
-		args = append(args, p.ParseExp())
-	}
+	for {
+		// Without the delimiters
+		value := text.Lexeme[1:]
+		if text.Kind == lexer.TOKEN_TMPL_TAIL {
+			value = value[:len(value)-1]
+		} else {
+			value = value[:len(value)-2]
+		}
 
-	if p.t.Get().Lexeme != ")" {
-		Stop("Expected ')' after function arguments but found " + p.t.Get().Lexeme + " in line " + fmt.Sprint(p.t.Get().Line))
-	}
-	// p.context.AvoidStructInit = false
-	p.t.Eat()
+		if value != "" {
+			node.Parts = append(node.Parts, StringNode{Value: value, Line: text.Line, Column: text.Column})
+		}
+
+		if text.Kind == lexer.TOKEN_TMPL_TAIL {
+			return node
+		}
+
+		node.Parts = append(node.Parts, p.ParseExp())
 
-	return args
+		text = p.t.Get()
+
+		if text.Kind != lexer.TOKEN_TMPL_MID && text.Kind != lexer.TOKEN_TMPL_TAIL {
+			p.Fail("Expected '}' to close the interpolation but found " + describeToken(text))
+		}
+
+		p.t.Eat()
+	}
 }
 
 func (p *Parser) ParseArrayInitializationExp() Exp {
 	node := ArrayExpNode{}
-	node.Line = p.t.Get().Line
+	node.Line, node.Column = position(p.t.Get())
 	node.Value = make([]Exp, 0)
 
 	for {
@@ -1111,18 +1674,14 @@ func (p *Parser) ParseArrayInitializationExp() Exp {
 
 	}
 
-	if p.t.Get().Kind != lexer.TOKEN_RBRACKET {
-		Stop("Expected ']' in line " + fmt.Sprint(p.t.Get().Line))
-	} else {
-		p.t.Eat()
-	}
+	p.Expect(lexer.TOKEN_RBRACKET, "at the end of the array")
 
 	return node
 }
 
-func Stop(msg string) {
-	fmt.Println(msg)
-	os.Exit(1)
+// Returns the line and column where a token starts
+func position(token lexer.Token) (int, int) {
+	return token.Line, token.Column
 }
 
 func (p *Parser) Debug(text string) {
@@ -11,12 +11,15 @@ type TokenIterator struct {
 
 func (t TokenIterator) Get() lexer.Token {
 	if t.IsOutOfBounds() {
-		return lexer.Token{Kind: lexer.TOKEN_EOF}
+		return t.eofToken()
 	}
 	char := t.Items[t.Index]
 	return char
 }
 func (t *TokenIterator) Eat() lexer.Token {
+	if t.IsOutOfBounds() {
+		return t.eofToken()
+	}
 	char := t.Items[t.Index]
 	t.Index++
 	return char
@@ -31,5 +34,23 @@ func (t TokenIterator) IsOutOfBounds() bool {
 }
 
 func (t TokenIterator) GetNext() lexer.Token {
+	if t.Index+1 >= len(t.Items) {
+		return t.eofToken()
+	}
 	return t.Items[t.Index+1]
 }
+
+// Checks if the actual token is the given symbol or keyword, a string with the same text is not
+func (t TokenIterator) Is(lexeme string) bool {
+	token := t.Get()
+	return token.Kind != lexer.TOKEN_STRING && token.Lexeme == lexeme
+}
+
+// Token returned when reading past the end, it keeps the position of the last token
+func (t TokenIterator) eofToken() lexer.Token {
+	if len(t.Items) == 0 {
+		return lexer.Token{Kind: lexer.TOKEN_EOF}
+	}
+	last := t.Items[len(t.Items)-1]
+	return lexer.Token{Kind: lexer.TOKEN_EOF, Line: last.Line, Column: last.Column}
+}
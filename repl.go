@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	environment "evie/env"
+	"evie/evruntime"
+	"evie/native"
+	"evie/parser"
+	"evie/resolver"
+	"evie/values"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	replPrompt      = ">>> "
+	replMorePrompt  = "... "
+	replModuleName  = "repl"
+	replExitCommand = "exit"
+)
+
+// Starts an interactive session, every line is evaluated in the same environment
+// so variables, functions and structs declared before are still available
+func StartRepl() {
+
+	cd, err := os.Getwd()
+
+	if err != nil {
+		fmt.Println("Error trying to get the actual working directory")
+	}
+
+	env := SetupInitialEnv(replModuleName)
+
+	intr := evruntime.Evaluator{RootPath: cd, Sources: map[string]string{}}
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	fmt.Println("Evie interactive mode, type '" + replExitCommand + "' to quit")
+
+	source := ""
+
+	// Inputs evaluated before, each input is parsed after as many empty lines as they have
+	// so the lines of its errors and of the functions it declares are lines of the session
+	history := ""
+
+	for {
+		if source == "" {
+			fmt.Print(replPrompt)
+		} else {
+			fmt.Print(replMorePrompt)
+		}
+
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+
+		line := scanner.Text()
+
+		if source == "" && line == replExitCommand {
+			return
+		}
+
+		source += line + "\n"
+
+		// Wait for more lines while there are blocks, arrays or calls left open
+		if IsIncompleteInput(source) {
+			continue
+		}
+
+		padded := strings.Repeat("\n", strings.Count(history, "\n")) + source
+
+		ast, err := parser.Parse(padded)
+
+		if err == nil {
+			err = resolver.Resolve(ast, env)
+		}
+
+		// Nothing of the input is evaluated if it is not valid
+		if err != nil {
+			PrintParseError(replModuleName, padded, err)
+			source = ""
+			continue
+		}
+
+		// Runtime errors show the lines of the session where they happened
+		history += source
+		intr.Sources[replModuleName] = history
+		source = ""
+
+		EvaluateReplStatements(&intr, ast, env)
+	}
+}
+
+// Evaluates the statements of a single input and prints the value of the expression statements
+func EvaluateReplStatements(intr *evruntime.Evaluator, ast []parser.Stmt, env *environment.Environment) {
+
+	for _, stmt := range ast {
+
+		result := intr.EvaluateStmt(stmt, env)
+
+		if result.GetType() == values.ErrorType {
+			intr.PrintError(result.(values.ErrorValue))
+			return
+		}
+
+		if stmt.StmtType() != parser.NodeExpStmt {
+			continue
+		}
+
+		expression := stmt.(parser.ExpressionStmtNode).Expression
+
+		// Assignments are expressions, but echoing them back is just noise
+		if expression.ExpType() == parser.NodeAssignment {
+			continue
+		}
+
+		// The same for the true returned by print, the value was already shown
+		if IsPrintCall(expression) {
+			continue
+		}
+
+		if result.GetType() == values.NothingType {
+			continue
+		}
+
+		native.PrintValues([]values.RuntimeValue{result}, true)
+		fmt.Println()
+	}
+}
+
+// Checks if the expression is a direct call to the built in print function
+func IsPrintCall(expression parser.Exp) bool {
+
+	call, ok := expression.(parser.CallExpNode)
+
+	if !ok {
+		return false
+	}
+
+	name, ok := call.Name.(parser.IdentifierNode)
+
+	return ok && name.Value == "print"
+}
+
+// Checks if there are braces, brackets or parentheses without close in the given source
+// ignoring the ones inside strings and comments. Strings and block comments left open
+// wait for more lines too
+func IsIncompleteInput(source string) bool {
+
+	depth := 0
+	inComment := false
+	isScaped := false
+
+	// Delimiter of the string being read, empty outside of strings
+	closing := ""
+
+	characters := []rune(source)
+
+	startsWith := func(i int, text string) bool {
+		return strings.HasPrefix(string(characters[i:]), text)
+	}
+
+	for i := 0; i < len(characters); i++ {
+
+		char := characters[i]
+
+		if inComment {
+			if startsWith(i, "*/") {
+				inComment = false
+				i++
+			}
+			continue
+		}
+
+		if closing != "" {
+			// Raw strings have no escapes
+			if isScaped {
+				isScaped = false
+			} else if char == '\\' && closing != "`" {
+				isScaped = true
+			} else if startsWith(i, closing) {
+				i += len(closing) - 1
+				closing = ""
+			}
+			continue
+		}
+
+		if startsWith(i, "//") {
+			for i < len(characters) && characters[i] != '\n' {
+				i++
+			}
+			continue
+		}
+
+		if startsWith(i, "/*") {
+			inComment = true
+			i++
+			continue
+		}
+
+		switch char {
+		case '"':
+			closing = `"`
+			if startsWith(i, `"""`) {
+				closing = `"""`
+				i += 2
+			}
+		case '\'', '`':
+			closing = string(char)
+		case '{', '[', '(':
+			depth++
+		case '}', ']', ')':
+			depth--
+		}
+	}
+
+	return depth > 0 || closing != "" || inComment
+}
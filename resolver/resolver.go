@@ -0,0 +1,565 @@
+package resolver
+
+import (
+	environment "evie/env"
+	"evie/lib"
+	"evie/parser"
+	"sort"
+)
+
+// A scope that exists at run time, the top level of a module is the only one that stores names
+type scope struct {
+	slots     map[string]int
+	constants map[string]bool
+
+	// Function bodies are resolved when the scope where they were created ends,
+	// so they can use anything declared in it
+	pending []func()
+}
+
+// All the problems found with the names of a module, they are not syntax errors
+// but they are found before running it too
+type NameError struct {
+	parser.ParseError
+}
+
+type Resolver struct {
+	scopes []*scope
+
+	// Names declared in the module environment, before and after the resolved code
+	globals  map[string]bool
+	declared map[string]bool
+
+	// Names of the module environment that can not be assigned
+	constants map[string]bool
+
+	// Every name declared anywhere, to tell typos from uses before the declaration
+	names map[string]bool
+
+	functions   int
+	diagnostics []parser.Diagnostic
+}
+
+// Resolves every variable of a module to its slot or to the module environment
+// The names already in the environment, like the built in functions, are known globals
+func Resolve(ast []parser.Stmt, env *environment.Environment) error {
+
+	r := &Resolver{
+		globals:   make(map[string]bool),
+		declared:  make(map[string]bool),
+		constants: make(map[string]bool),
+		names:     make(map[string]bool),
+	}
+
+	for name := range env.Variables {
+		r.globals[name] = true
+		r.declared[name] = true
+		r.constants[name] = env.IsConstant(name)
+	}
+
+	for _, stmt := range ast {
+		for _, name := range r.declaredNames(stmt) {
+			r.globals[name] = true
+		}
+	}
+
+	collectNames(ast, r.names)
+
+	r.beginScope()
+	r.resolveStmts(ast)
+	r.endScope()
+
+	if len(r.diagnostics) > 0 {
+		// Function bodies are resolved later than the code around them
+		sort.SliceStable(r.diagnostics, func(i, j int) bool {
+			a, b := r.diagnostics[i], r.diagnostics[j]
+			return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+		})
+		return NameError{parser.ParseError{Diagnostics: r.diagnostics}}
+	}
+
+	return nil
+}
+
+// Checks if a block declares something, only those blocks get a scope when they run
+// The catch of a try declares the error in the scope where the try is
+func NeedsScope(stmts []parser.Stmt) bool {
+	for _, stmt := range stmts {
+		switch stmt.StmtType() {
+		case parser.NodeVarDeclaration, parser.NodeFunctionDeclaration, parser.NodeStructDeclaration, parser.NodeInterfaceDeclaration, parser.NodeEnumDeclaration, parser.NodeTryCatchStatement:
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) report(msg string, name string, line int, column int) {
+	r.diagnostics = append(r.diagnostics, parser.Diagnostic{Message: msg, Line: line, Column: column, Length: len([]rune(name))})
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, &scope{slots: make(map[string]int), constants: make(map[string]bool)})
+}
+
+func (r *Resolver) endScope() {
+	actual := r.scopes[len(r.scopes)-1]
+
+	// Resolving a body can add more pending bodies
+	for i := 0; i < len(actual.pending); i++ {
+		actual.pending[i]()
+	}
+
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) isTopLevel() bool {
+	return len(r.scopes) == 1
+}
+
+// Declares a name in the actual scope, reporting it if it was already declared there
+func (r *Resolver) declare(name string, binding *parser.Binding, line int, column int) {
+
+	if r.isTopLevel() {
+		if r.declared[name] {
+			r.report("'"+name+"' is already declared in this scope", name, line, column)
+		}
+		r.declared[name] = true
+		return
+	}
+
+	actual := r.scopes[len(r.scopes)-1]
+
+	if _, exists := actual.slots[name]; exists {
+		r.report("'"+name+"' is already declared in this scope", name, line, column)
+	}
+
+	r.forceDeclare(name, binding)
+}
+
+// Declares a name in the actual scope even if it already exists, reusing its slot
+func (r *Resolver) forceDeclare(name string, binding *parser.Binding) {
+
+	if r.isTopLevel() {
+		r.declared[name] = true
+		return
+	}
+
+	actual := r.scopes[len(r.scopes)-1]
+
+	slot, exists := actual.slots[name]
+
+	if !exists {
+		slot = len(actual.slots)
+		actual.slots[name] = slot
+	}
+
+	if binding != nil {
+		binding.Local = true
+		binding.Depth = 0
+		binding.Slot = slot
+	}
+}
+
+// Marks a name of the actual scope as a constant
+func (r *Resolver) makeConstant(name string) {
+	if r.isTopLevel() {
+		r.constants[name] = true
+	} else {
+		r.scopes[len(r.scopes)-1].constants[name] = true
+	}
+}
+
+// Checks if the variable that an assignment would change is a constant
+func (r *Resolver) isConstant(name string) bool {
+	for i := len(r.scopes) - 1; i > 0; i-- {
+		if _, ok := r.scopes[i].slots[name]; ok {
+			return r.scopes[i].constants[name]
+		}
+	}
+	return r.constants[name]
+}
+
+// Finds where a used name is declared
+func (r *Resolver) lookup(name string, binding *parser.Binding, line int, column int) {
+
+	for i := len(r.scopes) - 1; i > 0; i-- {
+		if slot, ok := r.scopes[i].slots[name]; ok {
+			if binding != nil {
+				binding.Local = true
+				binding.Depth = len(r.scopes) - 1 - i
+				binding.Slot = slot
+			}
+			return
+		}
+	}
+
+	// Functions run after the top level declarations, the rest of the code must follow the order
+	if r.declared[name] || (r.functions > 0 && r.globals[name]) {
+		return
+	}
+
+	if r.names[name] {
+		r.report("'"+name+"' is used before its declaration", name, line, column)
+	} else {
+		r.report("'"+name+"' is not declared", name, line, column)
+	}
+}
+
+func (r *Resolver) resolveStmts(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		r.resolveStmt(stmt)
+	}
+}
+
+// Resolves a block that only has its own scope if it declares something
+func (r *Resolver) resolveBlock(stmts []parser.Stmt) {
+
+	if !NeedsScope(stmts) {
+		r.resolveStmts(stmts)
+		return
+	}
+
+	r.beginScope()
+	r.resolveStmts(stmts)
+	r.endScope()
+}
+
+func (r *Resolver) resolveStmt(n parser.Stmt) {
+	switch n.StmtType() {
+	case parser.NodeExpStmt:
+		r.resolveExpression(n.(parser.ExpressionStmtNode).Expression)
+	case parser.NodeVarDeclaration:
+		node := n.(parser.VarDeclarationNode)
+		r.resolveExpression(node.Right)
+		if node.Pattern != nil {
+			r.resolvePattern(*node.Pattern)
+		} else {
+			r.declare(node.Left.Value, node.Left.Binding, node.Left.Line, node.Left.Column)
+		}
+		if node.Constant {
+			for _, name := range node.Names() {
+				r.makeConstant(name)
+			}
+		}
+	case parser.NodeIfStatement:
+		node := n.(parser.IfStatementNode)
+		r.resolveExpression(node.Condition)
+		r.resolveBlock(node.Body)
+		for _, elseif := range node.ElseIf {
+			r.resolveExpression(elseif.Condition)
+			r.resolveBlock(elseif.Body)
+		}
+		if node.ElseBody != nil {
+			r.resolveBlock(node.ElseBody)
+		}
+	case parser.NodeForInStatement:
+		node := n.(parser.ForInSatementNode)
+		r.resolveExpression(node.Iterator)
+		r.beginScope()
+		if node.Pattern != nil {
+			r.resolvePattern(*node.Pattern)
+		} else {
+			r.forceDeclare(node.LocalVarName, node.LocalBinding)
+		}
+		if node.IndexVarName != "" {
+			r.forceDeclare(node.IndexVarName, node.IndexBinding)
+		}
+		r.resolveStmts(node.Body)
+		r.endScope()
+	case parser.NodeFunctionDeclaration:
+		node := n.(parser.FunctionDeclarationNode)
+		r.declare(node.Name, node.Binding, node.Line, node.Column)
+		r.resolveFunction(node.Parameters, node.Body, false, node.Line, node.Column)
+	case parser.NodeReturnStatement:
+		r.resolveExpression(n.(parser.ReturnNode).Right)
+	case parser.NodeYieldStatement:
+		r.resolveExpression(n.(parser.YieldNode).Value)
+	case parser.NodeLoopStatement:
+		r.resolveBlock(n.(parser.LoopStmtNode).Body)
+	case parser.NodeWhileStatement:
+		node := n.(parser.WhileStmtNode)
+		r.resolveExpression(node.Condition)
+		r.resolveBlock(node.Body)
+	case parser.NodeMatchStatement:
+		node := n.(parser.MatchStmtNode)
+		r.resolveExpression(node.Value)
+		// Each arm has its own scope, with the names of its pattern
+		for _, arm := range node.Arms {
+			r.beginScope()
+			r.resolvePattern(arm.Pattern)
+			if arm.Guard != nil {
+				r.resolveExpression(arm.Guard)
+			}
+			r.resolveStmts(arm.Body)
+			r.endScope()
+		}
+	case parser.NodeStructMethodDeclaration:
+		node := n.(parser.StructMethodDeclarationNode)
+		r.lookup(node.Struct, node.StructBinding, node.Line, node.Column)
+		r.resolveFunction(node.Function.Parameters, node.Function.Body, !node.Static, node.Function.Line, node.Function.Column)
+	case parser.NodeTryCatchStatement:
+		node := n.(parser.TryCatchNode)
+		r.resolveStmts(node.Body)
+		r.forceDeclare("error", node.ErrorBinding)
+		r.resolveStmts(node.Catch)
+		if node.Finally != nil {
+			r.resolveBlock(node.Finally)
+		}
+	case parser.NodeStructDeclaration:
+		node := n.(parser.StructDeclarationNode)
+		if node.Parent != "" {
+			r.lookup(node.Parent, node.ParentBinding, node.Line, node.Column)
+		}
+		for _, iface := range node.Interfaces {
+			r.resolveExpression(iface)
+		}
+		for _, value := range node.Defaults {
+			if value != nil {
+				r.resolveExpression(value)
+			}
+		}
+		for _, static := range node.Statics {
+			r.resolveExpression(static.Value)
+		}
+		r.declare(node.Name, node.Binding, node.Line, node.Column)
+	case parser.NodeInterfaceDeclaration:
+		node := n.(parser.InterfaceDeclarationNode)
+		r.declare(node.Name, node.Binding, node.Line, node.Column)
+	case parser.NodeEnumDeclaration:
+		node := n.(parser.EnumDeclarationNode)
+		r.declare(node.Name, node.Binding, node.Line, node.Column)
+	case parser.NodeImportStatement:
+		node := n.(parser.ImportNode)
+		if !r.isTopLevel() {
+			r.report("Modules can only be imported at the top level", node.Path, node.Line, node.Column)
+			return
+		}
+		for _, name := range importedNames(node) {
+			r.declared[name] = true
+			r.constants[name] = true
+		}
+	}
+}
+
+// The parameters, 'this' and 'super' take the first slots of the scope of the call
+// Default values are evaluated in that scope and can use the parameters before them
+func (r *Resolver) resolveFunction(parameters []parser.Parameter, body []parser.Stmt, isMethod bool, line int, column int) {
+
+	actual := r.scopes[len(r.scopes)-1]
+
+	actual.pending = append(actual.pending, func() {
+		r.functions++
+		r.beginScope()
+
+		for _, param := range parameters {
+			if param.Default != nil {
+				r.resolveExpression(param.Default)
+			}
+			r.declare(param.Name, nil, line, column)
+		}
+
+		if isMethod {
+			r.forceDeclare("this", nil)
+			r.forceDeclare("super", nil)
+		}
+
+		r.resolveStmts(body)
+		r.endScope()
+		r.functions--
+	})
+}
+
+func (r *Resolver) resolveExpression(n parser.Exp) {
+
+	switch n.ExpType() {
+	case parser.NodeIdentifier:
+		node := n.(parser.IdentifierNode)
+		r.lookup(node.Value, node.Binding, node.Line, node.Column)
+	case parser.NodeAssignment:
+		node := n.(parser.AssignmentNode)
+		r.resolveExpression(node.Right)
+		r.resolveExpression(node.Left)
+		if identifier, ok := node.Left.(parser.IdentifierNode); ok && r.isConstant(identifier.Value) {
+			r.report("'"+identifier.Value+"' is a constant and can not be reassigned", identifier.Value, identifier.Line, identifier.Column)
+		}
+	case parser.NodeBinaryExp:
+		node := n.(parser.BinaryExpNode)
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Right)
+	case parser.NodeBinaryComparisonExp:
+		node := n.(parser.BinaryComparisonExpNode)
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Right)
+	case parser.NodeBinaryLogicExp:
+		node := n.(parser.BinaryLogicExpNode)
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Right)
+	case parser.NodeUnaryExp:
+		r.resolveExpression(n.(parser.UnaryExpNode).Right)
+	case parser.NodeCallExp:
+		node := n.(parser.CallExpNode)
+		for _, arg := range node.Args {
+			r.resolveExpression(arg)
+		}
+		for _, arg := range node.NamedArgs {
+			r.resolveExpression(arg.Value)
+		}
+		r.resolveExpression(node.Name)
+	case parser.NodeArrayExp:
+		for _, exp := range n.(parser.ArrayExpNode).Value {
+			r.resolveExpression(exp)
+		}
+	case parser.NodeIndexAccessExp:
+		node := n.(parser.IndexAccessExpNode)
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Index)
+	case parser.NodeDictionaryExp:
+		for _, exp := range n.(parser.DictionaryExpNode).Value {
+			r.resolveExpression(exp)
+		}
+	case parser.NodeObjectInitExp:
+		node := n.(parser.ObjectInitExpNode)
+		r.resolveExpression(node.Struct)
+		for _, exp := range node.Value.Value {
+			r.resolveExpression(exp)
+		}
+	case parser.NodeMemberExp:
+		r.resolveExpression(n.(parser.MemberExpNode).Left)
+	case parser.NodeSliceExp:
+		node := n.(parser.SliceExpNode)
+		r.resolveExpression(node.Left)
+		if node.From != nil {
+			r.resolveExpression(node.From)
+		}
+		if node.To != nil {
+			r.resolveExpression(node.To)
+		}
+	case parser.NodeInterpolationExp:
+		for _, part := range n.(parser.InterpolationNode).Parts {
+			r.resolveExpression(part)
+		}
+	case parser.NodeRangeExp:
+		node := n.(parser.RangeExpNode)
+		r.resolveExpression(node.From)
+		r.resolveExpression(node.To)
+		if node.Step != nil {
+			r.resolveExpression(node.Step)
+		}
+	case parser.NodeTernaryExp:
+		node := n.(parser.TernaryExpNode)
+		r.resolveExpression(node.Condition)
+		r.resolveExpression(node.Left)
+		r.resolveExpression(node.Right)
+	case parser.NodeAnonFunctionDeclaration:
+		node := n.(parser.AnonFunctionDeclarationNode)
+		r.resolveFunction(node.Parameters, node.Body, false, node.Line, node.Column)
+	}
+}
+
+// Declares the names of a pattern and finds the structs it uses
+func (r *Resolver) resolvePattern(pattern parser.Pattern) {
+	switch pattern.Kind {
+	case parser.PatternBinding:
+		r.declare(pattern.Name, pattern.Binding, pattern.Line, pattern.Column)
+	case parser.PatternObject:
+		r.lookup(pattern.Name, pattern.Binding, pattern.Line, pattern.Column)
+	case parser.PatternLiteral, parser.PatternEnum:
+		r.resolveExpression(pattern.Value)
+	}
+
+	for _, item := range pattern.Items {
+		r.resolvePattern(item)
+	}
+
+	if pattern.Rest != nil {
+		r.resolvePattern(*pattern.Rest)
+	}
+}
+
+// Names that a top level statement adds to the module environment
+func (r *Resolver) declaredNames(n parser.Stmt) []string {
+	switch n.StmtType() {
+	case parser.NodeVarDeclaration:
+		return n.(parser.VarDeclarationNode).Names()
+	case parser.NodeFunctionDeclaration:
+		return []string{n.(parser.FunctionDeclarationNode).Name}
+	case parser.NodeStructDeclaration:
+		return []string{n.(parser.StructDeclarationNode).Name}
+	case parser.NodeInterfaceDeclaration:
+		return []string{n.(parser.InterfaceDeclarationNode).Name}
+	case parser.NodeEnumDeclaration:
+		return []string{n.(parser.EnumDeclarationNode).Name}
+	case parser.NodeTryCatchStatement:
+		return []string{"error"}
+	case parser.NodeImportStatement:
+		return importedNames(n.(parser.ImportNode))
+	}
+	return nil
+}
+
+// Standard libraries declare their own names, files are declared with their alias
+func importedNames(node parser.ImportNode) []string {
+
+	load, ok := lib.GetLibMap()[node.Path]
+
+	if !ok {
+		return []string{node.Alias}
+	}
+
+	env := environment.NewEnvironment()
+	load(env)
+
+	names := make([]string, 0, len(env.Variables))
+
+	for name := range env.Variables {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// Collects every name declared in the statements, at any depth
+func collectNames(stmts []parser.Stmt, names map[string]bool) {
+	for _, stmt := range stmts {
+		switch stmt.StmtType() {
+		case parser.NodeVarDeclaration:
+			for _, name := range stmt.(parser.VarDeclarationNode).Names() {
+				names[name] = true
+			}
+		case parser.NodeFunctionDeclaration:
+			node := stmt.(parser.FunctionDeclarationNode)
+			names[node.Name] = true
+			collectNames(node.Body, names)
+		case parser.NodeStructDeclaration:
+			names[stmt.(parser.StructDeclarationNode).Name] = true
+		case parser.NodeInterfaceDeclaration:
+			names[stmt.(parser.InterfaceDeclarationNode).Name] = true
+		case parser.NodeEnumDeclaration:
+			names[stmt.(parser.EnumDeclarationNode).Name] = true
+		case parser.NodeIfStatement:
+			node := stmt.(parser.IfStatementNode)
+			collectNames(node.Body, names)
+			for _, elseif := range node.ElseIf {
+				collectNames(elseif.Body, names)
+			}
+			collectNames(node.ElseBody, names)
+		case parser.NodeForInStatement:
+			collectNames(stmt.(parser.ForInSatementNode).Body, names)
+		case parser.NodeLoopStatement:
+			collectNames(stmt.(parser.LoopStmtNode).Body, names)
+		case parser.NodeWhileStatement:
+			collectNames(stmt.(parser.WhileStmtNode).Body, names)
+		case parser.NodeMatchStatement:
+			for _, arm := range stmt.(parser.MatchStmtNode).Arms {
+				collectNames(arm.Body, names)
+			}
+		case parser.NodeTryCatchStatement:
+			node := stmt.(parser.TryCatchNode)
+			collectNames(node.Body, names)
+			collectNames(node.Catch, names)
+			collectNames(node.Finally, names)
+		case parser.NodeStructMethodDeclaration:
+			collectNames(stmt.(parser.StructMethodDeclarationNode).Function.Body, names)
+		}
+	}
+}
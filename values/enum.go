@@ -0,0 +1,120 @@
+package values
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Declared with enum Color { Red, Green, Blue }, it is used as a pointer so two enums are never the same
+type EnumValue struct {
+	Name    string
+	Members []EnumMemberValue
+}
+
+// A member of an enum, members declared with fields like Ok(value) are called to give them a payload
+type EnumMemberValue struct {
+	Enum    *EnumValue
+	Name    string
+	Ordinal int
+	Fields  []string
+	// nil until the member is called with its payload
+	Payload []RuntimeValue
+}
+
+// Creates an enum whose members have no payload
+func NewEnum(name string, members ...string) *EnumValue {
+	enum := &EnumValue{Name: name}
+	for _, member := range members {
+		enum.Add(member, nil)
+	}
+	return enum
+}
+
+// Adds a member after the ones it already has
+func (e *EnumValue) Add(name string, fields []string) {
+	e.Members = append(e.Members, EnumMemberValue{Enum: e, Name: name, Ordinal: len(e.Members), Fields: fields})
+}
+
+func (e *EnumValue) GetNumber() float64 {
+	return 1
+}
+func (e *EnumValue) GetString() string {
+	return e.Name
+}
+func (e *EnumValue) GetBool() bool {
+	return true
+}
+func (e *EnumValue) GetType() ValueType {
+	return EnumType
+}
+
+func (e *EnumValue) GetProp(name string) (RuntimeValue, error) {
+	if member, exists := e.Member(name); exists {
+		return member, nil
+	}
+	return NothingValue{}, fmt.Errorf("enum %s has no member %s", e.Name, name)
+}
+
+func (e *EnumValue) Member(name string) (EnumMemberValue, bool) {
+	for _, member := range e.Members {
+		if member.Name == name {
+			return member, true
+		}
+	}
+	return EnumMemberValue{}, false
+}
+
+// Loops over the members in the order they were declared
+func (e *EnumValue) Iterate() Iterator {
+	items := make([]RuntimeValue, len(e.Members))
+	for i, member := range e.Members {
+		items[i] = member
+	}
+	return &listIterator{items: items}
+}
+
+func (m EnumMemberValue) GetNumber() float64 {
+	return float64(m.Ordinal)
+}
+
+// Members are shown by their name, with the payload if they have one like Ok(5)
+func (m EnumMemberValue) GetString() string {
+	if m.Payload == nil {
+		return m.Name
+	}
+
+	payload := make([]string, len(m.Payload))
+	for i, value := range m.Payload {
+		payload[i] = value.GetString()
+	}
+	return m.Name + "(" + strings.Join(payload, ", ") + ")"
+}
+func (m EnumMemberValue) GetBool() bool {
+	return true
+}
+func (m EnumMemberValue) GetType() ValueType {
+	return EnumMemberType
+}
+
+// Gives the fields of the payload, the name and the ordinal of the member
+func (m EnumMemberValue) GetProp(name string) (RuntimeValue, error) {
+	for i, field := range m.Fields {
+		if field == name && m.Payload != nil {
+			return m.Payload[i], nil
+		}
+	}
+
+	switch name {
+	case "name":
+		return StringValue{Value: m.Name}, nil
+	case "ordinal":
+		return NumberValue{Value: float64(m.Ordinal)}, nil
+	}
+
+	return NothingValue{}, fmt.Errorf("property %s does not exists in %s.%s", name, m.Enum.Name, m.Name)
+}
+
+// Checks if both are the same member of the same enum, the payloads are compared apart
+func (m EnumMemberValue) Is(other EnumMemberValue) bool {
+	return m.Enum == other.Enum && m.Ordinal == other.Ordinal
+}
@@ -0,0 +1,116 @@
+package values
+
+import "reflect"
+
+// Compares two values by their content, arrays, dictionaries and objects are equal if all their items are
+// The second result is false if the values can not be compared, like functions
+func Equals(left RuntimeValue, right RuntimeValue) (bool, bool) {
+	return equals(left, right, map[[2]uintptr]bool{})
+}
+
+// Pairs already being compared are taken as equal, so values that contain themselves do not loop forever
+func equals(left RuntimeValue, right RuntimeValue, visiting map[[2]uintptr]bool) (bool, bool) {
+
+	if left.GetType() != right.GetType() {
+		return false, true
+	}
+
+	switch l := left.(type) {
+	case StringValue:
+		return l.Value == right.(StringValue).Value, true
+	case NumberValue:
+		return l.Value == right.(NumberValue).Value, true
+	case BoolValue:
+		return l.Value == right.(BoolValue).Value, true
+	case NothingValue:
+		return true, true
+	case RangeValue:
+		return l == right.(RangeValue), true
+	case StructValue:
+		return sameStruct(l, right.(StructValue)), true
+	case *EnumValue:
+		return l == right.(*EnumValue), true
+	case EnumMemberValue:
+		r := right.(EnumMemberValue)
+
+		if !l.Is(r) || (l.Payload == nil) != (r.Payload == nil) {
+			return false, true
+		}
+
+		for i := range l.Payload {
+			if equal, ok := equals(l.Payload[i], r.Payload[i], visiting); !equal || !ok {
+				return equal, ok
+			}
+		}
+		return true, true
+	case *ArrayValue:
+		r := right.(*ArrayValue)
+
+		if len(l.Value) != len(r.Value) {
+			return false, true
+		}
+
+		pair := [2]uintptr{reflect.ValueOf(l).Pointer(), reflect.ValueOf(r).Pointer()}
+		if l == r || visiting[pair] {
+			return true, true
+		}
+		visiting[pair] = true
+
+		for i := range l.Value {
+			if equal, ok := equals(l.Value[i], r.Value[i], visiting); !equal || !ok {
+				return equal, ok
+			}
+		}
+		return true, true
+	case *DictionaryValue:
+		r := right.(*DictionaryValue)
+
+		pair := [2]uintptr{reflect.ValueOf(l).Pointer(), reflect.ValueOf(r).Pointer()}
+		if l == r || visiting[pair] {
+			return true, true
+		}
+		visiting[pair] = true
+
+		return equalMaps(l.Value, r.Value, visiting)
+	case *ObjectValue:
+		r := right.(*ObjectValue)
+
+		if !sameStruct(l.Struct, r.Struct) {
+			return false, true
+		}
+
+		pair := [2]uintptr{reflect.ValueOf(l).Pointer(), reflect.ValueOf(r).Pointer()}
+		if l == r || visiting[pair] {
+			return true, true
+		}
+		visiting[pair] = true
+
+		return equalMaps(l.Value, r.Value, visiting)
+	}
+
+	return false, false
+}
+
+func equalMaps(left map[string]RuntimeValue, right map[string]RuntimeValue, visiting map[[2]uintptr]bool) (bool, bool) {
+
+	if len(left) != len(right) {
+		return false, true
+	}
+
+	for key, value := range left {
+		other, exists := right[key]
+		if !exists {
+			return false, true
+		}
+		if equal, ok := equals(value, other, visiting); !equal || !ok {
+			return equal, ok
+		}
+	}
+
+	return true, true
+}
+
+// Structs are only equal to themselves, two declarations with the same name are different structs
+func sameStruct(left StructValue, right StructValue) bool {
+	return left.Name == right.Name && reflect.ValueOf(left.Methods).Pointer() == reflect.ValueOf(right.Methods).Pointer()
+}
@@ -12,8 +12,31 @@ const (
 	InvalidConversionError string = "InvalidConversionError"
 	CircularImportError    string = "CircularImportError"
 	PropertyError          string = "PropertyError"
+	SyntaxError            string = "SyntaxError"
 )
 
+// Built in kinds of errors, the type of the errors they raise is one of its members
+var ErrorKinds = NewEnum("ErrorKind",
+	RuntimeError,
+	TypeError,
+	InvalidIndexError,
+	IdentifierError,
+	ZeroDivisionError,
+	InvalidArgumentError,
+	InvalidConversionError,
+	CircularImportError,
+	PropertyError,
+	SyntaxError,
+)
+
+// Gives the member of ErrorKinds with that name, the kind of the errors of the user is nothing
+func ErrorKindOf(name string) RuntimeValue {
+	if member, exists := ErrorKinds.Member(name); exists {
+		return member
+	}
+	return NothingValue{}
+}
+
 type ErrorValue struct {
 	Value     string
 	ErrorType string
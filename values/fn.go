@@ -7,12 +7,21 @@ import (
 )
 
 type FunctionValue struct {
+	Name         string
 	Struct       string
 	StructObjRef *ObjectValue
-	Body         []parser.Stmt
-	Parameters   []string
-	Environment  interface{}
-	Evaluator    common.Evaluator
+	// Struct inherited by the one that declares the method, used by super
+	Super *StructValue
+	// Struct of the method where the function was declared, it can use its private members
+	Owner       *StructValue
+	Body        []parser.Stmt
+	Parameters  []parser.Parameter
+	Generator   bool
+	Environment interface{}
+	Evaluator   common.Evaluator
+
+	// Compiled body, only set for the functions created by the vm engine
+	Code interface{}
 }
 
 func (a FunctionValue) GetNumber() float64 {
@@ -32,3 +41,17 @@ func (a FunctionValue) GetType() ValueType {
 func (f FunctionValue) GetProp(name string) (RuntimeValue, error) {
 	return NothingValue{}, fmt.Errorf("property %s does not exists", name)
 }
+
+// Checks if the function can be called with that number of arguments
+func (f FunctionValue) Accepts(count int) bool {
+	required := 0
+	for _, param := range f.Parameters {
+		if param.Rest {
+			return required <= count
+		}
+		if param.Default == nil {
+			required++
+		}
+	}
+	return required <= count && count <= len(f.Parameters)
+}
@@ -0,0 +1,66 @@
+package values
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Methods that a struct must have, declared with interface Shape { area() }
+type InterfaceValue struct {
+	Name    string
+	Methods []InterfaceMethod
+}
+
+type InterfaceMethod struct {
+	Name string
+	// Number of arguments the method is called with
+	Arity int
+}
+
+func (i InterfaceValue) GetNumber() float64 {
+	return 1
+}
+func (i InterfaceValue) GetString() string {
+	return "Interface"
+}
+func (i InterfaceValue) GetBool() bool {
+	return true
+}
+func (i InterfaceValue) GetType() ValueType {
+	return InterfaceType
+}
+
+func (i InterfaceValue) GetProp(name string) (RuntimeValue, error) {
+	return NothingValue{}, fmt.Errorf("property %s does not exists", name)
+}
+
+// Explains the first method of the interface that the struct does not have, empty when it has all of them
+// Inherited methods count, and a method can take more arguments if they have default values
+func (i InterfaceValue) Unmet(s StructValue) string {
+	for _, method := range i.Methods {
+		found, exists := s.Method(method.Name)
+
+		if !exists {
+			return "it has no method " + method.Name
+		}
+
+		fn, ok := found.(FunctionValue)
+
+		if ok && !fn.Accepts(method.Arity) {
+			return "its method " + method.Name + " can not be called with " + strconv.Itoa(method.Arity) + " arguments"
+		}
+	}
+	return ""
+}
+
+// Checks if the value is a struct, or an object of a struct, that has every method of the interface
+// It does not need to declare it with implements
+func (i InterfaceValue) ImplementedBy(value RuntimeValue) bool {
+	switch value := value.(type) {
+	case *ObjectValue:
+		return i.Unmet(value.Struct) == ""
+	case StructValue:
+		return i.Unmet(value) == ""
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package values
+
+import "sort"
+
+// Values that can be walked with a for in loop
+type Iterable interface {
+	Iterate() Iterator
+}
+
+// Gives the elements of an iterable one by one
+type Iterator interface {
+	// Returns the element and its index, or false when there are no more
+	// A loop stops with the error if the element is an ErrorValue
+	Next() (RuntimeValue, RuntimeValue, bool)
+}
+
+// Iterators that keep something running until their last element, like generators
+type Closer interface {
+	Close()
+}
+
+// Lets the iterator free what it keeps, loops call it when they stop before the end
+func CloseIterator(it Iterator) {
+	if closer, ok := it.(Closer); ok {
+		closer.Close()
+	}
+}
+
+// Walks a list of elements giving their position as index
+type listIterator struct {
+	items []RuntimeValue
+	index int
+}
+
+func (it *listIterator) Next() (RuntimeValue, RuntimeValue, bool) {
+	if it.index >= len(it.items) {
+		return nil, nil, false
+	}
+	it.index++
+	return it.items[it.index-1], NumberValue{Value: float64(it.index - 1)}, true
+}
+
+func (a *ArrayValue) Iterate() Iterator {
+	return &listIterator{items: a.Value}
+}
+
+// Gives the keys in order and their values as index
+type dictionaryIterator struct {
+	dict  map[string]RuntimeValue
+	keys  []string
+	index int
+}
+
+func (it *dictionaryIterator) Next() (RuntimeValue, RuntimeValue, bool) {
+	if it.index >= len(it.keys) {
+		return nil, nil, false
+	}
+	key := it.keys[it.index]
+	it.index++
+	return StringValue{Value: key}, it.dict[key], true
+}
+
+func (d *DictionaryValue) Iterate() Iterator {
+	keys := make([]string, 0, len(d.Value))
+	for key := range d.Value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return &dictionaryIterator{dict: d.Value, keys: keys}
+}
+
+// Strings are walked by character, not by byte
+func (s StringValue) Iterate() Iterator {
+	chars := []rune(s.Value)
+	items := make([]RuntimeValue, len(chars))
+	for i, char := range chars {
+		items[i] = StringValue{Value: string(char)}
+	}
+	return &listIterator{items: items}
+}
+
+// Produces the numbers of the range as they are needed
+type rangeIterator struct {
+	r     RangeValue
+	n     float64
+	index int
+}
+
+func (it *rangeIterator) Next() (RuntimeValue, RuntimeValue, bool) {
+	if !it.r.Contains(it.n) {
+		return nil, nil, false
+	}
+	n := it.n
+	it.n += it.r.Step
+	it.index++
+	return NumberValue{Value: n}, NumberValue{Value: float64(it.index - 1)}, true
+}
+
+func (r RangeValue) Iterate() Iterator {
+	return &rangeIterator{r: r, n: r.From}
+}
@@ -4,6 +4,8 @@ import "fmt"
 
 type NativeFunctionValue struct {
 	Value func(args []RuntimeValue) RuntimeValue
+	// Takes arguments by name, they are given as an OptionsValue after the positional ones
+	Options bool
 }
 
 func (a NativeFunctionValue) GetNumber() float64 {
@@ -2,6 +2,7 @@ package values
 
 import (
 	"fmt"
+	"strings"
 )
 
 type ObjectValue struct {
@@ -12,9 +13,37 @@ type ObjectValue struct {
 func (a ObjectValue) GetNumber() float64 {
 	return 1
 }
+
+// Objects whose struct has a __str method are shown with it
 func (a ObjectValue) GetString() string {
+	if text, ok := a.ToString().(StringValue); ok {
+		return text.Value
+	}
 	return "Object"
 }
+
+// Gives the result of the __str method, an error if it fails or does not return a string
+func (a *ObjectValue) ToString() RuntimeValue {
+	method, exists := a.Struct.Method("__str")
+	fn, ok := method.(FunctionValue)
+
+	if !exists || !ok || fn.Evaluator == nil {
+		return StringValue{Value: "Object"}
+	}
+
+	fn.StructObjRef = a
+	result, _ := fn.Evaluator.ExecuteCallback(fn, nil).(RuntimeValue)
+
+	if result == nil {
+		result = NothingValue{}
+	}
+
+	if result.GetType() == ErrorType || result.GetType() == StringType {
+		return result
+	}
+
+	return ErrorValue{ErrorType: TypeError, Value: "The method __str of " + a.Struct.Name + " must return a string, not " + result.GetType().String()}
+}
 func (a ObjectValue) GetBool() bool {
 	return true
 }
@@ -36,6 +65,9 @@ func (a *ObjectValue) GetProp(prop string) (RuntimeValue, error) {
 				}
 
 				key := args[0].(StringValue).Value
+				if IsPrivate(key) {
+					return ErrorValue{ErrorType: PropertyError, Value: "Key " + key + " is private"}
+				}
 				if val, ok := a.Value[key]; ok {
 					return val
 				}
@@ -47,7 +79,7 @@ func (a *ObjectValue) GetProp(prop string) (RuntimeValue, error) {
 	propValue, exists := a.Value[prop]
 
 	if !exists {
-		propValue, metExists := a.Struct.Methods[prop]
+		propValue, metExists := a.Struct.Method(prop)
 
 		if !metExists {
 			return NothingValue{}, fmt.Errorf("property %s does not exists", prop)
@@ -66,3 +98,8 @@ func (a *ObjectValue) GetProp(prop string) (RuntimeValue, error) {
 
 	return propValue, nil
 }
+
+// Members and module names that start with one underscore are private, __add and the other operator methods are not
+func IsPrivate(name string) bool {
+	return strings.HasPrefix(name, "_") && !strings.HasPrefix(name, "__")
+}
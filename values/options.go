@@ -0,0 +1,19 @@
+package values
+
+// Arguments given by name to a native function, like host in postgres.connect(host: "db")
+// They are passed in a dictionary after the positional arguments
+type OptionsValue struct {
+	*DictionaryValue
+}
+
+// Separates the options from the positional arguments of a native function
+func SplitOptions(args []RuntimeValue) ([]RuntimeValue, map[string]RuntimeValue) {
+
+	if len(args) > 0 {
+		if options, ok := args[len(args)-1].(OptionsValue); ok {
+			return args[:len(args)-1], options.Value
+		}
+	}
+
+	return args, map[string]RuntimeValue{}
+}
@@ -0,0 +1,46 @@
+package values
+
+import "fmt"
+
+// Numbers from From to To counting by Step, they are produced while iterating so no array is created
+type RangeValue struct {
+	From      float64
+	To        float64
+	Step      float64
+	Inclusive bool
+}
+
+func (r RangeValue) GetType() ValueType {
+	return RangeType
+}
+
+func (r RangeValue) GetString() string {
+	text := NumberValue{Value: r.From}.GetString() + ".."
+	if r.Inclusive {
+		text += "="
+	}
+	text += NumberValue{Value: r.To}.GetString()
+
+	if r.Step != 1 {
+		text += " step " + NumberValue{Value: r.Step}.GetString()
+	}
+	return text
+}
+func (r RangeValue) GetNumber() float64 {
+	return 0
+}
+func (r RangeValue) GetBool() bool {
+	return r.Contains(r.From)
+}
+
+func (r RangeValue) GetProp(name string) (RuntimeValue, error) {
+	return NothingValue{}, fmt.Errorf("property %s does not exists", name)
+}
+
+// Checks if the given number has not gone past the end of the range, in the direction of the step
+func (r RangeValue) Contains(n float64) bool {
+	if r.Step > 0 {
+		return n < r.To || r.Inclusive && n == r.To
+	}
+	return n > r.To || r.Inclusive && n == r.To
+}
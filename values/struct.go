@@ -6,6 +6,14 @@ type StructValue struct {
 	Name       string
 	Properties []string
 	Methods    map[string]RuntimeValue
+	// Struct that it inherits from, nil when it has not
+	Parent *StructValue
+	// Functions that give the default value of a property, inherited ones included
+	Defaults map[string]RuntimeValue
+	// Members of the struct itself, like Person.create
+	Statics map[string]RuntimeValue
+	// Interfaces declared with implements, not the inherited ones
+	Interfaces []InterfaceValue
 }
 
 func (a StructValue) GetNumber() float64 {
@@ -20,6 +28,33 @@ func (a StructValue) GetBool() bool {
 func (a StructValue) GetType() ValueType {
 	return StructType
 }
+
+// Gives the static members of the struct or of the structs it inherits from
 func (s StructValue) GetProp(name string) (RuntimeValue, error) {
+	for actual := &s; actual != nil; actual = actual.Parent {
+		if static, exists := actual.Statics[name]; exists {
+			return static, nil
+		}
+	}
 	return NothingValue{}, fmt.Errorf("property %s does not exists", name)
 }
+
+// Finds a method of the struct or of the structs it inherits from
+func (s StructValue) Method(name string) (RuntimeValue, bool) {
+	for actual := &s; actual != nil; actual = actual.Parent {
+		if method, exists := actual.Methods[name]; exists {
+			return method, true
+		}
+	}
+	return nil, false
+}
+
+// Checks if the struct is the other one or inherits from it
+func (s StructValue) Is(other StructValue) bool {
+	for actual := &s; actual != nil; actual = actual.Parent {
+		if sameStruct(*actual, other) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package values
+
+import "fmt"
+
+// Value of super inside a method, it gives the methods of the inherited struct called on the same object
+type SuperValue struct {
+	Object *ObjectValue
+	// nil when the struct of the method does not inherit from another one
+	Struct *StructValue
+	Owner  string
+}
+
+func (s SuperValue) GetNumber() float64 {
+	return 1
+}
+func (s SuperValue) GetString() string {
+	return "Super"
+}
+func (s SuperValue) GetBool() bool {
+	return true
+}
+func (s SuperValue) GetType() ValueType {
+	return SuperType
+}
+
+func (s SuperValue) GetProp(name string) (RuntimeValue, error) {
+
+	if s.Struct == nil {
+		return NothingValue{}, fmt.Errorf("struct %s does not inherit from another struct", s.Owner)
+	}
+
+	method, exists := s.Struct.Method(name)
+
+	if !exists {
+		return NothingValue{}, fmt.Errorf("method %s does not exists in struct %s", name, s.Struct.Name)
+	}
+
+	if fn, ok := method.(FunctionValue); ok {
+		fn.StructObjRef = s.Object
+		return fn, nil
+	}
+
+	return method, nil
+}
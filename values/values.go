@@ -21,6 +21,12 @@ const (
 	FileType
 	NativeMethodType
 	CustomType
+	RangeType
+	GeneratorType
+	SuperType
+	InterfaceType
+	EnumType
+	EnumMemberType
 )
 
 func (v ValueType) String() string {
@@ -41,7 +47,14 @@ func (v ValueType) String() string {
 		"Object",
 		"Namespace",
 		"File",
+		"NativeMethod",
 		"Custom",
+		"range",
+		"generator",
+		"Super",
+		"Interface",
+		"Enum",
+		"EnumMember",
 	}[v]
 }
 
@@ -0,0 +1,1082 @@
+package vm
+
+import (
+	"evie/evruntime"
+	"evie/parser"
+	"evie/resolver"
+	"evie/values"
+	"sort"
+)
+
+type Instruction struct {
+	Op  Opcode
+	Arg int
+}
+
+// Position in the source of the node an instruction was compiled from
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Compiled code of a module or a function
+type Chunk struct {
+	Code      []Instruction
+	Positions []Position
+	Constants []values.RuntimeValue
+	Names     []string
+	Locals    []Local
+	Scopes    []Scope
+	Keys      [][]string
+	Functions []*FunctionProto
+	Structs   []parser.StructDeclarationNode
+	Enums     []parser.EnumDeclarationNode
+	Imports   []parser.ImportNode
+	Patterns  []Pattern
+
+	// Slots that a frame of the chunk keeps for its locals, the values it works with go after them
+	Slots int
+}
+
+// A variable found by the resolver, kept in a slot of the frame or in an upvalue of the closure
+type Local struct {
+	Name string
+	Slot int
+}
+
+// Slots of a block scope, they are emptied each time the block runs
+type Scope struct {
+	From int
+	Size int
+}
+
+// A pattern whose values are computed on the stack before it is matched
+// The binding of a literal, enum or object pattern is the index of its value, and the names are in the slots of the frame
+type Pattern struct {
+	parser.Pattern
+	Values int
+}
+
+// A function ready to be instantiated as a closure
+type FunctionProto struct {
+	Name       string
+	Struct     string
+	Parameters []parser.Parameter
+	Generator  bool
+	Chunk      *Chunk
+	Upvalues   []UpvalueRef
+}
+
+// Where a closure takes a variable from when it is created, a slot of the frame that creates it or one of its upvalues
+type UpvalueRef struct {
+	Local bool
+	Index int
+}
+
+// Bits of the argument of OpSlice
+const (
+	sliceFrom = 1 << iota
+	sliceTo
+)
+
+// Bits of the argument of OpMakeRange
+const (
+	rangeInclusive = 1 << iota
+	rangeStep
+)
+
+type blockKind uint8
+
+const (
+	blockLoop blockKind = iota
+	blockHandler
+)
+
+// Blocks the compiler is inside of, break, continue and return need to leave them cleanly
+type block struct {
+	kind blockKind
+
+	// Loops
+	iterator       bool
+	continueTarget int
+	breaks         []int
+
+	// Handlers, the finally code to run when leaving the try
+	finally []parser.Stmt
+}
+
+type Compiler struct {
+	chunk       *Chunk
+	names       map[string]int
+	blocks      []*block
+	diagnostics []parser.Diagnostic
+
+	// Compiler of the function where this one is declared, nil for the module
+	enclosing *Compiler
+
+	// Scopes of the resolver that keep locals, the module scope keeps its names in the environment
+	scopes []int
+
+	// First free slot of the frame, the scopes that end give their slots back
+	slots int
+
+	upvalues []UpvalueRef
+}
+
+// Compiles the statements of a module
+func Compile(ast []parser.Stmt) (*Chunk, error) {
+
+	c := newCompiler()
+
+	for _, stmt := range ast {
+		c.compileStmt(stmt)
+	}
+
+	c.emit(OpNothing, 0, Position{})
+	c.emit(OpReturn, 0, Position{})
+
+	if len(c.diagnostics) > 0 {
+		return nil, parser.ParseError{Diagnostics: c.diagnostics}
+	}
+
+	return c.chunk, nil
+}
+
+func newCompiler() *Compiler {
+	return &Compiler{
+		chunk: &Chunk{},
+		names: make(map[string]int),
+	}
+}
+
+func (c *Compiler) emit(op Opcode, arg int, pos Position) int {
+	c.chunk.Code = append(c.chunk.Code, Instruction{Op: op, Arg: arg})
+	c.chunk.Positions = append(c.chunk.Positions, pos)
+	return len(c.chunk.Code) - 1
+}
+
+// Points the jump at the given address to the next instruction
+func (c *Compiler) patch(address int) {
+	c.chunk.Code[address].Arg = len(c.chunk.Code)
+}
+
+func (c *Compiler) name(name string) int {
+
+	if index, ok := c.names[name]; ok {
+		return index
+	}
+
+	c.chunk.Names = append(c.chunk.Names, name)
+	c.names[name] = len(c.chunk.Names) - 1
+
+	return len(c.chunk.Names) - 1
+}
+
+func (c *Compiler) local(name string, slot int) int {
+	c.chunk.Locals = append(c.chunk.Locals, Local{Name: name, Slot: slot})
+	return len(c.chunk.Locals) - 1
+}
+
+func isLocal(binding *parser.Binding) bool {
+	return binding != nil && binding.Local
+}
+
+// Starts a scope of the resolver with the given number of slots, they go after the ones of the scopes around it
+func (c *Compiler) beginScope(size int) int {
+
+	base := c.slots
+	c.scopes = append(c.scopes, base)
+	c.slots += size
+
+	if c.slots > c.chunk.Slots {
+		c.chunk.Slots = c.slots
+	}
+
+	return base
+}
+
+func (c *Compiler) endScope() {
+	c.slots = c.scopes[len(c.scopes)-1]
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// Finds the slot of the frame of a local, or its upvalue when it belongs to a function around this one
+func (c *Compiler) resolveLocal(binding *parser.Binding) (int, bool) {
+
+	if binding.Depth < len(c.scopes) {
+		return c.scopes[len(c.scopes)-1-binding.Depth] + binding.Slot, false
+	}
+
+	return c.upvalue(binding.Depth-len(c.scopes), binding.Slot), true
+}
+
+// Returns the upvalue of a local of the enclosing functions, the depth counts from the scope where this function is declared
+func (c *Compiler) upvalue(depth int, slot int) int {
+
+	enclosing := c.enclosing
+	ref := UpvalueRef{Local: true}
+
+	if depth < len(enclosing.scopes) {
+		ref.Index = enclosing.scopes[len(enclosing.scopes)-1-depth] + slot
+	} else {
+		ref = UpvalueRef{Index: enclosing.upvalue(depth-len(enclosing.scopes), slot)}
+	}
+
+	for index, upvalue := range c.upvalues {
+		if upvalue == ref {
+			return index
+		}
+	}
+
+	c.upvalues = append(c.upvalues, ref)
+
+	return len(c.upvalues) - 1
+}
+
+func (c *Compiler) loadVar(name string, binding *parser.Binding, pos Position) {
+
+	if !isLocal(binding) {
+		c.emit(OpLoadName, c.name(name), pos)
+		return
+	}
+
+	if slot, upvalue := c.resolveLocal(binding); upvalue {
+		c.emit(OpLoadUpvalue, c.local(name, slot), pos)
+	} else {
+		c.emit(OpLoadLocal, c.local(name, slot), pos)
+	}
+}
+
+func (c *Compiler) storeVar(name string, binding *parser.Binding, pos Position) {
+
+	if !isLocal(binding) {
+		c.emit(OpStoreName, c.name(name), pos)
+		return
+	}
+
+	if slot, upvalue := c.resolveLocal(binding); upvalue {
+		c.emit(OpStoreUpvalue, c.local(name, slot), pos)
+	} else {
+		c.emit(OpStoreLocal, c.local(name, slot), pos)
+	}
+}
+
+// Declares the value at the top of the stack, globals are declared with the given opcode
+// Names are always declared in the actual scope, so locals never are upvalues here
+func (c *Compiler) declareVar(name string, binding *parser.Binding, op Opcode, pos Position) {
+	if isLocal(binding) {
+		slot, _ := c.resolveLocal(binding)
+		c.emit(OpDeclareLocal, c.local(name, slot), pos)
+	} else {
+		c.emit(op, c.name(name), pos)
+	}
+}
+
+// Pushes the values that the pattern compares with and returns the index of its copy for the vm
+func (c *Compiler) pattern(pattern parser.Pattern) int {
+
+	values := 0
+	compiled := c.preparePattern(pattern, &values)
+
+	c.chunk.Patterns = append(c.chunk.Patterns, Pattern{Pattern: compiled, Values: values})
+
+	return len(c.chunk.Patterns) - 1
+}
+
+// Copies the pattern giving its values the index where they are on the stack and its locals their slot of the frame
+// The values are pushed in the order the resolver visits the pattern
+func (c *Compiler) preparePattern(pattern parser.Pattern, values *int) parser.Pattern {
+
+	switch pattern.Kind {
+	case parser.PatternBinding:
+		if isLocal(pattern.Binding) {
+			slot, _ := c.resolveLocal(pattern.Binding)
+			pattern.Binding = &parser.Binding{Local: true, Slot: slot}
+		}
+	case parser.PatternObject:
+		c.loadVar(pattern.Name, pattern.Binding, Position{pattern.Line, pattern.Column})
+		pattern.Binding = &parser.Binding{Slot: *values}
+		*values++
+	case parser.PatternLiteral, parser.PatternEnum:
+		c.compileExpression(pattern.Value)
+		pattern.Binding = &parser.Binding{Slot: *values}
+		*values++
+	}
+
+	if pattern.Items != nil {
+		items := make([]parser.Pattern, len(pattern.Items))
+		for i, item := range pattern.Items {
+			items[i] = c.preparePattern(item, values)
+		}
+		pattern.Items = items
+	}
+
+	if pattern.Rest != nil {
+		rest := c.preparePattern(*pattern.Rest, values)
+		pattern.Rest = &rest
+	}
+
+	return pattern
+}
+
+func (c *Compiler) constant(value values.RuntimeValue) int {
+	c.chunk.Constants = append(c.chunk.Constants, value)
+	return len(c.chunk.Constants) - 1
+}
+
+func (c *Compiler) report(msg string, pos Position) {
+	c.diagnostics = append(c.diagnostics, parser.Diagnostic{Message: msg, Line: pos.Line, Column: pos.Column, Length: 1})
+}
+
+func (c *Compiler) pushBlock(b *block) {
+	c.blocks = append(c.blocks, b)
+}
+
+func (c *Compiler) popBlock() {
+	c.blocks = c.blocks[:len(c.blocks)-1]
+}
+
+// Enters a scope of a block, its slots are emptied each time it runs so closures of a previous run keep their own values
+func (c *Compiler) pushScope(size int, pos Position) {
+	from := c.beginScope(size)
+	c.chunk.Scopes = append(c.chunk.Scopes, Scope{From: from, Size: size})
+	c.emit(OpPushScope, len(c.chunk.Scopes)-1, pos)
+}
+
+// Compiles a block of statements, with its own scope only if something is declared in it
+func (c *Compiler) compileBlock(stmts []parser.Stmt, pos Position) {
+
+	if !resolver.NeedsScope(stmts) {
+		for _, stmt := range stmts {
+			c.compileStmt(stmt)
+		}
+		return
+	}
+
+	c.pushScope(scopeSize(stmts, 0), pos)
+
+	for _, stmt := range stmts {
+		c.compileStmt(stmt)
+	}
+
+	c.endScope()
+}
+
+// Number of slots the resolver gave to the names declared directly in the statements
+// The try and catch bodies declare their names in the scope around them
+func scopeSize(stmts []parser.Stmt, size int) int {
+
+	for _, stmt := range stmts {
+		switch stmt.StmtType() {
+		case parser.NodeVarDeclaration:
+			size = bindingsSize(stmt.(parser.VarDeclarationNode).Identifiers(), size)
+		case parser.NodeFunctionDeclaration:
+			size = bindingSize(stmt.(parser.FunctionDeclarationNode).Binding, size)
+		case parser.NodeStructDeclaration:
+			size = bindingSize(stmt.(parser.StructDeclarationNode).Binding, size)
+		case parser.NodeInterfaceDeclaration:
+			size = bindingSize(stmt.(parser.InterfaceDeclarationNode).Binding, size)
+		case parser.NodeEnumDeclaration:
+			size = bindingSize(stmt.(parser.EnumDeclarationNode).Binding, size)
+		case parser.NodeTryCatchStatement:
+			node := stmt.(parser.TryCatchNode)
+			size = bindingSize(node.ErrorBinding, size)
+			size = scopeSize(node.Body, size)
+			size = scopeSize(node.Catch, size)
+		}
+	}
+
+	return size
+}
+
+func bindingsSize(identifiers []parser.IdentifierNode, size int) int {
+	for _, identifier := range identifiers {
+		size = bindingSize(identifier.Binding, size)
+	}
+	return size
+}
+
+func bindingSize(binding *parser.Binding, size int) int {
+	if isLocal(binding) && binding.Slot >= size {
+		return binding.Slot + 1
+	}
+	return size
+}
+
+func (c *Compiler) compileStmt(n parser.Stmt) {
+	switch n.StmtType() {
+	case parser.NodeExpStmt:
+		exp := n.(parser.ExpressionStmtNode).Expression
+		c.compileExpression(exp)
+		c.emit(OpPop, 0, Position{})
+	case parser.NodeVarDeclaration:
+		node := n.(parser.VarDeclarationNode)
+		c.compileExpression(node.Right)
+		pos := Position{node.Line, node.Column}
+		if node.Pattern != nil {
+			c.emit(OpDestructure, c.pattern(*node.Pattern), pos)
+		} else {
+			c.declareVar(node.Left.Value, node.Left.Binding, OpDeclareVar, pos)
+		}
+		if node.Constant {
+			for _, identifier := range node.Identifiers() {
+				if !isLocal(identifier.Binding) {
+					c.emit(OpMakeConstant, c.name(identifier.Value), pos)
+				}
+			}
+		}
+	case parser.NodeIfStatement:
+		c.compileIfStmt(n.(parser.IfStatementNode))
+	case parser.NodeForInStatement:
+		c.compileForInStmt(n.(parser.ForInSatementNode))
+	case parser.NodeFunctionDeclaration:
+		node := n.(parser.FunctionDeclarationNode)
+		pos := Position{node.Line, node.Column}
+		c.emit(OpMakeFunction, c.compileFunction(node.Name, "", node.Parameters, node.Generator, node.Body), pos)
+		c.declareVar(node.Name, node.Binding, OpDeclareName, pos)
+	case parser.NodeReturnStatement:
+		c.compileReturnStmt(n.(parser.ReturnNode))
+	case parser.NodeYieldStatement:
+		node := n.(parser.YieldNode)
+		c.compileExpression(node.Value)
+		c.emit(OpYield, 0, Position{node.Line, node.Column})
+	case parser.NodeLoopStatement:
+		c.compileLoopStmt(n.(parser.LoopStmtNode))
+	case parser.NodeWhileStatement:
+		c.compileWhileStmt(n.(parser.WhileStmtNode))
+	case parser.NodeMatchStatement:
+		c.compileMatchStmt(n.(parser.MatchStmtNode))
+	case parser.NodeStructMethodDeclaration:
+		node := n.(parser.StructMethodDeclarationNode)
+		pos := Position{node.Line, node.Column}
+		c.loadVar(node.Struct, node.StructBinding, pos)
+		fn := c.compileFunction(node.Function.Name, node.Struct, node.Function.Parameters, node.Function.Generator, node.Function.Body)
+		if node.Static {
+			c.emit(OpMakeStatic, fn, pos)
+		} else {
+			c.emit(OpMakeMethod, fn, pos)
+		}
+	case parser.NodeBreakStatement:
+		node := n.(parser.BreakNode)
+		c.compileBreakStmt(Position{node.Line, node.Column})
+	case parser.NodeContinueStatement:
+		node := n.(parser.ContinueNode)
+		c.compileContinueStmt(Position{node.Line, node.Column})
+	case parser.NodeTryCatchStatement:
+		c.compileTryCatchStmt(n.(parser.TryCatchNode))
+	case parser.NodeStructDeclaration:
+		node := n.(parser.StructDeclarationNode)
+		pos := Position{node.Line, node.Column}
+		if node.Parent != "" {
+			c.loadVar(node.Parent, node.ParentBinding, pos)
+		}
+		for _, value := range node.Defaults {
+			if value != nil {
+				c.compileExpression(value)
+			} else {
+				c.emit(OpNothing, 0, pos)
+			}
+		}
+		for _, static := range node.Statics {
+			c.compileExpression(static.Value)
+		}
+		for _, iface := range node.Interfaces {
+			c.compileExpression(iface)
+		}
+		c.chunk.Structs = append(c.chunk.Structs, node)
+		c.emit(OpMakeStruct, len(c.chunk.Structs)-1, pos)
+		c.declareVar(node.Name, node.Binding, OpDeclareName, pos)
+	case parser.NodeInterfaceDeclaration:
+		node := n.(parser.InterfaceDeclarationNode)
+		pos := Position{node.Line, node.Column}
+		c.emit(OpConstant, c.constant(evruntime.NewInterface(node)), pos)
+		c.declareVar(node.Name, node.Binding, OpDeclareName, pos)
+	case parser.NodeEnumDeclaration:
+		node := n.(parser.EnumDeclarationNode)
+		pos := Position{node.Line, node.Column}
+		c.chunk.Enums = append(c.chunk.Enums, node)
+		c.emit(OpMakeEnum, len(c.chunk.Enums)-1, pos)
+		c.declareVar(node.Name, node.Binding, OpDeclareName, pos)
+	case parser.NodeImportStatement:
+		node := n.(parser.ImportNode)
+		c.chunk.Imports = append(c.chunk.Imports, node)
+		c.emit(OpImport, len(c.chunk.Imports)-1, Position{node.Line, node.Column})
+	default:
+		c.report("Unknown statement type", Position{})
+	}
+}
+
+func (c *Compiler) compileIfStmt(node parser.IfStatementNode) {
+
+	pos := Position{node.Line, node.Column}
+	ends := make([]int, 0, len(node.ElseIf)+1)
+
+	c.compileExpression(node.Condition)
+	c.emit(OpToBool, 0, pos)
+	next := c.emit(OpJumpIfFalse, 0, pos)
+
+	c.compileBlock(node.Body, pos)
+	ends = append(ends, c.emit(OpJump, 0, pos))
+	c.patch(next)
+
+	for _, elseif := range node.ElseIf {
+		c.compileExpression(elseif.Condition)
+		next := c.emit(OpJumpIfFalse, 0, pos)
+
+		c.compileBlock(elseif.Body, pos)
+		ends = append(ends, c.emit(OpJump, 0, pos))
+		c.patch(next)
+	}
+
+	if node.ElseBody != nil {
+		c.compileBlock(node.ElseBody, pos)
+	}
+
+	for _, end := range ends {
+		c.patch(end)
+	}
+}
+
+func (c *Compiler) compileLoopStmt(node parser.LoopStmtNode) {
+
+	pos := Position{node.Line, node.Column}
+	loop := &block{kind: blockLoop, continueTarget: len(c.chunk.Code)}
+
+	c.pushBlock(loop)
+	c.compileBlock(node.Body, pos)
+	c.popBlock()
+
+	c.emit(OpJump, loop.continueTarget, pos)
+
+	for _, address := range loop.breaks {
+		c.patch(address)
+	}
+}
+
+func (c *Compiler) compileWhileStmt(node parser.WhileStmtNode) {
+
+	pos := Position{node.Line, node.Column}
+	loop := &block{kind: blockLoop, continueTarget: len(c.chunk.Code)}
+
+	c.compileExpression(node.Condition)
+	exit := c.emit(OpJumpIfFalse, 0, pos)
+
+	c.pushBlock(loop)
+	c.compileBlock(node.Body, pos)
+	c.popBlock()
+
+	c.emit(OpJump, loop.continueTarget, pos)
+
+	c.patch(exit)
+
+	for _, address := range loop.breaks {
+		c.patch(address)
+	}
+}
+
+// The value stays on the stack until an arm matches, each arm has its own scope
+func (c *Compiler) compileMatchStmt(node parser.MatchStmtNode) {
+
+	pos := Position{node.Line, node.Column}
+	ends := make([]int, 0, len(node.Arms))
+
+	c.compileExpression(node.Value)
+
+	for _, arm := range node.Arms {
+
+		c.pushScope(scopeSize(arm.Body, bindingsSize(arm.Pattern.Identifiers(), 0)), pos)
+		c.emit(OpMatch, c.pattern(arm.Pattern), pos)
+		next := []int{c.emit(OpJumpIfFalse, 0, pos)}
+
+		if arm.Guard != nil {
+			c.compileExpression(arm.Guard)
+			c.emit(OpToBool, 0, pos)
+			next = append(next, c.emit(OpJumpIfFalse, 0, pos))
+		}
+
+		c.emit(OpPop, 0, pos)
+
+		for _, stmt := range arm.Body {
+			c.compileStmt(stmt)
+		}
+
+		c.endScope()
+		ends = append(ends, c.emit(OpJump, 0, pos))
+
+		for _, address := range next {
+			c.patch(address)
+		}
+	}
+
+	c.emit(OpPop, 0, pos)
+
+	for _, end := range ends {
+		c.patch(end)
+	}
+}
+
+// The iterator stays on the stack while the loop runs and each iteration has its own scope
+func (c *Compiler) compileForInStmt(node parser.ForInSatementNode) {
+
+	pos := Position{node.Line, node.Column}
+
+	c.compileExpression(node.Iterator)
+	c.emit(OpIterInit, 0, pos)
+
+	loop := &block{kind: blockLoop, iterator: true, continueTarget: len(c.chunk.Code)}
+
+	exit := c.emit(OpForIter, 0, pos)
+
+	size := bindingSize(node.IndexBinding, bindingSize(node.LocalBinding, 0))
+	if node.Pattern != nil {
+		size = bindingsSize(node.Pattern.Identifiers(), size)
+	}
+
+	c.pushScope(scopeSize(node.Body, size), pos)
+	if node.Pattern != nil {
+		c.emit(OpDestructure, c.pattern(*node.Pattern), pos)
+	} else {
+		c.declareVar(node.LocalVarName, node.LocalBinding, OpForceDeclare, pos)
+	}
+
+	if node.IndexVarName != "" {
+		c.declareVar(node.IndexVarName, node.IndexBinding, OpForceDeclare, pos)
+	} else {
+		c.emit(OpPop, 0, pos)
+	}
+
+	c.pushBlock(loop)
+
+	for _, stmt := range node.Body {
+		c.compileStmt(stmt)
+	}
+
+	c.popBlock()
+	c.endScope()
+
+	c.emit(OpJump, loop.continueTarget, pos)
+
+	c.patch(exit)
+
+	for _, address := range loop.breaks {
+		c.patch(address)
+	}
+}
+
+// Leaves every block until the nearest loop, running the finally blocks in the way
+func (c *Compiler) unwindToLoop(pos Position) *block {
+
+	for i := len(c.blocks) - 1; i >= 0; i-- {
+		b := c.blocks[i]
+
+		switch b.kind {
+		case blockLoop:
+			return b
+		case blockHandler:
+			c.leaveHandler(i, pos)
+		}
+	}
+
+	return nil
+}
+
+// Removes the handler of the block at the given depth and runs its finally code
+func (c *Compiler) leaveHandler(depth int, pos Position) {
+
+	c.emit(OpPopTry, 0, pos)
+
+	finally := c.blocks[depth].finally
+
+	if finally == nil {
+		return
+	}
+
+	// The finally code is compiled as if it was outside of the try
+	blocks := c.blocks
+	c.blocks = append([]*block{}, blocks[:depth]...)
+	c.compileFinally(finally, pos)
+	c.blocks = blocks
+}
+
+func (c *Compiler) compileBreakStmt(pos Position) {
+
+	loop := c.unwindToLoop(pos)
+
+	if loop == nil {
+		c.report("break outside of a loop", pos)
+		return
+	}
+
+	if loop.iterator {
+		c.emit(OpIterClose, 0, pos)
+	}
+
+	loop.breaks = append(loop.breaks, c.emit(OpJump, 0, pos))
+}
+
+func (c *Compiler) compileContinueStmt(pos Position) {
+
+	loop := c.unwindToLoop(pos)
+
+	if loop == nil {
+		c.report("continue outside of a loop", pos)
+		return
+	}
+
+	c.emit(OpJump, loop.continueTarget, pos)
+}
+
+func (c *Compiler) compileReturnStmt(node parser.ReturnNode) {
+
+	pos := Position{node.Line, node.Column}
+
+	c.compileExpression(node.Right)
+
+	// Locals are dropped with the frame, but finally blocks must run before leaving
+	for i := len(c.blocks) - 1; i >= 0; i-- {
+		if c.blocks[i].kind == blockHandler {
+			c.leaveHandler(i, pos)
+		}
+	}
+
+	c.emit(OpReturn, 0, pos)
+}
+
+// The try and catch bodies share the scope of the statement, the error is declared in it
+func (c *Compiler) compileTryCatchStmt(node parser.TryCatchNode) {
+
+	pos := Position{node.Line, node.Column}
+
+	catch := c.emit(OpSetupTry, 0, pos)
+	c.pushBlock(&block{kind: blockHandler, finally: node.Finally})
+
+	for _, stmt := range node.Body {
+		c.compileStmt(stmt)
+	}
+
+	c.popBlock()
+	c.emit(OpPopTry, 0, pos)
+	c.compileFinally(node.Finally, pos)
+	end := c.emit(OpJump, 0, pos)
+
+	c.patch(catch)
+	c.emit(OpErrorObject, 0, pos)
+	c.declareVar("error", node.ErrorBinding, OpForceDeclare, pos)
+
+	if node.Finally == nil {
+		for _, stmt := range node.Catch {
+			c.compileStmt(stmt)
+		}
+		c.patch(end)
+		return
+	}
+
+	// Errors inside the catch body still run the finally code before going up
+	rethrow := c.emit(OpSetupTry, 0, pos)
+	c.pushBlock(&block{kind: blockHandler, finally: node.Finally})
+
+	for _, stmt := range node.Catch {
+		c.compileStmt(stmt)
+	}
+
+	c.popBlock()
+	c.emit(OpPopTry, 0, pos)
+	c.compileFinally(node.Finally, pos)
+	end2 := c.emit(OpJump, 0, pos)
+
+	c.patch(rethrow)
+	c.compileFinally(node.Finally, pos)
+	c.emit(OpThrow, 0, pos)
+
+	c.patch(end)
+	c.patch(end2)
+}
+
+// The errors raised inside the finally code are ignored
+func (c *Compiler) compileFinally(stmts []parser.Stmt, pos Position) {
+
+	if stmts == nil {
+		return
+	}
+
+	swallow := c.emit(OpSetupTry, 0, pos)
+	c.pushBlock(&block{kind: blockHandler})
+
+	c.compileBlock(stmts, pos)
+
+	c.popBlock()
+	c.emit(OpPopTry, 0, pos)
+	end := c.emit(OpJump, 0, pos)
+
+	c.patch(swallow)
+	c.emit(OpPop, 0, pos)
+	c.patch(end)
+}
+
+// Compiles the body of a function into its own chunk and returns the index of the proto
+// The value of a last expression statement is returned if there is no return
+func (c *Compiler) compileFunction(name string, structName string, parameters []parser.Parameter, generator bool, body []parser.Stmt) int {
+
+	fc := newCompiler()
+	fc.enclosing = c
+
+	// The parameters take the first slots and 'this' and 'super' the next ones, even if it is not a method
+	fc.beginScope(scopeSize(body, len(parameters)+2))
+
+	// Parameters without argument take their default value
+	for slot, param := range parameters {
+		if param.Default == nil {
+			continue
+		}
+
+		fc.emit(OpArgMissing, slot, Position{})
+		skip := fc.emit(OpJumpIfFalse, 0, Position{})
+		fc.compileExpression(param.Default)
+		fc.emit(OpDeclareLocal, fc.local(param.Name, slot), Position{})
+		fc.patch(skip)
+	}
+
+	for i, stmt := range body {
+		if i == len(body)-1 && stmt.StmtType() == parser.NodeExpStmt {
+			fc.compileExpression(stmt.(parser.ExpressionStmtNode).Expression)
+			fc.emit(OpReturn, 0, Position{})
+			break
+		}
+
+		fc.compileStmt(stmt)
+	}
+
+	fc.emit(OpNothing, 0, Position{})
+	fc.emit(OpReturn, 0, Position{})
+
+	c.diagnostics = append(c.diagnostics, fc.diagnostics...)
+
+	proto := &FunctionProto{Name: name, Struct: structName, Parameters: parameters, Generator: generator, Chunk: fc.chunk, Upvalues: fc.upvalues}
+
+	c.chunk.Functions = append(c.chunk.Functions, proto)
+
+	return len(c.chunk.Functions) - 1
+}
+
+func (c *Compiler) compileExpression(n parser.Exp) {
+
+	switch n.ExpType() {
+
+	case parser.NodeNumber:
+		node := n.(parser.NumberNode)
+		c.emit(OpConstant, c.constant(values.NumberValue{Value: node.Value}), Position{node.Line, node.Column})
+	case parser.NodeString:
+		node := n.(parser.StringNode)
+		c.emit(OpConstant, c.constant(values.StringValue{Value: node.Value}), Position{node.Line, node.Column})
+	case parser.NodeBoolean:
+		node := n.(parser.BooleanNode)
+		c.emit(OpConstant, c.constant(values.BoolValue{Value: node.Value}), Position{node.Line, node.Column})
+	case parser.NodeIdentifier:
+		node := n.(parser.IdentifierNode)
+		c.loadVar(node.Value, node.Binding, Position{node.Line, node.Column})
+	case parser.NodeNothing:
+		node := n.(parser.NothingNode)
+		c.emit(OpNothing, 0, Position{node.Line, node.Column})
+	case parser.NodeAssignment:
+		c.compileAssignment(n.(parser.AssignmentNode))
+	case parser.NodeBinaryExp:
+		node := n.(parser.BinaryExpNode)
+		c.compileExpression(node.Left)
+		c.compileExpression(node.Right)
+		c.emit(OpBinary, int(node.Operator), Position{node.Line, node.Column})
+	case parser.NodeBinaryComparisonExp:
+		node := n.(parser.BinaryComparisonExpNode)
+		c.compileExpression(node.Left)
+		c.compileExpression(node.Right)
+		c.emit(OpCompare, int(node.Operator), Position{node.Line, node.Column})
+	case parser.NodeBinaryLogicExp:
+		node := n.(parser.BinaryLogicExpNode)
+		c.compileExpression(node.Left)
+		c.compileExpression(node.Right)
+		c.emit(OpLogic, int(node.Operator), Position{node.Line, node.Column})
+	case parser.NodeUnaryExp:
+		node := n.(parser.UnaryExpNode)
+		c.compileExpression(node.Right)
+		c.emit(OpUnary, c.name(node.Operator), Position{node.Line, node.Column})
+	case parser.NodeCallExp:
+		node := n.(parser.CallExpNode)
+		// Arguments are evaluated before the callee
+		for _, arg := range node.Args {
+			c.compileExpression(arg)
+		}
+		if len(node.NamedArgs) == 0 {
+			c.compileExpression(node.Name)
+			c.emit(OpCall, len(node.Args), Position{node.Line, node.Column})
+			break
+		}
+		// Named arguments go in a dictionary after the positional ones
+		names := make([]string, 0, len(node.NamedArgs))
+		for _, arg := range node.NamedArgs {
+			c.compileExpression(arg.Value)
+			names = append(names, arg.Name)
+		}
+		c.chunk.Keys = append(c.chunk.Keys, names)
+		c.emit(OpMakeDict, len(c.chunk.Keys)-1, Position{node.Line, node.Column})
+		c.compileExpression(node.Name)
+		c.emit(OpCallNamed, len(node.Args), Position{node.Line, node.Column})
+	case parser.NodeArrayExp:
+		node := n.(parser.ArrayExpNode)
+		for _, exp := range node.Value {
+			c.compileExpression(exp)
+		}
+		c.emit(OpMakeArray, len(node.Value), Position{node.Line, node.Column})
+	case parser.NodeIndexAccessExp:
+		node := n.(parser.IndexAccessExpNode)
+		c.compileExpression(node.Left)
+		c.compileExpression(node.Index)
+		c.emit(OpIndex, 0, Position{node.Line, node.Column})
+	case parser.NodeDictionaryExp:
+		node := n.(parser.DictionaryExpNode)
+		c.emit(OpMakeDict, c.compileKeys(node), Position{node.Line, node.Column})
+	case parser.NodeObjectInitExp:
+		node := n.(parser.ObjectInitExpNode)
+		c.compileExpression(node.Struct)
+		c.emit(OpMakeObject, c.compileKeys(node.Value), Position{node.Line, node.Column})
+	case parser.NodeMemberExp:
+		node := n.(parser.MemberExpNode)
+		c.compileExpression(node.Left)
+		c.emit(OpGetMember, c.name(node.Member), Position{node.Line, node.Column})
+	case parser.NodeSliceExp:
+		c.compileSlice(n.(parser.SliceExpNode))
+	case parser.NodeRangeExp:
+		node := n.(parser.RangeExpNode)
+		flags := 0
+		if node.Inclusive {
+			flags |= rangeInclusive
+		}
+		c.compileExpression(node.From)
+		c.compileExpression(node.To)
+		if node.Step != nil {
+			c.compileExpression(node.Step)
+			flags |= rangeStep
+		}
+		c.emit(OpMakeRange, flags, Position{node.Line, node.Column})
+	case parser.NodeInterpolationExp:
+		node := n.(parser.InterpolationNode)
+		for _, part := range node.Parts {
+			c.compileExpression(part)
+		}
+		c.emit(OpInterpolate, len(node.Parts), Position{node.Line, node.Column})
+	case parser.NodeTernaryExp:
+		node := n.(parser.TernaryExpNode)
+		pos := Position{node.Line, node.Column}
+		c.compileExpression(node.Condition)
+		right := c.emit(OpJumpIfFalse, 0, pos)
+		c.compileExpression(node.Left)
+		end := c.emit(OpJump, 0, pos)
+		c.patch(right)
+		c.compileExpression(node.Right)
+		c.patch(end)
+	case parser.NodeAnonFunctionDeclaration:
+		node := n.(parser.AnonFunctionDeclarationNode)
+		c.emit(OpMakeFunction, c.compileFunction("", "", node.Parameters, node.Generator, node.Body), Position{node.Line, node.Column})
+	default:
+		c.report("Unknown expression type", Position{})
+	}
+}
+
+// Compiles the values of a dictionary sorted by key and returns the index of the keys
+func (c *Compiler) compileKeys(node parser.DictionaryExpNode) int {
+
+	keys := make([]string, 0, len(node.Value))
+
+	for key := range node.Value {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		c.compileExpression(node.Value[key])
+	}
+
+	c.chunk.Keys = append(c.chunk.Keys, keys)
+
+	return len(c.chunk.Keys) - 1
+}
+
+func (c *Compiler) compileSlice(node parser.SliceExpNode) {
+
+	bounds := 0
+
+	c.compileExpression(node.Left)
+
+	if node.From != nil {
+		c.compileExpression(node.From)
+		bounds |= sliceFrom
+	}
+
+	if node.To != nil {
+		c.compileExpression(node.To)
+		bounds |= sliceTo
+	}
+
+	c.emit(OpSlice, bounds, Position{node.Line, node.Column})
+}
+
+// The right side is evaluated first, then the target
+func (c *Compiler) compileAssignment(node parser.AssignmentNode) {
+
+	pos := Position{node.Line, node.Column}
+
+	if operator, ok := node.CompoundOperator(); ok {
+		c.compileCompoundAssignment(node, operator, pos)
+		return
+	}
+
+	c.compileExpression(node.Right)
+
+	switch node.Left.ExpType() {
+	case parser.NodeIdentifier:
+		left := node.Left.(parser.IdentifierNode)
+		c.storeVar(left.Value, left.Binding, pos)
+	case parser.NodeIndexAccessExp:
+		left := node.Left.(parser.IndexAccessExpNode)
+		c.compileExpression(left.Left)
+		c.compileExpression(left.Index)
+		c.emit(OpSetIndex, 0, pos)
+	case parser.NodeMemberExp:
+		left := node.Left.(parser.MemberExpNode)
+		c.compileExpression(left.Left)
+		c.emit(OpSetMember, c.name(left.Member), pos)
+	default:
+		c.report("Invalid assignment", pos)
+	}
+}
+
+// The target is evaluated once, its parts are duplicated to read the actual value
+func (c *Compiler) compileCompoundAssignment(node parser.AssignmentNode, operator parser.OperatorType, pos Position) {
+
+	switch node.Left.ExpType() {
+	case parser.NodeIdentifier:
+		left := node.Left.(parser.IdentifierNode)
+		c.loadVar(left.Value, left.Binding, Position{left.Line, left.Column})
+		c.compileExpression(node.Right)
+		c.emit(OpBinary, int(operator), pos)
+		c.storeVar(left.Value, left.Binding, pos)
+	case parser.NodeIndexAccessExp:
+		left := node.Left.(parser.IndexAccessExpNode)
+		c.compileExpression(left.Left)
+		c.compileExpression(left.Index)
+		c.emit(OpDup, 2, pos)
+		c.emit(OpIndex, 0, Position{left.Line, left.Column})
+		c.compileExpression(node.Right)
+		c.emit(OpBinary, int(operator), pos)
+		c.emit(OpUpdateIndex, 0, pos)
+	case parser.NodeMemberExp:
+		left := node.Left.(parser.MemberExpNode)
+		c.compileExpression(left.Left)
+		c.emit(OpDup, 1, pos)
+		c.emit(OpGetMember, c.name(left.Member), Position{left.Line, left.Column})
+		c.compileExpression(node.Right)
+		c.emit(OpBinary, int(operator), pos)
+		c.emit(OpUpdateMember, c.name(left.Member), pos)
+	default:
+		c.report("Invalid assignment", pos)
+	}
+}
@@ -0,0 +1,132 @@
+package vm
+
+type Opcode uint8
+
+const (
+	OpConstant Opcode = iota // Push Constants[Arg]
+	OpNothing                // Push nothing
+	OpPop                    // Discard the top of the stack
+	OpDup                    // Push again the last Arg values keeping their order
+
+	OpLoadName     // Push the variable Names[Arg]
+	OpStoreName    // Assign the top of the stack to the variable Names[Arg], the value is kept
+	OpDeclareVar   // Pop and declare the variable Names[Arg]
+	OpDeclareName  // Pop and declare a function or struct called Names[Arg]
+	OpForceDeclare // Pop and declare Names[Arg] even if it already exists in the scope
+	OpMakeConstant // Mark the variable Names[Arg] of the actual scope as a constant
+	OpLoadLocal    // Push the local in the slot of the frame of Locals[Arg]
+	OpStoreLocal   // Assign the top of the stack to the local in the slot of Locals[Arg], the value is kept
+	OpDeclareLocal // Pop and declare the local in the slot of Locals[Arg]
+	OpLoadUpvalue  // Push the upvalue of the closure that Locals[Arg] points to
+	OpStoreUpvalue // Assign the top of the stack to the upvalue that Locals[Arg] points to, the value is kept
+	OpArgMissing   // Push whether the parameter in the slot Arg did not get an argument
+	OpPushScope    // Empty the slots of the block scope Scopes[Arg], closing the upvalues over them
+
+	OpBinary  // Pop two values and apply the arithmetic operator Arg
+	OpCompare // Pop two values and apply the comparison operator Arg
+	OpLogic   // Pop two values and apply the logic operator Arg
+	OpUnary   // Pop a value and apply the unary operator Names[Arg]
+
+	OpToBool      // Convert the top of the stack to a boolean, failing with an InvalidConversionError
+	OpJump        // Jump to Arg
+	OpJumpIfFalse // Pop a value and jump to Arg if it is false
+
+	OpCall      // Pop the callee and Arg arguments and call it
+	OpCallNamed // Pop the callee, a dictionary with the named arguments and Arg arguments and call it
+	OpReturn    // Return the top of the stack to the caller
+	OpYield     // Pop a value and give it to the code running the generator
+
+	OpMakeArray    // Pop Arg values into a new array
+	OpMakeDict     // Pop a value for each key of Keys[Arg] into a new dictionary
+	OpMakeObject   // Pop a value for each key of Keys[Arg] and the struct into a new object
+	OpMakeFunction // Push a closure of Functions[Arg] over the actual scope
+	OpMakeStruct   // Pop the implemented interfaces, the static values, the default of each property and the inherited struct if it has one into the struct declared by Structs[Arg]
+	OpMakeMethod   // Pop a struct and store a closure of Functions[Arg] as its method
+	OpMakeStatic   // Pop a struct and store a closure of Functions[Arg] as its static function
+	OpMakeEnum     // Push a new enum declared by Enums[Arg]
+	OpInterpolate  // Pop Arg values and push them joined in a string
+	OpMakeRange    // Pop the step if given and the limits into a new range, Arg has rangeInclusive and rangeStep bits
+
+	OpIndex        // Pop an index and a value and push the element
+	OpSetIndex     // Pop an index, a value and the new element, and push the element back
+	OpGetMember    // Pop a value and push its property Names[Arg]
+	OpSetMember    // Pop a value and the new property Names[Arg] and push the property back
+	OpUpdateIndex  // Pop the new element, an index and a value and push the element back
+	OpUpdateMember // Pop the new property Names[Arg] and a value and push the property back
+	OpSlice        // Pop the given bounds and a value and push the slice, Arg has sliceFrom and sliceTo bits
+
+	OpIterInit    // Replace the top of the stack with an iterator over it
+	OpForIter     // Push the next two values of the iterator or pop it and jump to Arg when it is exhausted
+	OpIterClose   // Pop the iterator of a loop left before its end and close it
+	OpMatch       // Pop the values of Patterns[Arg] and push whether the top of the stack matches it, declaring its names
+	OpDestructure // Pop the values of Patterns[Arg] and a value and declare the names of the pattern with its parts, failing if it does not match
+
+	OpSetupTry    // Register a handler at Arg for the errors raised until OpPopTry
+	OpPopTry      // Remove the last registered handler
+	OpErrorObject // Replace the error at the top of the stack with its object
+	OpThrow       // Pop an error and raise it again
+
+	OpImport // Load Imports[Arg]
+)
+
+var opcodeNames = [...]string{
+	"CONSTANT",
+	"NOTHING",
+	"POP",
+	"DUP",
+	"LOAD_NAME",
+	"STORE_NAME",
+	"DECLARE_VAR",
+	"DECLARE_NAME",
+	"FORCE_DECLARE",
+	"MAKE_CONSTANT",
+	"LOAD_LOCAL",
+	"STORE_LOCAL",
+	"DECLARE_LOCAL",
+	"LOAD_UPVALUE",
+	"STORE_UPVALUE",
+	"ARG_MISSING",
+	"PUSH_SCOPE",
+	"BINARY",
+	"COMPARE",
+	"LOGIC",
+	"UNARY",
+	"TO_BOOL",
+	"JUMP",
+	"JUMP_IF_FALSE",
+	"CALL",
+	"CALL_NAMED",
+	"RETURN",
+	"YIELD",
+	"MAKE_ARRAY",
+	"MAKE_DICT",
+	"MAKE_OBJECT",
+	"MAKE_FUNCTION",
+	"MAKE_STRUCT",
+	"MAKE_METHOD",
+	"MAKE_STATIC",
+	"MAKE_ENUM",
+	"INTERPOLATE",
+	"MAKE_RANGE",
+	"INDEX",
+	"SET_INDEX",
+	"GET_MEMBER",
+	"SET_MEMBER",
+	"UPDATE_INDEX",
+	"UPDATE_MEMBER",
+	"SLICE",
+	"ITER_INIT",
+	"FOR_ITER",
+	"ITER_CLOSE",
+	"MATCH",
+	"DESTRUCTURE",
+	"SETUP_TRY",
+	"POP_TRY",
+	"ERROR_OBJECT",
+	"THROW",
+	"IMPORT",
+}
+
+func (op Opcode) String() string {
+	return opcodeNames[op]
+}
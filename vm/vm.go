@@ -0,0 +1,854 @@
+package vm
+
+import (
+	environment "evie/env"
+	"evie/evruntime"
+	"evie/parser"
+	"evie/values"
+	"os"
+)
+
+// A stack machine that runs the chunks built by the compiler
+// The evaluator is shared with the tree walker for errors and the operations over values
+type VM struct {
+	evruntime.Evaluator
+
+	stack  []values.RuntimeValue
+	frames []frame
+
+	// Upvalues over the slots of the frames that are still running
+	open []*upvalue
+}
+
+// The locals of a frame are in the slots after its base, the module environment keeps the rest of the names
+type frame struct {
+	chunk    *Chunk
+	ip       int
+	env      *environment.Environment
+	base     int
+	upvalues []*upvalue
+	handlers []handler
+
+	// Frames of function calls have an entry in the callstack
+	isCall bool
+
+	// Object created by a call to a struct, it is returned instead of the result of its init method
+	object *values.ObjectValue
+
+	// Struct of the method that runs, its private members can be used
+	owner *values.StructValue
+}
+
+// Where to continue when an error is raised inside a try
+type handler struct {
+	target    int
+	stackSize int
+}
+
+// A function of the vm with the locals it took from the functions around it
+type closure struct {
+	proto    *FunctionProto
+	upvalues []*upvalue
+}
+
+// A local taken by a closure, it stays in its slot while the scope that declares it runs and keeps its value after
+type upvalue struct {
+	vm     *VM
+	index  int
+	value  values.RuntimeValue
+	closed bool
+}
+
+func (u *upvalue) get() values.RuntimeValue {
+	if u.closed {
+		return u.value
+	}
+	return u.vm.stack[u.index]
+}
+
+func (u *upvalue) set(value values.RuntimeValue) {
+	if u.closed {
+		u.value = value
+	} else {
+		u.vm.stack[u.index] = value
+	}
+}
+
+func NewVM(evaluator evruntime.Evaluator) *VM {
+	evaluator.CallStack = evruntime.CallStack{Items: make([]evruntime.CallStackItem, 0)}
+	return &VM{Evaluator: evaluator}
+}
+
+// Runs a compiled module, errors that reach the top are printed and stop the program
+func (vm *VM) Evaluate(chunk *Chunk, env *environment.Environment) *environment.Environment {
+
+	ret := vm.execute(chunk, env)
+
+	if ret.GetType() == values.ErrorType {
+		vm.PrintError(ret.(values.ErrorValue))
+		os.Exit(1)
+	}
+
+	return env
+}
+
+// Compiles and runs the statements of an imported module, returns its error or nil
+func (vm *VM) EvaluateModule(ast []parser.Stmt, env *environment.Environment) values.RuntimeValue {
+
+	chunk, err := Compile(ast)
+
+	if err != nil {
+		return vm.Panic(values.SyntaxError, "Errors compiling module "+env.ModuleName+":\n"+err.Error(), 0, 0, env)
+	}
+
+	if ret := vm.execute(chunk, env); ret.GetType() == values.ErrorType {
+		return ret
+	}
+
+	return nil
+}
+
+func (vm *VM) execute(chunk *Chunk, env *environment.Environment) values.RuntimeValue {
+	depth := len(vm.frames)
+	vm.frames = append(vm.frames, frame{chunk: chunk, env: env, base: len(vm.stack)})
+	vm.stack = append(vm.stack, make([]values.RuntimeValue, chunk.Slots)...)
+	return vm.run(depth)
+}
+
+// Calls a function from native code, like the handlers of the http module
+func (vm *VM) ExecuteCallback(fn interface{}, args []interface{}) interface{} {
+
+	fnValue := fn.(values.FunctionValue)
+	fnEnv := fnValue.Environment.(*environment.Environment)
+
+	arguments := make([]values.RuntimeValue, 0, len(args))
+
+	for _, arg := range args {
+		switch val := arg.(type) {
+		case values.ArrayValue:
+			arguments = append(arguments, &val)
+		case values.RuntimeValue:
+			arguments = append(arguments, val)
+		}
+	}
+
+	// Natives give all the values they have, the function may not want all of them
+	if !evruntime.HasRestParameter(fnValue) && len(arguments) > len(fnValue.Parameters) {
+		arguments = arguments[:len(fnValue.Parameters)]
+	}
+
+	if err := evruntime.CheckArity(fnValue, len(arguments)); err != nil {
+		return values.ErrorValue{ErrorType: values.InvalidArgumentError, Value: err.Error()}
+	}
+
+	if fnValue.Generator {
+		return vm.startGenerator(fnValue, arguments, 0, fnEnv)
+	}
+
+	// Callbacks may run at the same time, so each one has its own stack
+	callback := NewVM(vm.Evaluator)
+	callback.CallStack.Items = append(callback.CallStack.Items, vm.CallStack.Items...)
+
+	if err := callback.call(fnValue, arguments, 0, 0, fnEnv.ModuleName); err != nil {
+		return err
+	}
+
+	// Like in the tree engine, the callback is not a call of the script
+	callback.frames[0].isCall = false
+	callback.CallStack.Remove()
+
+	return callback.run(0)
+}
+
+func (vm *VM) push(value values.RuntimeValue) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() values.RuntimeValue {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek() values.RuntimeValue {
+	return vm.stack[len(vm.stack)-1]
+}
+
+// Removes the values above the given size, closing the iterators of the loops left in the way
+func (vm *VM) dropStack(size int) {
+
+	for _, value := range vm.stack[size:] {
+		if it, ok := value.(*iterator); ok {
+			values.CloseIterator(it.Iterator)
+		}
+	}
+
+	vm.stack = vm.stack[:size]
+}
+
+// Pops the last n values keeping their order
+func (vm *VM) popN(n int) []values.RuntimeValue {
+	items := make([]values.RuntimeValue, n)
+	copy(items, vm.stack[len(vm.stack)-n:])
+	vm.stack = vm.stack[:len(vm.stack)-n]
+	return items
+}
+
+// Enters a function, the last argc values of the stack are its arguments and its slots start where they are
+// The arguments are bound already, they may be the values of the stack or a copy placed by name
+func (vm *VM) call(fn values.FunctionValue, args []values.RuntimeValue, argc int, line int, module string) values.RuntimeValue {
+
+	code, ok := fn.Code.(*closure)
+
+	if !ok {
+		vm.stack = vm.stack[:len(vm.stack)-argc]
+		return values.ErrorValue{ErrorType: values.RuntimeError, Value: "The function was not compiled for the vm engine"}
+	}
+
+	top := len(vm.stack)
+	base := top - argc
+	size := code.proto.Chunk.Slots
+
+	for len(vm.stack) < base+size {
+		vm.stack = append(vm.stack, nil)
+	}
+
+	slots := vm.stack[base : base+size]
+	evruntime.SetCallSlots(fn, args, slots)
+
+	// The slots after the parameters are empty until their locals are declared
+	used := len(fn.Parameters)
+	if fn.Struct != "" {
+		used += 2
+	}
+	clear(slots[used:])
+
+	// A rest parameter can leave arguments after the slots
+	if top > base+size {
+		clear(vm.stack[base+size : top])
+		vm.stack = vm.stack[:base+size]
+	}
+
+	vm.CallStack.Add(line, module)
+
+	vm.frames = append(vm.frames, frame{
+		chunk:    code.proto.Chunk,
+		env:      fn.Environment.(*environment.Environment),
+		base:     base,
+		upvalues: code.upvalues,
+		isCall:   true,
+		owner:    fn.Owner,
+	})
+
+	return nil
+}
+
+// Returns the upvalue over a slot of the stack, closures that take the same local share it
+func (vm *VM) capture(index int) *upvalue {
+
+	for _, open := range vm.open {
+		if open.index == index {
+			return open
+		}
+	}
+
+	created := &upvalue{vm: vm, index: index}
+	vm.open = append(vm.open, created)
+
+	return created
+}
+
+// Moves the values of the upvalues over the slots from the given index into them, the slots are going to be reused
+func (vm *VM) closeUpvalues(from int) {
+
+	open := vm.open[:0]
+
+	for _, upvalue := range vm.open {
+		if upvalue.index >= from {
+			upvalue.value = vm.stack[upvalue.index]
+			upvalue.closed = true
+		} else {
+			open = append(open, upvalue)
+		}
+	}
+
+	clear(vm.open[len(open):])
+	vm.open = open
+}
+
+// Returns a generator that runs the function in its own vm when its values are asked
+func (vm *VM) startGenerator(fn values.FunctionValue, args []values.RuntimeValue, line int, env *environment.Environment) values.RuntimeValue {
+
+	evaluator := vm.Evaluator
+	evaluator.CallStack = evruntime.CallStack{Items: append([]evruntime.CallStackItem{}, vm.CallStack.Items...)}
+
+	// The body does not keep the environment of the call, it may hold the generator itself
+	module := env.ModuleName
+
+	return evruntime.NewGenerator(func(co *evruntime.Coroutine) values.RuntimeValue {
+		evaluator.Generator = co
+		generator := NewVM(evaluator)
+
+		if err := generator.call(fn, args, 0, line, module); err != nil {
+			return err
+		}
+
+		result := generator.run(0)
+
+		if result.GetType() == values.ErrorType {
+			return result
+		}
+		return values.NothingValue{}
+	})
+}
+
+// Calls a native or starts the frame of a function with the last argc values of the stack as arguments
+// Returns the result of natives or an error, the arguments are taken from the stack in any case
+func (vm *VM) callValue(callee values.RuntimeValue, argc int, named map[string]values.RuntimeValue, f *frame) values.RuntimeValue {
+
+	switch callee.GetType() {
+	case values.NativeFunctionType:
+		native := callee.(values.NativeFunctionValue)
+		args, err := evruntime.NativeArguments(native, vm.popN(argc), named)
+		if err != nil {
+			return vm.fail(values.InvalidArgumentError, err.Error(), f)
+		}
+		return native.Value(args)
+	case values.FunctionType:
+		fn := callee.(values.FunctionValue)
+		bound, err := evruntime.BindArguments(fn, vm.stack[len(vm.stack)-argc:], named)
+		if err != nil {
+			vm.stack = vm.stack[:len(vm.stack)-argc]
+			return vm.fail(values.InvalidArgumentError, err.Error(), f)
+		}
+		if fn.Generator {
+			generator := vm.startGenerator(fn, append([]values.RuntimeValue{}, bound...), f.position().Line, f.env)
+			vm.stack = vm.stack[:len(vm.stack)-argc]
+			return generator
+		}
+		return vm.call(fn, bound, argc, f.position().Line, f.env.ModuleName)
+	case values.StructType:
+		pos := f.position()
+		object, init := vm.Construct(callee, argc+len(named), pos.Line, pos.Column, f.env)
+		if init == nil || object.GetType() == values.ErrorType {
+			vm.stack = vm.stack[:len(vm.stack)-argc]
+			return object
+		}
+		if err := vm.callValue(init, argc, named, f); err != nil {
+			return err
+		}
+		vm.frames[len(vm.frames)-1].object = object.(*values.ObjectValue)
+		return nil
+	case values.EnumMemberType:
+		pos := f.position()
+		return vm.NewEnumMember(callee.(values.EnumMemberValue), vm.popN(argc), named, pos.Line, pos.Column, f.env)
+	default:
+		vm.stack = vm.stack[:len(vm.stack)-argc]
+		return vm.fail(values.RuntimeError, "Only functions can be called not "+callee.GetType().String(), f)
+	}
+}
+
+// Creates a function value of a proto that takes the locals it uses from the frame, and can use the private members the frame can
+func (vm *VM) closure(proto *FunctionProto, f *frame) values.FunctionValue {
+
+	upvalues := make([]*upvalue, len(proto.Upvalues))
+
+	for i, ref := range proto.Upvalues {
+		if ref.Local {
+			upvalues[i] = vm.capture(f.base + ref.Index)
+		} else {
+			upvalues[i] = f.upvalues[ref.Index]
+		}
+	}
+
+	return values.FunctionValue{
+		Name:        proto.Name,
+		Parameters:  proto.Parameters,
+		Generator:   proto.Generator,
+		Owner:       f.owner,
+		Environment: f.env,
+		Evaluator:   vm,
+		Code:        &closure{proto: proto, upvalues: upvalues},
+	}
+}
+
+// Sends the error to the nearest handler, unwinding the frames in the way
+// Returns false if there is no handler above the given depth
+func (vm *VM) raise(err values.RuntimeValue, depth int) bool {
+
+	for len(vm.frames) > depth {
+
+		f := &vm.frames[len(vm.frames)-1]
+
+		if len(f.handlers) > 0 {
+			h := f.handlers[len(f.handlers)-1]
+			f.handlers = f.handlers[:len(f.handlers)-1]
+
+			vm.dropStack(h.stackSize)
+			vm.push(err)
+			f.ip = h.target
+
+			return true
+		}
+
+		vm.leaveFrame(f)
+	}
+
+	return false
+}
+
+func (vm *VM) leaveFrame(f *frame) {
+
+	if len(vm.open) > 0 {
+		vm.closeUpvalues(f.base)
+	}
+
+	vm.dropStack(f.base)
+	vm.frames = vm.frames[:len(vm.frames)-1]
+
+	if f.isCall {
+		vm.CallStack.Remove()
+	}
+}
+
+// Runs instructions until the frame at the given depth returns
+func (vm *VM) run(depth int) values.RuntimeValue {
+
+	for {
+
+		f := &vm.frames[len(vm.frames)-1]
+		instruction := f.chunk.Code[f.ip]
+		f.ip++
+
+		var result values.RuntimeValue
+
+		switch instruction.Op {
+
+		case OpConstant:
+			vm.push(f.chunk.Constants[instruction.Arg])
+
+		case OpNothing:
+			vm.push(values.NothingValue{})
+
+		case OpPop:
+			vm.pop()
+
+		case OpDup:
+			vm.stack = append(vm.stack, vm.stack[len(vm.stack)-instruction.Arg:]...)
+
+		case OpLoadName:
+			value, err := f.env.GetVar(f.chunk.Names[instruction.Arg])
+			if err != nil {
+				result = vm.fail(values.IdentifierError, err.Error(), f)
+				break
+			}
+			vm.push(value)
+
+		case OpStoreName:
+			if err := f.env.SetVar(f.chunk.Names[instruction.Arg], vm.peek()); err != nil {
+				result = vm.fail(values.RuntimeError, err.Error(), f)
+			}
+
+		case OpDeclareVar:
+			if err := f.env.DeclareVar(f.chunk.Names[instruction.Arg], vm.pop()); err != nil {
+				result = vm.fail(values.RuntimeError, err.Error(), f)
+			}
+
+		case OpDeclareName:
+			if err := f.env.DeclareVar(f.chunk.Names[instruction.Arg], vm.pop()); err != nil {
+				result = vm.fail(values.IdentifierError, err.Error(), f)
+			}
+
+		case OpForceDeclare:
+			f.env.ForceDeclare(f.chunk.Names[instruction.Arg], vm.pop())
+
+		case OpMakeConstant:
+			f.env.MakeConstant(f.chunk.Names[instruction.Arg])
+
+		case OpLoadLocal:
+			local := f.chunk.Locals[instruction.Arg]
+			value := vm.stack[f.base+local.Slot]
+			if value == nil {
+				result = vm.fail(values.IdentifierError, "variable '"+local.Name+"' not found", f)
+				break
+			}
+			vm.push(value)
+
+		case OpStoreLocal:
+			local := f.chunk.Locals[instruction.Arg]
+			if vm.stack[f.base+local.Slot] == nil {
+				result = vm.fail(values.RuntimeError, "variable '"+local.Name+"' not found", f)
+				break
+			}
+			vm.stack[f.base+local.Slot] = vm.peek()
+
+		case OpDeclareLocal:
+			vm.stack[f.base+f.chunk.Locals[instruction.Arg].Slot] = vm.pop()
+
+		case OpLoadUpvalue:
+			local := f.chunk.Locals[instruction.Arg]
+			value := f.upvalues[local.Slot].get()
+			if value == nil {
+				result = vm.fail(values.IdentifierError, "variable '"+local.Name+"' not found", f)
+				break
+			}
+			vm.push(value)
+
+		case OpStoreUpvalue:
+			local := f.chunk.Locals[instruction.Arg]
+			upvalue := f.upvalues[local.Slot]
+			if upvalue.get() == nil {
+				result = vm.fail(values.RuntimeError, "variable '"+local.Name+"' not found", f)
+				break
+			}
+			upvalue.set(vm.peek())
+
+		case OpArgMissing:
+			vm.push(values.BoolValue{Value: vm.stack[f.base+instruction.Arg] == nil})
+
+		case OpPushScope:
+			scope := f.chunk.Scopes[instruction.Arg]
+			from := f.base + scope.From
+			if len(vm.open) > 0 {
+				vm.closeUpvalues(from)
+			}
+			clear(vm.stack[from : from+scope.Size])
+
+		case OpBinary:
+			right := vm.pop()
+			left := vm.pop()
+			pos := f.position()
+			result = vm.BinaryOperation(parser.OperatorType(instruction.Arg), left, right, pos.Line, pos.Column, f.env)
+
+		case OpCompare:
+			right := vm.pop()
+			left := vm.pop()
+			pos := f.position()
+			result = vm.ComparisonOperation(parser.OperatorType(instruction.Arg), left, right, pos.Line, pos.Column, f.env)
+
+		case OpLogic:
+			right := vm.pop()
+			left := vm.pop()
+			pos := f.position()
+			result = vm.LogicOperation(parser.OperatorType(instruction.Arg), left, right, pos.Line, pos.Column, f.env)
+
+		case OpUnary:
+			pos := f.position()
+			result = vm.UnaryOperation(f.chunk.Names[instruction.Arg], vm.pop(), pos.Line, pos.Column, f.env)
+
+		case OpToBool:
+			value, err := vm.EvaluateImplicitBoolConversion(vm.pop())
+			if err != nil {
+				result = vm.fail(values.InvalidConversionError, err.Error(), f)
+				break
+			}
+			vm.push(values.BoolValue{Value: value})
+
+		case OpJump:
+			f.ip = instruction.Arg
+
+		case OpJumpIfFalse:
+			value, err := vm.EvaluateImplicitBoolConversion(vm.pop())
+			if err != nil {
+				result = vm.fail(values.RuntimeError, err.Error(), f)
+				break
+			}
+			if !value {
+				f.ip = instruction.Arg
+			}
+
+		case OpYield:
+			vm.Generator.Yield(vm.pop())
+
+		case OpCall:
+			callee := vm.pop()
+			result = vm.callValue(callee, instruction.Arg, nil, f)
+
+		case OpCallNamed:
+			callee := vm.pop()
+			named := vm.pop().(*values.DictionaryValue).Value
+			result = vm.callValue(callee, instruction.Arg, named, f)
+
+		case OpReturn:
+			value := vm.pop()
+			object := f.object
+			vm.leaveFrame(f)
+
+			if object != nil {
+				value = object
+			}
+
+			if len(vm.frames) == depth {
+				return value
+			}
+
+			vm.push(value)
+
+		case OpMakeArray:
+			vm.push(&values.ArrayValue{Value: vm.popN(instruction.Arg)})
+
+		case OpMakeDict:
+			keys := f.chunk.Keys[instruction.Arg]
+			items := vm.popN(len(keys))
+			dict := make(map[string]values.RuntimeValue, len(keys))
+
+			for i, key := range keys {
+				dict[key] = items[i]
+			}
+
+			vm.push(&values.DictionaryValue{Value: dict})
+
+		case OpMakeObject:
+			keys := f.chunk.Keys[instruction.Arg]
+			items := vm.popN(len(keys))
+			properties := make(map[string]values.RuntimeValue, len(keys))
+
+			for i, key := range keys {
+				properties[key] = items[i]
+			}
+
+			pos := f.position()
+			result = vm.NewObject(vm.pop(), properties, f.owner, pos.Line, pos.Column, f.env)
+
+		case OpMakeFunction:
+			vm.push(vm.closure(f.chunk.Functions[instruction.Arg], f))
+
+		case OpMakeStruct:
+			node := f.chunk.Structs[instruction.Arg]
+			interfaces := vm.popN(len(node.Interfaces))
+			statics := vm.popN(len(node.Statics))
+			defaults := vm.popN(len(node.Properties))
+			var parent values.RuntimeValue
+			if node.Parent != "" {
+				parent = vm.pop()
+			}
+			result = vm.NewStruct(node, parent, defaults, statics, interfaces, f.env)
+
+		case OpMakeEnum:
+			vm.push(evruntime.NewEnum(f.chunk.Enums[instruction.Arg]))
+
+		case OpMakeMethod:
+			proto := f.chunk.Functions[instruction.Arg]
+			pos := f.position()
+			ret := vm.DeclareStructMethod(vm.pop(), proto.Struct, vm.closure(proto, f), proto.Name, pos.Line, pos.Column, f.env)
+			if ret.GetType() == values.ErrorType {
+				result = ret
+			}
+
+		case OpMakeStatic:
+			proto := f.chunk.Functions[instruction.Arg]
+			pos := f.position()
+			ret := vm.DeclareStaticMethod(vm.pop(), proto.Struct, vm.closure(proto, f), proto.Name, pos.Line, pos.Column, f.env)
+			if ret.GetType() == values.ErrorType {
+				result = ret
+			}
+
+		case OpInterpolate:
+			pos := f.position()
+			result = vm.Interpolate(vm.popN(instruction.Arg), pos.Line, pos.Column, f.env)
+
+		case OpMakeRange:
+			var step values.RuntimeValue
+			if instruction.Arg&rangeStep != 0 {
+				step = vm.pop()
+			}
+			to := vm.pop()
+			from := vm.pop()
+
+			pos := f.position()
+			result = vm.MakeRange(from, to, step, instruction.Arg&rangeInclusive != 0, pos.Line, pos.Column, f.env)
+
+		case OpIndex:
+			index := vm.pop()
+			value := vm.pop()
+			pos := f.position()
+			result = vm.IndexValue(value, index, pos.Line, pos.Column, f.env)
+
+		case OpSetIndex:
+			index := vm.pop()
+			value := vm.pop()
+			right := vm.pop()
+			pos := f.position()
+			result = vm.AssignIndex(value, index, right, pos.Line, pos.Column, f.env)
+
+		case OpUpdateIndex:
+			right := vm.pop()
+			index := vm.pop()
+			value := vm.pop()
+			pos := f.position()
+			result = vm.AssignIndex(value, index, right, pos.Line, pos.Column, f.env)
+
+		case OpGetMember:
+			pos := f.position()
+			result = vm.MemberValue(vm.pop(), f.chunk.Names[instruction.Arg], f.owner, pos.Line, pos.Column, f.env)
+
+		case OpSetMember:
+			value := vm.pop()
+			right := vm.pop()
+			pos := f.position()
+			result = vm.AssignMember(value, f.chunk.Names[instruction.Arg], right, f.owner, pos.Line, pos.Column, f.env)
+
+		case OpUpdateMember:
+			right := vm.pop()
+			value := vm.pop()
+			pos := f.position()
+			result = vm.AssignMember(value, f.chunk.Names[instruction.Arg], right, f.owner, pos.Line, pos.Column, f.env)
+
+		case OpSlice:
+			var init, end values.RuntimeValue
+
+			if instruction.Arg&sliceTo != 0 {
+				end = vm.pop()
+			}
+			if instruction.Arg&sliceFrom != 0 {
+				init = vm.pop()
+			}
+
+			pos := f.position()
+			result = vm.SliceValue(vm.pop(), init, end, pos.Line, pos.Column, f.env)
+
+		case OpIterInit:
+			pos := f.position()
+			it, err := vm.GetIterator(vm.pop(), pos.Line, pos.Column, f.env)
+
+			if err != nil {
+				result = err
+				break
+			}
+
+			vm.push(&iterator{it})
+
+		case OpIterClose:
+			values.CloseIterator(vm.pop().(*iterator).Iterator)
+
+		case OpForIter:
+			it := vm.peek().(*iterator)
+
+			local, index, ok := it.Next()
+
+			if !ok {
+				vm.pop()
+				f.ip = instruction.Arg
+				break
+			}
+
+			if local.GetType() == values.ErrorType {
+				result = local
+				break
+			}
+
+			vm.push(index)
+			vm.push(local)
+
+		case OpMatch:
+			pattern := f.chunk.Patterns[instruction.Arg]
+			scope := vm.patternScope(pattern, f)
+			matched, err := vm.MatchPatternIn(pattern.Pattern, vm.peek(), scope, f.env)
+
+			if err != nil {
+				result = err
+				break
+			}
+
+			vm.push(values.BoolValue{Value: matched})
+
+		case OpDestructure:
+			pattern := f.chunk.Patterns[instruction.Arg]
+			scope := vm.patternScope(pattern, f)
+			pos := f.position()
+			result = vm.DestructureIn(pattern.Pattern, vm.pop(), scope, pos.Line, pos.Column, f.env)
+
+		case OpSetupTry:
+			f.handlers = append(f.handlers, handler{target: instruction.Arg, stackSize: len(vm.stack)})
+
+		case OpPopTry:
+			f.handlers = f.handlers[:len(f.handlers)-1]
+
+		case OpErrorObject:
+			vm.push(vm.pop().(values.ErrorValue).Object)
+
+		case OpThrow:
+			result = vm.pop()
+
+		case OpImport:
+			result = vm.ImportModule(f.chunk.Imports[instruction.Arg], f.env, vm.EvaluateModule)
+
+			if result.GetType() != values.ErrorType {
+				result = nil
+			}
+		}
+
+		if result == nil {
+			continue
+		}
+
+		if result.GetType() != values.ErrorType {
+			vm.push(result)
+			continue
+		}
+
+		err := result.(values.ErrorValue)
+
+		// Errors returned by natives or by the operations without a position yet
+		if err.Object == nil {
+			errorType := err.ErrorType
+			if errorType == "" {
+				errorType = values.RuntimeError
+			}
+			err = vm.fail(errorType, err.Value, f).(values.ErrorValue)
+		}
+
+		if !vm.raise(err, depth) {
+			return err
+		}
+	}
+}
+
+// Builds an error at the position of the instruction being executed
+func (vm *VM) fail(errorType string, msg string, f *frame) values.RuntimeValue {
+	pos := f.position()
+	return vm.Panic(errorType, msg, pos.Line, pos.Column, f.env)
+}
+
+func (f *frame) position() Position {
+	return f.chunk.Positions[f.ip-1]
+}
+
+// Gives a pattern the values computed for it on the stack, its locals are declared in the slots of the frame
+type patternScope struct {
+	values []values.RuntimeValue
+	slots  []values.RuntimeValue
+	env    *environment.Environment
+}
+
+// Pops the values of the pattern, they stay in the array of the stack while it is matched
+func (vm *VM) patternScope(pattern Pattern, f *frame) *patternScope {
+	values := vm.stack[len(vm.stack)-pattern.Values:]
+	vm.stack = vm.stack[:len(vm.stack)-pattern.Values]
+	return &patternScope{values: values, slots: vm.stack[f.base:], env: f.env}
+}
+
+func (s *patternScope) PatternValue(pattern parser.Pattern) values.RuntimeValue {
+	return s.values[pattern.Binding.Slot]
+}
+
+func (s *patternScope) DeclarePattern(pattern parser.Pattern, value values.RuntimeValue) {
+	if pattern.Binding.Local {
+		s.slots[pattern.Binding.Slot] = value
+	} else {
+		s.env.ForceDeclare(pattern.Name, value)
+	}
+}
+
+// Keeps the iterator of a for in loop on the stack
+type iterator struct {
+	values.Iterator
+}
+
+func (it *iterator) GetType() values.ValueType { return values.CustomType }
+func (it *iterator) GetString() string         { return "iterator" }
+func (it *iterator) GetNumber() float64        { return 0 }
+func (it *iterator) GetBool() bool             { return true }
+func (it *iterator) GetProp(name string) (values.RuntimeValue, error) {
+	return values.NothingValue{}, nil
+}